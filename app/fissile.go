@@ -3,12 +3,15 @@ package app
 import (
 	"archive/tar"
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/SUSE/fissile/builder"
 	"github.com/SUSE/fissile/compilator"
@@ -18,7 +21,6 @@ import (
 	"github.com/SUSE/fissile/model"
 	"github.com/SUSE/fissile/scripts/compilation"
 	"github.com/SUSE/fissile/util"
-	"github.com/SUSE/stampy"
 	"github.com/SUSE/termui"
 
 	"github.com/fatih/color"
@@ -37,12 +39,34 @@ const (
 	OutputFormatYAML  = "yaml"  // output as YAML
 )
 
+// timingSummaryRows is the number of steps listed in timing summaries
+const timingSummaryRows = 20
+
+// TimingReports selects the timing reports written at the end of commands
+// compiling packages or building images
+type TimingReports struct {
+	CSVPath        string // timings are appended to this CSV file
+	PrometheusPath string // timings are written to this Prometheus textfile
+	Summary        bool   // print a table of the slowest steps
+}
+
+// newTimingCollector returns a collector for the command, or nil if no
+// reports are wanted
+func (r TimingReports) newTimingCollector(command string) *util.TimingCollector {
+	if r.CSVPath == "" && r.PrometheusPath == "" && !r.Summary {
+		return nil
+	}
+	return util.NewTimingCollector(command)
+}
+
 // Fissile represents a fissile application
 type Fissile struct {
 	Version   string
 	UI        *termui.UI
+	Events    util.EventSink
 	cmdErr    error
 	releases  []*model.Release // Only applies for some commands
+	overlays  []string         // Role manifest overlays
 	graphFile *os.File
 }
 
@@ -51,7 +75,138 @@ func NewFissileApplication(version string, ui *termui.UI) *Fissile {
 	return &Fissile{
 		Version: version,
 		UI:      ui,
+		Events:  util.NewHumanEventSink(ui),
+	}
+}
+
+// SetLogFormat selects how progress of long-running commands is reported.
+// In the json format, commands reporting their progress as events write one
+// event per line to standard output, and all other messages go to standard
+// error.  Other commands keep writing their results to standard output, and
+// write any events to standard error.
+func (f *Fissile) SetLogFormat(logFormat string, reportsProgress bool) error {
+	switch logFormat {
+	case OutputFormatHuman:
+		f.Events = util.NewHumanEventSink(f.UI)
+	case OutputFormatJSON:
+		if reportsProgress {
+			f.UI = termui.New(os.Stdin, os.Stderr, nil)
+			f.Events = util.NewJSONEventSink(os.Stdout)
+		} else {
+			f.Events = util.NewJSONEventSink(os.Stderr)
+		}
+	default:
+		return fmt.Errorf("Invalid log format %s; expected %s or %s", logFormat, OutputFormatHuman, OutputFormatJSON)
+	}
+
+	return nil
+}
+
+// SetRoleManifestOverlays selects the overlays applied, in order, to the role
+// manifest loaded by commands
+func (f *Fissile) SetRoleManifestOverlays(overlayPaths []string) {
+	f.overlays = overlayPaths
+}
+
+// loadRoleManifest loads the role manifest, with the overlays applied to it
+func (f *Fissile) loadRoleManifest(roleManifestPath string) (*model.RoleManifest, error) {
+	return model.LoadRoleManifest(roleManifestPath, f.releases, f, f.overlays...)
+}
+
+// ShowManifest prints the role manifest, with the files it includes and the
+// overlays merged into it, or only the given roles of it.  The resolved role
+// manifest is the one fissile builds: its jobs include their specs and
+// consumed links, and its roles their computed settings.
+func (f *Fissile) ShowManifest(roleManifestPath string, resolved bool, roleNames []string, outputFormat OutputFormat) error {
+	var roleManifest *model.RoleManifest
+	var err error
+	if resolved {
+		if len(f.releases) == 0 {
+			return fmt.Errorf("Releases not loaded")
+		}
+		roleManifest, err = f.loadRoleManifest(roleManifestPath)
+	} else {
+		roleManifest, err = model.LoadMergedRoleManifest(roleManifestPath, f.overlays...)
+	}
+	if err != nil {
+		return fmt.Errorf("Error loading roles manifest: %s", err.Error())
+	}
+
+	var manifest interface{} = util.NewMarshalAdapter(roleManifest)
+	if len(roleNames) > 0 {
+		roles, err := roleManifest.SelectRoles(roleNames)
+		if err != nil {
+			return err
+		}
+		selected := make([]interface{}, 0, len(roles))
+		for _, role := range roles {
+			selected = append(selected, util.NewMarshalAdapter(role))
+		}
+		manifest = map[string]interface{}{"roles": selected}
+	}
+
+	var buf []byte
+	switch outputFormat {
+	case OutputFormatHuman, OutputFormatYAML:
+		// The role manifest is meant to be read as YAML
+		buf, err = yaml.Marshal(manifest)
+	case OutputFormatJSON:
+		buf, err = json.Marshal(manifest)
+	default:
+		return fmt.Errorf("Invalid output format '%s', expected one of human, json, or yaml", outputFormat)
+	}
+	if err != nil {
+		return err
+	}
+
+	f.UI.Printf("%s", buf)
+	return nil
+}
+
+// ShowSchema will display the JSON Schema of role manifests
+func (f *Fissile) ShowSchema(outputFormat OutputFormat) error {
+	var buf []byte
+	var err error
+	switch outputFormat {
+	case OutputFormatHuman, OutputFormatJSON:
+		// The schema is meant to be read as JSON
+		buf, err = json.MarshalIndent(model.RoleManifestSchema(), "", "  ")
+		buf = append(buf, '\n')
+	case OutputFormatYAML:
+		buf, err = yaml.Marshal(model.RoleManifestSchema())
+	default:
+		return fmt.Errorf("Invalid output format '%s', expected one of human, json, or yaml", outputFormat)
+	}
+	if err != nil {
+		return err
 	}
+
+	f.UI.Printf("%s", buf)
+	return nil
+}
+
+// Validate checks the role manifest and opinions against the releases,
+// without building anything
+func (f *Fissile) Validate(roleManifestPath, lightManifestPath, darkManifestPath string) error {
+	if len(f.releases) == 0 {
+		return fmt.Errorf("Releases not loaded")
+	}
+
+	roleManifest, err := f.loadRoleManifest(roleManifestPath)
+	if err != nil {
+		return fmt.Errorf("Error loading roles manifest: %s", err.Error())
+	}
+
+	opinions, err := model.NewOpinions(lightManifestPath, darkManifestPath)
+	if err != nil {
+		return fmt.Errorf("Error loading opinions: %s", err.Error())
+	}
+	if errs := f.validateManifestAndOpinions(roleManifest, opinions); len(errs) != 0 {
+		return fmt.Errorf("%s", errs.Errors())
+	}
+
+	f.UI.Println(color.GreenString("The role manifest and opinions are valid."))
+	return nil
 }
 
 // ListPackages will list all BOSH packages within a list of releases
@@ -301,22 +456,20 @@ func newPropertyInfo(maybeHash bool) *propertyInfo {
 }
 
 // Compile will compile a list of dev BOSH releases
-func (f *Fissile) Compile(stemcellImageName string, targetPath, roleManifestPath, metricsPath string, roleNames, releaseNames []string, workerCount int, dockerNetworkMode string, withoutDocker, verbose bool) error {
+func (f *Fissile) Compile(stemcellImageName string, compilationDir, logDir, roleManifestPath string, timingReports TimingReports, roleNames, releaseNames []string, workerCount int, dockerNetworkMode string, packageCache compilator.PackageCache, keepGoing, withoutDocker, verbose bool) error {
 	if len(f.releases) == 0 {
 		return fmt.Errorf("Releases not loaded")
 	}
 
-	if metricsPath != "" {
-		stampy.Stamp(metricsPath, "fissile", "compile-packages", "start")
-		defer stampy.Stamp(metricsPath, "fissile", "compile-packages", "done")
-	}
+	timings := timingReports.newTimingCollector("build-packages")
+	defer f.writeTimingReports(timings, timingReports)
 
 	dockerManager, err := docker.NewImageManager()
 	if err != nil {
 		return fmt.Errorf("Error connecting to docker: %s", err.Error())
 	}
 
-	roleManifest, err := model.LoadRoleManifest(roleManifestPath, f.releases, f)
+	roleManifest, err := f.loadRoleManifest(roleManifestPath)
 	if err != nil {
 		return fmt.Errorf("Error loading roles manifest: %s", err.Error())
 	}
@@ -331,14 +484,37 @@ func (f *Fissile) Compile(stemcellImageName string, targetPath, roleManifestPath
 		f.UI.Printf("         %s (%s)\n", color.YellowString(release.Name), color.MagentaString(release.Version))
 	}
 
+	stemcell, err := compilator.LookupStemcell(dockerManager, stemcellImageName)
+	if err != nil {
+		if !withoutDocker {
+			return fmt.Errorf("Error looking up stemcell image: %s", err.Error())
+		}
+		// Compiling without docker doesn't need the stemcell image; fall
+		// back to a cache directory keyed by its name
+		f.UI.Printf("%s: Could not look up stemcell image %s, compiled packages are cached by image name: %s\n",
+			color.YellowString("Warning"), stemcellImageName, err.Error())
+		stemcell = &model.Stemcell{ImageName: stemcellImageName}
+	}
+
+	targetPath, movedTo, err := stemcell.PrepareCompilationDir(compilationDir)
+	if err != nil {
+		return fmt.Errorf("Error preparing compilation directory: %s", err.Error())
+	}
+	if movedTo == targetPath {
+		f.UI.Printf("Migrated compiled packages for %s to %s\n", color.YellowString(stemcellImageName), color.MagentaString(targetPath))
+	} else if movedTo != "" {
+		f.UI.Printf("%s: Compiled packages for %s predate the stemcell image, they were moved to %s and are not reused\n",
+			color.YellowString("Warning"), stemcellImageName, color.MagentaString(movedTo))
+	}
+
 	var comp *compilator.Compilator
 	if withoutDocker {
-		comp, err = compilator.NewMountNSCompilator(targetPath, metricsPath, stemcellImageName, compilation.LinuxBase, f.Version, f.UI, f)
+		comp, err = compilator.NewMountNSCompilator(targetPath, logDir, timings, stemcellImageName, compilation.LinuxBase, f.Version, f.UI, f, packageCache, f.Events)
 		if err != nil {
 			return fmt.Errorf("Error creating a new compilator: %s", err.Error())
 		}
 	} else {
-		comp, err = compilator.NewDockerCompilator(dockerManager, targetPath, metricsPath, stemcellImageName, compilation.LinuxBase, f.Version, dockerNetworkMode, false, f.UI, f)
+		comp, err = compilator.NewDockerCompilator(dockerManager, targetPath, logDir, timings, stemcellImageName, compilation.LinuxBase, f.Version, dockerNetworkMode, false, f.UI, f, packageCache, f.Events)
 		if err != nil {
 			return fmt.Errorf("Error creating a new compilator: %s", err.Error())
 		}
@@ -349,13 +525,238 @@ func (f *Fissile) Compile(stemcellImageName string, targetPath, roleManifestPath
 		return fmt.Errorf("Error selecting packages to build: %s", err.Error())
 	}
 
-	if err := comp.Compile(workerCount, releases, roles, verbose); err != nil {
-		return fmt.Errorf("Error compiling packages: %s", err.Error())
+	durationsPath := filepath.Join(compilationDir, compilator.CompileDurationsFile)
+	durations, err := compilator.LoadCompileDurations(durationsPath)
+	if err != nil {
+		return err
+	}
+
+	compileErr := comp.Compile(workerCount, releases, roles, durations, keepGoing, verbose)
+
+	// Packages compiled before a failure are worth remembering as well
+	if err := durations.Save(durationsPath); err != nil {
+		f.UI.Printf("%s: Error saving compile durations to %s: %s\n", color.YellowString("Warning"), durationsPath, err.Error())
+	}
+
+	if compileErr != nil {
+		return fmt.Errorf("Error compiling packages: %s", compileErr.Error())
 	}
 
 	return nil
 }
 
+// plannedPackage describes a package in a compile plan
+type plannedPackage struct {
+	Release      string   `yaml:"release" json:"release"`
+	Name         string   `yaml:"name" json:"name"`
+	Fingerprint  string   `yaml:"fingerprint" json:"fingerprint"`
+	Dependencies []string `yaml:"dependencies" json:"dependencies"`
+	Estimate     float64  `yaml:"estimate" json:"estimate"`
+	Historical   bool     `yaml:"historical" json:"historical"`
+	Start        float64  `yaml:"start" json:"start"`
+	End          float64  `yaml:"end" json:"end"`
+	Worker       int      `yaml:"worker" json:"worker"`
+}
+
+// ShowCompilePlan prints the order the packages needed by the roles would be
+// compiled in from scratch, with the critical path and the predicted wall
+// clock time for the number of workers.  Estimates are based on the compile
+// durations recorded in the compilation directory.
+func (f *Fissile) ShowCompilePlan(compilationDir, roleManifestPath string, roleNames []string, workerCount int, outputFormat OutputFormat) error {
+	if len(f.releases) == 0 {
+		return fmt.Errorf("Releases not loaded")
+	}
+
+	roleManifest, err := f.loadRoleManifest(roleManifestPath)
+	if err != nil {
+		return fmt.Errorf("Error loading roles manifest: %s", err.Error())
+	}
+
+	roles, err := roleManifest.SelectRoles(roleNames)
+	if err != nil {
+		return err
+	}
+
+	durations, err := compilator.LoadCompileDurations(filepath.Join(compilationDir, compilator.CompileDurationsFile))
+	if err != nil {
+		return err
+	}
+
+	plan := compilator.PlanCompilation(f.releases, roles, workerCount, durations)
+
+	packageName := func(pkg *model.Package) string {
+		return fmt.Sprintf("%s/%s", pkg.Release.Name, pkg.Name)
+	}
+
+	switch outputFormat {
+	case OutputFormatHuman:
+		f.UI.Println(color.GreenString("Compile plan for %d packages with %d workers:",
+			len(plan.Packages), plan.WorkerCount))
+
+		table := termui.NewTable("Start", "End", "Worker", "Package", "Estimate", "Depends on")
+		for _, planned := range plan.Packages {
+			estimate := planned.Estimate.String()
+			if !planned.Historical {
+				estimate += " (guess)"
+			}
+			var dependencies []string
+			for _, dep := range planned.Dependencies {
+				dependencies = append(dependencies, packageName(dep))
+			}
+			table.Add(
+				planned.Start.String(),
+				planned.End.String(),
+				fmt.Sprintf("%d", planned.Worker),
+				packageName(planned.Package),
+				estimate,
+				strings.Join(dependencies, ", "),
+			)
+		}
+		table.PrintTo(f.UI)
+
+		var criticalPath []string
+		for _, planned := range plan.CriticalPath {
+			criticalPath = append(criticalPath, fmt.Sprintf("%s (%s)", color.YellowString(packageName(planned.Package)), planned.Estimate))
+		}
+		f.UI.Printf("\nCritical path: %s\n", strings.Join(criticalPath, " -> "))
+		f.UI.Printf("Predicted wall clock time: %s\n", color.MagentaString(plan.WallClock.String()))
+	case OutputFormatJSON, OutputFormatYAML:
+		packages := []plannedPackage{}
+		for _, planned := range plan.Packages {
+			info := plannedPackage{
+				Release:      planned.Package.Release.Name,
+				Name:         planned.Package.Name,
+				Fingerprint:  planned.Package.Fingerprint,
+				Dependencies: []string{},
+				Estimate:     planned.Estimate.Seconds(),
+				Historical:   planned.Historical,
+				Start:        planned.Start.Seconds(),
+				End:          planned.End.Seconds(),
+				Worker:       planned.Worker,
+			}
+			for _, dep := range planned.Dependencies {
+				info.Dependencies = append(info.Dependencies, packageName(dep))
+			}
+			packages = append(packages, info)
+		}
+
+		criticalPath := []string{}
+		for _, planned := range plan.CriticalPath {
+			criticalPath = append(criticalPath, packageName(planned.Package))
+		}
+
+		data := map[string]interface{}{
+			"workers":       plan.WorkerCount,
+			"wall_clock":    plan.WallClock.Seconds(),
+			"critical_path": criticalPath,
+			"packages":      packages,
+		}
+
+		var buf []byte
+		if outputFormat == OutputFormatJSON {
+			buf, err = json.Marshal(data)
+		} else {
+			buf, err = yaml.Marshal(data)
+		}
+		if err != nil {
+			return err
+		}
+
+		f.UI.Printf("%s", buf)
+	default:
+		return fmt.Errorf("Invalid output format '%s', expected one of human, json, or yaml", outputFormat)
+	}
+
+	return nil
+}
+
+// ShowCompileLog prints the log of the last compilation of a package of the
+// loaded releases.  The package is given by name, or as <release>/<package>
+// if several releases have a package of that name.
+func (f *Fissile) ShowCompileLog(logDir, packageName string) error {
+	if len(f.releases) == 0 {
+		return fmt.Errorf("Releases not loaded")
+	}
+
+	releaseName := ""
+	if parts := strings.SplitN(packageName, "/", 2); len(parts) == 2 {
+		releaseName, packageName = parts[0], parts[1]
+	}
+
+	var matches []*model.Package
+	for _, release := range f.releases {
+		if releaseName != "" && release.Name != releaseName {
+			continue
+		}
+		if pkg, err := release.LookupPackage(packageName); err == nil {
+			matches = append(matches, pkg)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return fmt.Errorf("Package %s not found in the loaded releases", packageName)
+	case 1:
+	default:
+		var names []string
+		for _, pkg := range matches {
+			names = append(names, fmt.Sprintf("%s/%s", pkg.Release.Name, pkg.Name))
+		}
+		return fmt.Errorf("Package %s is ambiguous, use one of: %s", packageName, strings.Join(names, ", "))
+	}
+
+	logPath := compilator.PackageLogPath(logDir, matches[0])
+	contents, err := ioutil.ReadFile(logPath)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("No compile log for package %s/%s (%s) at %s", matches[0].Release.Name, matches[0].Name, matches[0].Fingerprint, logPath)
+	}
+	if err != nil {
+		return fmt.Errorf("Error reading compile log %s: %s", logPath, err.Error())
+	}
+
+	f.UI.Printf("%s", contents)
+	return nil
+}
+
+// GenerateCompiledReleases exports the compiled packages of the loaded
+// releases as BOSH compiled release tarballs into the output directory
+func (f *Fissile) GenerateCompiledReleases(stemcellImageName, compiledPackagesPath, outputDirectory string, releaseNames []string) error {
+	if len(f.releases) == 0 {
+		return fmt.Errorf("Releases not loaded")
+	}
+
+	releases, err := f.getReleasesByName(releaseNames)
+	if err != nil {
+		return err
+	}
+
+	dockerManager, err := docker.NewImageManager()
+	if err != nil {
+		return fmt.Errorf("Error connecting to docker: %s", err.Error())
+	}
+
+	stemcell, err := compilator.LookupStemcell(dockerManager, stemcellImageName)
+	if err != nil {
+		return fmt.Errorf("Error looking up stemcell image: %s", err.Error())
+	}
+
+	compiledReleaseBuilder, err := builder.NewCompiledReleaseBuilder(stemcell, compiledPackagesPath, f.UI)
+	if err != nil {
+		return fmt.Errorf("Error using stemcell image %s: %s (are the %s and %s labels set?)",
+			stemcellImageName, err.Error(), compilator.StemcellOSLabel, compilator.StemcellVersionLabel)
+	}
+
+	for _, release := range releases {
+		if _, err := compiledReleaseBuilder.Build(release, outputDirectory); err != nil {
+			return err
+		}
+	}
+
+	f.UI.Println(color.GreenString("Done."))
+
+	return nil
+}
+
 // CleanCache inspects the compilation cache and removes all packages
 // which are not referenced (anymore).
 func (f *Fissile) CleanCache(targetPath string) error {
@@ -370,7 +771,7 @@ func (f *Fissile) CleanCache(targetPath string) error {
 	referenced := make(map[string]int)
 	for _, release := range f.releases {
 		for _, pkg := range release.Packages {
-			referenced[pkg.Version] = 1
+			referenced[pkg.Fingerprint] = 1
 		}
 	}
 
@@ -379,27 +780,26 @@ func (f *Fissile) CleanCache(targetPath string) error {
 
 	f.UI.Printf("Cleaning up %s\n", color.MagentaString(targetPath))
 
-	cached, err := filepath.Glob(targetPath + "/*/*")
+	cachedStemcells, err := model.LoadCachedStemcells(targetPath)
 	if err != nil {
 		return err
 	}
 
 	removed := 0
-	for _, cache := range cached {
-		key := filepath.Base(cache)
-		if _, ok := referenced[key]; ok {
-			continue
-		}
+	for _, cachedStemcell := range cachedStemcells {
+		for _, fingerprint := range cachedStemcell.Fingerprints {
+			if _, ok := referenced[fingerprint]; ok {
+				continue
+			}
 
-		relpath, err := filepath.Rel(targetPath, cache)
-		if err != nil {
-			relpath = key
-		}
-		f.UI.Printf("- Removing %s\n", color.YellowString(relpath))
-		if err := os.RemoveAll(cache); err != nil {
-			return err
+			f.UI.Printf("- Removing %s (%s)\n",
+				color.YellowString(filepath.Join(cachedStemcell.Key, fingerprint)),
+				cachedStemcell.Description())
+			if err := os.RemoveAll(filepath.Join(cachedStemcell.Path, fingerprint)); err != nil {
+				return err
+			}
+			removed++
 		}
-		removed++
 	}
 
 	if removed == 0 {
@@ -418,6 +818,113 @@ func (f *Fissile) CleanCache(targetPath string) error {
 	return nil
 }
 
+// cachedPackage describes which stemcells a package has been compiled for
+type cachedPackage struct {
+	Release     string   `yaml:"release" json:"release"`
+	Name        string   `yaml:"name" json:"name"`
+	Fingerprint string   `yaml:"fingerprint" json:"fingerprint"`
+	Stemcells   []string `yaml:"stemcells" json:"stemcells"`
+}
+
+// cachedStemcell describes a stemcell directory of the compilation cache
+type cachedStemcell struct {
+	Description string          `yaml:"description" json:"description"`
+	Stemcell    *model.Stemcell `yaml:"stemcell,omitempty" json:"stemcell,omitempty"`
+}
+
+// ListCache reports which stemcells the packages of the loaded releases have
+// been compiled for, in the compilation cache
+func (f *Fissile) ListCache(compilationDir string, outputFormat OutputFormat) error {
+	if len(f.releases) == 0 {
+		return fmt.Errorf("Releases not loaded")
+	}
+
+	cachedStemcells, err := model.LoadCachedStemcells(compilationDir)
+	if err != nil {
+		return fmt.Errorf("Error reading compilation cache %s: %s", compilationDir, err.Error())
+	}
+
+	stemcells := make(map[string]cachedStemcell)
+	for _, cached := range cachedStemcells {
+		stemcells[cached.Key] = cachedStemcell{
+			Description: cached.Description(),
+			Stemcell:    cached.Stemcell,
+		}
+	}
+
+	var packages []cachedPackage
+	for _, release := range f.releases {
+		for _, pkg := range release.Packages {
+			info := cachedPackage{
+				Release:     release.Name,
+				Name:        pkg.Name,
+				Fingerprint: pkg.Fingerprint,
+				Stemcells:   []string{},
+			}
+			for _, cached := range cachedStemcells {
+				if cached.HasCompiled(pkg.Fingerprint) {
+					info.Stemcells = append(info.Stemcells, cached.Key)
+				}
+			}
+			packages = append(packages, info)
+		}
+	}
+
+	switch outputFormat {
+	case OutputFormatHuman:
+		f.UI.Println(color.GreenString("Stemcells in %s:", color.MagentaString(compilationDir)))
+		for _, cached := range cachedStemcells {
+			f.UI.Printf("%s: %s\n", color.YellowString(cached.Key), cached.Description())
+		}
+		if len(cachedStemcells) == 0 {
+			f.UI.Println("No compiled packages found")
+		}
+
+		for _, release := range f.releases {
+			f.UI.Println(color.GreenString("\n%s release %s (%s)", release.ReleaseType(), color.YellowString(release.Name), color.MagentaString(release.Version)))
+			for _, info := range packages {
+				if info.Release != release.Name {
+					continue
+				}
+
+				var compiledFor []string
+				for _, key := range info.Stemcells {
+					compiledFor = append(compiledFor, stemcells[key].Description)
+				}
+				if len(compiledFor) == 0 {
+					compiledFor = append(compiledFor, color.RedString("not compiled"))
+				}
+
+				f.UI.Printf("%s (%s): %s\n",
+					color.YellowString(info.Name),
+					color.WhiteString(info.Fingerprint),
+					strings.Join(compiledFor, ", "))
+			}
+		}
+	case OutputFormatJSON, OutputFormatYAML:
+		data := map[string]interface{}{
+			"stemcells": stemcells,
+			"packages":  packages,
+		}
+
+		var buf []byte
+		if outputFormat == OutputFormatJSON {
+			buf, err = json.Marshal(data)
+		} else {
+			buf, err = yaml.Marshal(data)
+		}
+		if err != nil {
+			return err
+		}
+
+		f.UI.Printf("%s", buf)
+	default:
+		return fmt.Errorf("Invalid output format '%s', expected one of human, json, or yaml", outputFormat)
+	}
+
+	return nil
+}
+
 // GeneratePackagesRoleImage builds the docker image for the packages layer
 // where all packages are included
 func (f *Fissile) GeneratePackagesRoleImage(stemcellImageName string, roleManifest *model.RoleManifest, noBuild, force bool, roles model.Roles, packagesImageBuilder *builder.PackagesImageBuilder, labels map[string]string) error {
@@ -522,17 +1029,15 @@ func (f *Fissile) GeneratePackagesRoleTarball(repository string, roleManifest *m
 }
 
 // GenerateRoleImages generates all role images using releases
-func (f *Fissile) GenerateRoleImages(targetPath, registry, organization, repository, stemcellImageName, stemcellImageID, metricsPath string, noBuild, force bool, tagExtra string, roleNames []string, workerCount int, roleManifestPath, compiledPackagesPath, lightManifestPath, darkManifestPath, outputDirectory string, labels map[string]string) error {
+func (f *Fissile) GenerateRoleImages(targetPath, registry, organization, repository, stemcellImageName, stemcellImageID string, timingReports TimingReports, noBuild, force bool, tagExtra string, roleNames []string, workerCount int, roleManifestPath, compiledPackagesPath, lightManifestPath, darkManifestPath, outputDirectory string, labels map[string]string) error {
 	if len(f.releases) == 0 {
 		return fmt.Errorf("Releases not loaded")
 	}
 
-	if metricsPath != "" {
-		stampy.Stamp(metricsPath, "fissile", "create-role-images", "start")
-		defer stampy.Stamp(metricsPath, "fissile", "create-role-images", "done")
-	}
+	timings := timingReports.newTimingCollector("build-images")
+	defer f.writeTimingReports(timings, timingReports)
 
-	roleManifest, err := model.LoadRoleManifest(roleManifestPath, f.releases, f)
+	roleManifest, err := f.loadRoleManifest(roleManifestPath)
 	if err != nil {
 		return fmt.Errorf("Error loading roles manifest: %s", err.Error())
 	}
@@ -595,11 +1100,12 @@ func (f *Fissile) GenerateRoleImages(targetPath, registry, organization, reposit
 		targetPath,
 		lightManifestPath,
 		darkManifestPath,
-		metricsPath,
 		tagExtra,
 		f.Version,
+		timings,
 		f.UI,
 		f,
+		f.Events,
 	)
 	if err != nil {
 		return err
@@ -608,6 +1114,31 @@ func (f *Fissile) GenerateRoleImages(targetPath, registry, organization, reposit
 	return roleBuilder.BuildRoleImages(roles, registry, organization, repository, packagesLayerImageName, outputDirectory, force, noBuild, workerCount)
 }
 
+// writeTimingReports writes the selected reports of the timings.  Failing to
+// write them only results in a warning, so it doesn't hide the outcome of
+// the command itself.
+func (f *Fissile) writeTimingReports(timings *util.TimingCollector, timingReports TimingReports) {
+	if timings == nil {
+		return
+	}
+
+	if timingReports.Summary {
+		timings.WriteSummary(f.UI, timingSummaryRows)
+	}
+
+	if timingReports.CSVPath != "" {
+		if err := timings.WriteCSV(timingReports.CSVPath); err != nil {
+			f.UI.Printf("%s: Error writing timings to %s: %s\n", color.YellowString("Warning"), timingReports.CSVPath, err.Error())
+		}
+	}
+
+	if timingReports.PrometheusPath != "" {
+		if err := timings.WritePrometheus(timingReports.PrometheusPath); err != nil {
+			f.UI.Printf("%s: Error writing timings to %s: %s\n", color.YellowString("Warning"), timingReports.PrometheusPath, err.Error())
+		}
+	}
+}
+
 // ListRoleImages lists all dev role images
 func (f *Fissile) ListRoleImages(registry, organization, repository, roleManifestPath, opinionsPath, darkOpinionsPath string, existingOnDocker, withVirtualSize bool, tagExtra string) error {
 	if withVirtualSize && !existingOnDocker {
@@ -628,7 +1159,7 @@ func (f *Fissile) ListRoleImages(registry, organization, repository, roleManifes
 		}
 	}
 
-	roleManifest, err := model.LoadRoleManifest(roleManifestPath, f.releases, f)
+	roleManifest, err := f.loadRoleManifest(roleManifestPath)
 	if err != nil {
 		return fmt.Errorf("Error loading roles manifest: %s", err.Error())
 	}
@@ -690,7 +1221,14 @@ func (f *Fissile) LoadReleases(releasePaths, releaseNames, releaseVersions []str
 
 		var release *model.Release
 		var err error
-		if _, err = isFinalReleasePath(releasePath); err == nil {
+		if model.IsReleaseTarball(releasePath) {
+			// Release tarballs are always final (or compiled) releases; like
+			// final releases, their name and version come from release.MF.
+			release, err = model.NewReleaseFromTarball(releasePath, cacheDir)
+			if err != nil {
+				return fmt.Errorf("Error loading release tarball %s: %s", releasePath, err.Error())
+			}
+		} else if _, err = isFinalReleasePath(releasePath); err == nil {
 			// For final releases, only can use release name and version defined in release.MF, cannot specify them through flags.
 			release, err = model.NewFinalRelease(releasePath)
 			if err != nil {
@@ -728,8 +1266,11 @@ func isFinalReleasePath(releasePath string) (bool, error) {
 		return false, err
 	}
 
+	// Compiled releases have compiled_packages instead of packages
 	if err := util.ValidatePath(filepath.Join(releasePath, "packages"), true, "release 'packages' directory"); err != nil {
-		return false, err
+		if util.ValidatePath(filepath.Join(releasePath, "compiled_packages"), true, "release 'compiled_packages' directory") != nil {
+			return false, err
+		}
 	}
 
 	return true, nil
@@ -897,7 +1438,7 @@ func compareHashes(v1Hash, v2Hash keyHash) *HashDiffs {
 // on Kubernetes
 func (f *Fissile) GenerateKube(roleManifestPath string, defaultFiles []string, settings kube.ExportSettings) error {
 	var err error
-	settings.RoleManifest, err = model.LoadRoleManifest(roleManifestPath, f.releases, f)
+	settings.RoleManifest, err = f.loadRoleManifest(roleManifestPath)
 	if err != nil {
 		return fmt.Errorf("Error loading roles manifest: %s", err.Error())
 	}
@@ -952,6 +1493,25 @@ func (f *Fissile) GenerateKube(roleManifestPath string, defaultFiles []string, s
 		if err != nil {
 			return err
 		}
+
+		chart, err := kube.MakeChart(settings)
+		if err != nil {
+			return err
+		}
+		err = f.writeHelmNode(settings.OutputDir, "Chart.yaml", chart)
+		if err != nil {
+			return err
+		}
+
+		templatesDir := filepath.Join(settings.OutputDir, "templates")
+		err = f.writeHelmFile(templatesDir, "_helpers.tpl", kube.MakeHelpers())
+		if err != nil {
+			return err
+		}
+		err = f.writeHelmFile(templatesDir, "NOTES.txt", kube.MakeNotes(settings))
+		if err != nil {
+			return err
+		}
 	}
 
 	return f.generateKubeRoles(settings)
@@ -996,6 +1556,7 @@ func (f *Fissile) generateAuth(settings kube.ExportSettings) error {
 			return err
 		}
 		outputPath := filepath.Join(authDir, fmt.Sprintf("account-%s.yaml", accountName))
+		start := time.Now()
 		outputFile, err := os.Create(outputPath)
 		if err != nil {
 			return err
@@ -1012,13 +1573,14 @@ func (f *Fissile) generateAuth(settings kube.ExportSettings) error {
 		if err != nil {
 			return err
 		}
+		f.Events.Emit(util.Event{Type: util.EventKubeFileWritten, Path: outputPath, Duration: time.Since(start)})
 	}
 	return nil
 }
 
 func (f *Fissile) writeHelmNode(dirName, fileName string, node helm.Node) error {
 	outputPath := filepath.Join(dirName, fileName)
-	f.UI.Printf("Writing config %s\n", color.CyanString(outputPath))
+	start := time.Now()
 
 	outputFile, err := os.Create(outputPath)
 	if err != nil {
@@ -1026,11 +1588,28 @@ func (f *Fissile) writeHelmNode(dirName, fileName string, node helm.Node) error
 	}
 
 	err = helm.NewEncoder(outputFile, helm.EmptyLines(true)).Encode(node)
-	if err == nil {
-		return outputFile.Close()
+	if err != nil {
+		_ = outputFile.Close()
+		return err
+	}
+	if err := outputFile.Close(); err != nil {
+		return err
 	}
-	_ = outputFile.Close()
-	return err
+	f.Events.Emit(util.Event{Type: util.EventKubeFileWritten, Path: outputPath, Duration: time.Since(start)})
+	return nil
+}
+
+// writeHelmFile writes a helm template which is not a helm node, such as the
+// chart notes or helpers
+func (f *Fissile) writeHelmFile(dirName, fileName, contents string) error {
+	outputPath := filepath.Join(dirName, fileName)
+	start := time.Now()
+
+	if err := ioutil.WriteFile(outputPath, []byte(contents), 0644); err != nil {
+		return err
+	}
+	f.Events.Emit(util.Event{Type: util.EventKubeFileWritten, Path: outputPath, Duration: time.Since(start)})
+	return nil
 }
 
 func (f *Fissile) generateBoshTaskRole(outputFile *os.File, role *model.Role, settings kube.ExportSettings) error {
@@ -1057,18 +1636,6 @@ func (f *Fissile) generateBoshTaskRole(outputFile *os.File, role *model.Role, se
 	return nil
 }
 
-// roleHasStorage returns true if a given role uses shared or
-// persistent volumes.
-func (f *Fissile) roleHasStorage(role *model.Role) bool {
-	for _, volume := range role.Run.Volumes {
-		switch volume.Type {
-		case model.VolumeTypePersistent, model.VolumeTypeShared:
-			return true
-		}
-	}
-	return false
-}
-
 func (f *Fissile) generateKubeRoles(settings kube.ExportSettings) error {
 	for _, role := range settings.RoleManifest.Roles {
 		if role.IsColocatedContainerRole() {
@@ -1089,10 +1656,7 @@ func (f *Fissile) generateKubeRoles(settings kube.ExportSettings) error {
 		}
 		outputPath := filepath.Join(roleTypeDir, fmt.Sprintf("%s.yaml", role.Name))
 
-		f.UI.Printf("Writing config %s for role %s\n",
-			color.CyanString(outputPath),
-			color.CyanString(role.Name),
-		)
+		start := time.Now()
 
 		outputFile, err := os.Create(outputPath)
 		if err != nil {
@@ -1110,11 +1674,16 @@ func (f *Fissile) generateKubeRoles(settings kube.ExportSettings) error {
 		case model.RoleTypeBosh:
 			enc := helm.NewEncoder(outputFile)
 
-			statefulSet, deps, err := kube.NewStatefulSet(role, settings, f)
+			var controller, deps helm.Node
+			if role.Run.Controller == model.RoleControllerDeployment {
+				controller, deps, err = kube.NewDeployment(role, settings, f)
+			} else {
+				controller, deps, err = kube.NewStatefulSet(role, settings, f)
+			}
 			if err != nil {
 				return err
 			}
-			err = enc.Encode(statefulSet)
+			err = enc.Encode(controller)
 			if err != nil {
 				return err
 			}
@@ -1124,7 +1693,56 @@ func (f *Fissile) generateKubeRoles(settings kube.ExportSettings) error {
 					return err
 				}
 			}
+
+			pdb, err := kube.NewPodDisruptionBudget(role, settings)
+			if err != nil {
+				return err
+			}
+			if pdb != nil {
+				err = enc.Encode(pdb)
+				if err != nil {
+					return err
+				}
+			}
+
+			hpa, err := kube.NewHorizontalPodAutoscaler(role, settings)
+			if err != nil {
+				return err
+			}
+			if hpa != nil {
+				err = enc.Encode(hpa)
+				if err != nil {
+					return err
+				}
+			}
+
+			ingress, err := kube.NewIngress(role, settings)
+			if err != nil {
+				return err
+			}
+			if ingress != nil {
+				err = enc.Encode(ingress)
+				if err != nil {
+					return err
+				}
+			}
+
+			if settings.NetworkPolicies {
+				policy, err := kube.NewNetworkPolicy(role, settings)
+				if err != nil {
+					return err
+				}
+				err = enc.Encode(policy)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := outputFile.Close(); err != nil {
+			return err
 		}
+		f.Events.Emit(util.Event{Type: util.EventKubeFileWritten, Path: outputPath, Role: role.Name, Duration: time.Since(start)})
 	}
 
 	return nil
@@ -12,9 +12,11 @@ import (
 	"sync"
 	"testing"
 
+	"github.com/SUSE/fissile/compilator"
 	"github.com/SUSE/fissile/kube"
 	"github.com/SUSE/fissile/model"
 	"github.com/SUSE/fissile/testhelpers"
+	"github.com/SUSE/fissile/util"
 
 	"github.com/SUSE/termui"
 	"github.com/stretchr/testify/assert"
@@ -40,6 +42,61 @@ func TestCleanCacheEmpty(t *testing.T) {
 	}
 }
 
+func TestCompilationCacheByStemcell(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	require.NoError(t, err)
+
+	releasePath := filepath.Join(workDir, "../test-assets/ntp-release")
+	releasePathCacheDir := filepath.Join(releasePath, "bosh-cache")
+
+	compilationDir, err := ioutil.TempDir("", "fissile-tests")
+	require.NoError(t, err)
+	defer os.RemoveAll(compilationDir)
+
+	output := &bytes.Buffer{}
+	ui := termui.New(&bytes.Buffer{}, output, nil)
+	f := NewFissileApplication(".", ui)
+	require.NoError(t, f.LoadReleases([]string{releasePath}, []string{""}, []string{""}, releasePathCacheDir))
+	require.NotEmpty(t, f.releases[0].Packages)
+	pkg := f.releases[0].Packages[0]
+
+	stemcell := &model.Stemcell{ImageName: "stemcell:latest", ImageID: "sha256:0123456789abcdef", OS: "ubuntu-trusty", Version: "3586.40"}
+	stemcellDir, _, err := stemcell.PrepareCompilationDir(compilationDir)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Join(pkg.GetPackageCompiledDir(stemcellDir), "bin"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(stemcellDir, "unreferenced", "compiled"), 0755))
+
+	require.NoError(t, f.ListCache(compilationDir, OutputFormatJSON))
+	var report struct {
+		Stemcells map[string]struct {
+			Description string
+		}
+		Packages []struct {
+			Name      string
+			Stemcells []string
+		}
+	}
+	require.NoError(t, json.Unmarshal(output.Bytes(), &report))
+	if assert.Contains(report.Stemcells, "0123456789abcdef") {
+		assert.Equal("ubuntu-trusty/3586.40 (stemcell:latest, 0123456789ab)", report.Stemcells["0123456789abcdef"].Description)
+	}
+	require.Len(t, report.Packages, len(f.releases[0].Packages))
+	for _, info := range report.Packages {
+		if info.Name == pkg.Name {
+			assert.Equal([]string{"0123456789abcdef"}, info.Stemcells)
+		} else {
+			assert.Empty(info.Stemcells)
+		}
+	}
+
+	require.NoError(t, f.CleanCache(compilationDir))
+	assert.NoError(util.ValidatePath(pkg.GetPackageCompiledDir(stemcellDir), true, "referenced package"))
+	assert.Error(util.ValidatePath(filepath.Join(stemcellDir, "unreferenced"), true, "unreferenced package"))
+	assert.NoError(util.ValidatePath(filepath.Join(stemcellDir, model.StemcellInfoFile), false, "stemcell info"))
+}
+
 func TestListPackages(t *testing.T) {
 	ui := termui.New(&bytes.Buffer{}, ioutil.Discard, nil)
 	assert := assert.New(t)
@@ -366,7 +423,7 @@ func TestGenerateAuth(t *testing.T) {
 	samples := map[string][]string{
 		`auth/auth-role-extra-permissions.yaml`: []string{
 			`{
-				"apiVersion": "rbac.authorization.k8s.io/v1beta1",
+				"apiVersion": "rbac.authorization.k8s.io/v1",
 				"kind": "Role",
 				"metadata": {
 					"name": "extra-permissions"
@@ -382,7 +439,7 @@ func TestGenerateAuth(t *testing.T) {
 		},
 		`auth/auth-role-pointless.yaml`: []string{
 			`{
-				"apiVersion": "rbac.authorization.k8s.io/v1beta1",
+				"apiVersion": "rbac.authorization.k8s.io/v1",
 				"kind": "Role",
 				"metadata": {
 					"name": "pointless"
@@ -405,7 +462,7 @@ func TestGenerateAuth(t *testing.T) {
 				}
 			}`,
 			`{
-				"apiVersion": "rbac.authorization.k8s.io/v1beta1",
+				"apiVersion": "rbac.authorization.k8s.io/v1",
 				"kind": "RoleBinding",
 				"metadata": {
 					"name": "non-default-extra-permissions-binding"
@@ -426,7 +483,7 @@ func TestGenerateAuth(t *testing.T) {
 		`auth/account-default.yaml`: []string{
 			// Service accounts named "default" should not get created
 			`{
-				"apiVersion": "rbac.authorization.k8s.io/v1beta1",
+				"apiVersion": "rbac.authorization.k8s.io/v1",
 				"kind": "RoleBinding",
 				"metadata": {
 					"name": "default-pointless-binding"
@@ -666,12 +723,220 @@ func TestFissileGenerateKubeRoles(t *testing.T) {
 	require.NoError(t, err)
 	defer os.RemoveAll(outDir)
 
+	events := &bytes.Buffer{}
+	f.Events = util.NewJSONEventSink(events)
 	err = f.generateKubeRoles(kube.ExportSettings{OutputDir: outDir, RoleManifest: roleManifest})
 	assert.NoError(t, err)
 
-	for _, name := range []string{"myrole-deployment.yaml", "myrole-clustered.yaml"} {
+	// Each written file is reported, with the time it took
+	decoder := json.NewDecoder(events)
+	written := 0
+	for decoder.More() {
+		var event struct {
+			Event    string  `json:"event"`
+			Path     string  `json:"path"`
+			Duration float64 `json:"duration"`
+		}
+		require.NoError(t, decoder.Decode(&event))
+		assert.Equal(t, string(util.EventKubeFileWritten), event.Event)
+		assert.NotEmpty(t, event.Path)
+		assert.True(t, event.Duration > 0, "No duration for %s", event.Path)
+		written++
+	}
+	assert.Equal(t, 2, written)
+
+	// Roles that may have several instances keep their statefulsets
+	expectedKinds := map[string]string{
+		"myrole-deployment.yaml": "Deployment",
+		"myrole-clustered.yaml":  "StatefulSet",
+	}
+	for name, kind := range expectedKinds {
 		path := filepath.Join(outDir, "bosh", name)
-		_, err := os.Stat(path)
-		assert.NoError(t, err, "Failed to find output %s", name)
+		contents, err := ioutil.ReadFile(path)
+		if assert.NoError(t, err, "Failed to find output %s", name) {
+			assert.Contains(t, string(contents), fmt.Sprintf("kind: %q", kind), "Wrong controller in %s", name)
+		}
 	}
 }
+
+func TestShowCompilePlan(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	require.NoError(t, err)
+
+	releasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	releasePathCacheDir := filepath.Join(releasePath, "bosh-cache")
+	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/app/tor-validation-ok.yml")
+
+	compilationDir, err := ioutil.TempDir("", "fissile-tests")
+	require.NoError(t, err)
+	defer os.RemoveAll(compilationDir)
+
+	require.NoError(t, ioutil.WriteFile(
+		filepath.Join(compilationDir, "compile-durations.yml"),
+		[]byte("packages:\n  tor/libevent: 120\n"),
+		0644))
+
+	output := &bytes.Buffer{}
+	f := NewFissileApplication(".", termui.New(&bytes.Buffer{}, output, nil))
+	err = f.LoadReleases([]string{releasePath}, []string{""}, []string{""}, releasePathCacheDir)
+	require.NoError(t, err, "Failed to load release from %s", releasePath)
+
+	err = f.ShowCompilePlan(compilationDir, roleManifestPath, nil, 2, OutputFormatJSON)
+	require.NoError(t, err)
+
+	var plan struct {
+		Workers      int              `json:"workers"`
+		WallClock    float64          `json:"wall_clock"`
+		CriticalPath []string         `json:"critical_path"`
+		Packages     []plannedPackage `json:"packages"`
+	}
+	require.NoError(t, json.Unmarshal(output.Bytes(), &plan))
+
+	assert.Equal(2, plan.Workers)
+	assert.Equal([]string{"tor/libevent", "tor/tor"}, plan.CriticalPath)
+	assert.Equal(180.0, plan.WallClock, "libevent takes 2 minutes, tor is guessed at one")
+	if assert.Len(plan.Packages, 2) {
+		assert.Equal("libevent", plan.Packages[0].Name)
+		assert.True(plan.Packages[0].Historical)
+		assert.Equal("tor", plan.Packages[1].Name)
+		assert.False(plan.Packages[1].Historical)
+		assert.Equal([]string{"tor/libevent"}, plan.Packages[1].Dependencies)
+		assert.Equal(120.0, plan.Packages[1].Start)
+	}
+
+	assert.Error(f.ShowCompilePlan(compilationDir, roleManifestPath, nil, 2, OutputFormat("xml")))
+}
+
+func TestShowCompileLog(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	require.NoError(t, err)
+
+	releasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	releasePathCacheDir := filepath.Join(releasePath, "bosh-cache")
+
+	logDir, err := ioutil.TempDir("", "fissile-tests")
+	require.NoError(t, err)
+	defer os.RemoveAll(logDir)
+
+	output := &bytes.Buffer{}
+	f := NewFissileApplication(".", termui.New(&bytes.Buffer{}, output, nil))
+	err = f.LoadReleases([]string{releasePath}, []string{""}, []string{""}, releasePathCacheDir)
+	require.NoError(t, err, "Failed to load release from %s", releasePath)
+
+	err = f.ShowCompileLog(logDir, "libevent")
+	assert.Error(err, "A package never compiled should have no log")
+
+	pkg, err := f.releases[0].LookupPackage("libevent")
+	require.NoError(t, err)
+	logPath := compilator.PackageLogPath(logDir, pkg)
+	require.NoError(t, os.MkdirAll(filepath.Dir(logPath), 0755))
+	require.NoError(t, ioutil.WriteFile(logPath, []byte("checking for gcc... gcc\n"), 0644))
+
+	require.NoError(t, f.ShowCompileLog(logDir, "libevent"))
+	assert.Equal("checking for gcc... gcc\n", output.String())
+
+	output.Reset()
+	require.NoError(t, f.ShowCompileLog(logDir, "tor/libevent"))
+	assert.Equal("checking for gcc... gcc\n", output.String())
+
+	assert.Error(f.ShowCompileLog(logDir, "nats"))
+	assert.Error(f.ShowCompileLog(logDir, "cf/libevent"))
+}
+
+func TestShowManifest(t *testing.T) {
+	workDir, err := os.Getwd()
+	require.NoError(t, err)
+
+	releasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	releasePathCacheDir := filepath.Join(releasePath, "bosh-cache")
+	modelDir := filepath.Join(workDir, "../test-assets/role-manifests/model")
+	roleManifestPath := filepath.Join(modelDir, "includes-good.yml")
+
+	output := &bytes.Buffer{}
+	f := NewFissileApplication(".", termui.New(&bytes.Buffer{}, output, nil))
+	f.SetRoleManifestOverlays([]string{filepath.Join(modelDir, "overlays/merge.yml")})
+
+	t.Run("Merged", func(t *testing.T) {
+		output.Reset()
+		require.NoError(t, f.ShowManifest(roleManifestPath, false, nil, OutputFormatYAML))
+
+		var manifest struct {
+			Roles []struct {
+				Name string `yaml:"name"`
+				Run  struct {
+					Scaling struct {
+						Max int `yaml:"max"`
+					} `yaml:"scaling"`
+				} `yaml:"run"`
+			} `yaml:"roles"`
+			Configuration struct {
+				Templates map[string]string `yaml:"templates"`
+			} `yaml:"configuration"`
+		}
+		require.NoError(t, yaml.Unmarshal(output.Bytes(), &manifest))
+		var roles []string
+		for _, role := range manifest.Roles {
+			roles = append(roles, role.Name)
+		}
+		assert.Equal(t, []string{"myrole", "foorole", "newrole"}, roles)
+		assert.Equal(t, 3, manifest.Roles[0].Run.Scaling.Max)
+		assert.Contains(t, manifest.Configuration.Templates, "properties.tor.private_key")
+	})
+
+	t.Run("NotLoaded", func(t *testing.T) {
+		assert.EqualError(t, f.ShowManifest(roleManifestPath, true, nil, OutputFormatYAML), "Releases not loaded")
+	})
+
+	err = f.LoadReleases([]string{releasePath}, []string{""}, []string{""}, releasePathCacheDir)
+	require.NoError(t, err, "Failed to load release from %s", releasePath)
+
+	t.Run("Resolved", func(t *testing.T) {
+		output.Reset()
+		require.NoError(t, f.ShowManifest(roleManifestPath, true, []string{"foorole"}, OutputFormatJSON))
+
+		var manifest struct {
+			Roles []struct {
+				Name string `json:"name"`
+				Jobs []struct {
+					Name        string   `json:"name"`
+					Fingerprint string   `json:"fingerprint"`
+					Packages    []string `json:"packages"`
+				} `json:"jobs"`
+				Run struct {
+					FlightStage string `json:"flight-stage"`
+				} `json:"run"`
+			} `json:"roles"`
+		}
+		require.NoError(t, json.Unmarshal(output.Bytes(), &manifest))
+		require.Len(t, manifest.Roles, 1)
+		role := manifest.Roles[0]
+		assert.Equal(t, "foorole", role.Name)
+		assert.Equal(t, "flight", role.Run.FlightStage)
+		require.Len(t, role.Jobs, 1)
+		assert.Equal(t, "tor", role.Jobs[0].Name)
+		assert.NotEmpty(t, role.Jobs[0].Fingerprint)
+		assert.Contains(t, role.Jobs[0].Packages, "tor")
+	})
+
+	t.Run("UnknownRole", func(t *testing.T) {
+		assert.EqualError(t, f.ShowManifest(roleManifestPath, true, []string{"missing"}, OutputFormatYAML),
+			"Some roles are unknown: [missing]")
+	})
+}
+
+func TestShowSchema(t *testing.T) {
+	output := &bytes.Buffer{}
+	f := NewFissileApplication(".", termui.New(&bytes.Buffer{}, output, nil))
+
+	require.NoError(t, f.ShowSchema(OutputFormatJSON))
+	var schema map[string]interface{}
+	require.NoError(t, json.Unmarshal(output.Bytes(), &schema))
+	assert.Equal(t, "http://json-schema.org/draft-07/schema#", schema["$schema"])
+	assert.Contains(t, schema["properties"], "roles")
+
+	assert.EqualError(t, f.ShowSchema(OutputFormat("xml")), "Invalid output format 'xml', expected one of human, json, or yaml")
+}
@@ -22,28 +22,33 @@ func (f *Fissile) validateManifestAndOpinions(roleManifest *model.RoleManifest,
 	lightOpinions := model.FlattenOpinions(opinions.Light, false)
 	manifestProperties := collectManifestProperties(roleManifest)
 
+	manifestPosition := manifestPropertyPosition(roleManifest)
+
 	// All properties must be defined in a BOSH release
 	allErrs = append(allErrs, checkForUndefinedBOSHProperties("role-manifest",
-		manifestProperties, boshPropertyDefaultsAndJobs)...)
+		manifestProperties, boshPropertyDefaultsAndJobs, manifestPosition)...)
 
 	// All light opinions must exists in a bosh release
 	allErrs = append(allErrs, checkForUndefinedBOSHProperties("light opinion",
-		lightOpinions, boshPropertyDefaultsAndJobs)...)
+		lightOpinions, boshPropertyDefaultsAndJobs, opinions.LightPosition)...)
 
 	// All dark opinions must exists in a bosh release
 	allErrs = append(allErrs, checkForUndefinedBOSHProperties("dark opinion",
-		darkOpinions, boshPropertyDefaultsAndJobs)...)
+		darkOpinions, boshPropertyDefaultsAndJobs, opinions.DarkPosition)...)
 
 	// All dark opinions must be configured as templates
-	allErrs = append(allErrs, checkForUntemplatedDarkOpinions(darkOpinions,
-		manifestProperties)...)
+	allErrs = append(allErrs, locatePropertyErrors(checkForUntemplatedDarkOpinions(darkOpinions,
+		manifestProperties), opinions.DarkPosition)...)
 
 	// No dark opinions must have defaults in light opinions
-	allErrs = append(allErrs, checkForDarkInTheLight(darkOpinions, lightOpinions)...)
+	allErrs = append(allErrs, locatePropertyErrors(checkForDarkInTheLight(darkOpinions,
+		lightOpinions), opinions.LightPosition)...)
 
 	// No duplicates must exist between role manifest and light
 	// opinions
-	allErrs = append(allErrs, checkForDuplicatesBetweenManifestAndLight(lightOpinions, roleManifest)...)
+	duplicateErrs := checkForDuplicatesBetweenManifestAndLight(lightOpinions, roleManifest)
+	roleManifest.LocateErrors(duplicateErrs)
+	allErrs = append(allErrs, duplicateErrs...)
 
 	// All bosh properties in a release should have the same
 	// default across jobs -- WARNING only, not error
@@ -51,15 +56,40 @@ func (f *Fissile) validateManifestAndOpinions(roleManifest *model.RoleManifest,
 
 	// All light opinions should differ from their defaults in the
 	// BOSH releases
-	allErrs = append(allErrs, f.checkLightDefaults(lightOpinions,
-		boshPropertyDefaultsAndJobs)...)
+	allErrs = append(allErrs, locatePropertyErrors(f.checkLightDefaults(lightOpinions,
+		boshPropertyDefaultsAndJobs), opinions.LightPosition)...)
+
+	return allErrs
+}
 
+// manifestPropertyPosition returns a function locating the properties of the
+// role manifest, set either globally or by a role
+func manifestPropertyPosition(roleManifest *model.RoleManifest) func(string) validation.Position {
+	return func(property string) validation.Position {
+		if _, ok := roleManifest.Configuration.Templates[property]; ok {
+			return roleManifest.Position(fmt.Sprintf("configuration.templates[%s]", property))
+		}
+		for _, role := range roleManifest.Roles {
+			if _, ok := role.Configuration.Templates[property]; ok {
+				return roleManifest.Position(fmt.Sprintf("roles[%s].configuration.templates[%s]", role.Name, property))
+			}
+		}
+		return validation.Position{}
+	}
+}
+
+// locatePropertyErrors sets the positions of validation errors about
+// properties, which are named after them
+func locatePropertyErrors(allErrs validation.ErrorList, locate func(string) validation.Position) validation.ErrorList {
+	for _, err := range allErrs {
+		err.Position = locate(err.Field)
+	}
 	return allErrs
 }
 
 // Check that the given 'properties' are all defined in a 'bosh'
-// release.
-func checkForUndefinedBOSHProperties(label string, properties map[string]string, bosh propertyDefaults) validation.ErrorList {
+// release.  The errors are located with the given function.
+func checkForUndefinedBOSHProperties(label string, properties map[string]string, bosh propertyDefaults, locate func(string) validation.Position) validation.ErrorList {
 	// All provided properties must be defined in a BOSH release
 	allErrs := validation.ErrorList{}
 
@@ -83,8 +113,9 @@ func checkForUndefinedBOSHProperties(label string, properties map[string]string,
 				continue
 			}
 
-			allErrs = append(allErrs, validation.NotFound(
-				fmt.Sprintf("%s '%s'", label, p), "In any BOSH release"))
+			err := validation.NotFound(fmt.Sprintf("%s '%s'", label, p), "In any BOSH release")
+			err.Position = locate(property)
+			allErrs = append(allErrs, err)
 		}
 	}
 
@@ -68,6 +68,19 @@ func TestValidation(t *testing.T) {
 		assert.Contains(t, actual, expected)
 	}
 	assert.Len(t, errs, len(allExpected))
+
+	// Errors are located in the files setting the properties
+	for _, expected := range []string{
+		lightManifestPath + `:3:5: light opinion 'tor.opinion'`,
+		darkManifestPath + `:4:5: properties.tor.dark-opinion: Not found`,
+		lightManifestPath + `:5:5: properties.tor.masked_opinion: Forbidden`,
+		lightManifestPath + `:6:5: properties.tor.hostname: Forbidden`,
+		roleManifestPath + `:30:5: role-manifest 'fox'`,
+		roleManifestPath + `:13:7: role-manifest 'tor.bogus'`,
+		roleManifestPath + `:27:5: configuration.templates[properties.tor.hostname]: Forbidden`,
+	} {
+		assert.Contains(t, actual, expected)
+	}
 }
 
 func TestValidationOk(t *testing.T) {
@@ -133,3 +146,44 @@ func TestValidationHash(t *testing.T) {
 	}
 	assert.Len(t, errs, len(allExpected))
 }
+
+func TestValidate(t *testing.T) {
+	workDir, err := os.Getwd()
+	assert.NoError(t, err)
+
+	torReleasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	torReleasePathBoshCache := filepath.Join(torReleasePath, "bosh-cache")
+	lightManifestPath := filepath.Join(workDir, "../test-assets/test-opinions/good-opinions.yml")
+	darkManifestPath := filepath.Join(workDir, "../test-assets/test-opinions/good-dark-opinions.yml")
+
+	output := &bytes.Buffer{}
+	f := NewFissileApplication(".", termui.New(&bytes.Buffer{}, output, nil))
+
+	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/app/tor-validation-ok.yml")
+	err = f.Validate(roleManifestPath, lightManifestPath, darkManifestPath)
+	assert.EqualError(t, err, "Releases not loaded")
+
+	err = f.LoadReleases([]string{torReleasePath}, []string{""}, []string{""}, torReleasePathBoshCache)
+	require.NoError(t, err)
+
+	t.Run("Ok", func(t *testing.T) {
+		output.Reset()
+		err := f.Validate(roleManifestPath, lightManifestPath, darkManifestPath)
+		assert.NoError(t, err)
+		assert.Contains(t, output.String(), "The role manifest and opinions are valid.")
+	})
+
+	t.Run("UnknownKeys", func(t *testing.T) {
+		roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/model/unknown-keys.yml")
+		err := f.Validate(roleManifestPath, lightManifestPath, darkManifestPath)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), roleManifestPath+":7:5: roles[myrole].run.virtual_cpus: Forbidden: Unknown key, did you mean virtual-cpus?")
+	})
+
+	t.Run("Issues", func(t *testing.T) {
+		roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/app/tor-validation-issues.yml")
+		err := f.Validate(roleManifestPath, lightManifestPath, darkManifestPath)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `role-manifest 'fox': Not found: "In any BOSH release"`)
+	})
+}
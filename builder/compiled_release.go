@@ -0,0 +1,295 @@
+package builder
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/SUSE/fissile/model"
+	"github.com/SUSE/fissile/util"
+	"github.com/SUSE/termui"
+
+	"github.com/fatih/color"
+	"gopkg.in/yaml.v2"
+)
+
+// CompiledReleaseBuilder exports the packages compiled by fissile as BOSH
+// compiled release tarballs
+type CompiledReleaseBuilder struct {
+	stemcellOS           string
+	stemcellVersion      string
+	compiledPackagesPath string
+	ui                   *termui.UI
+}
+
+// compiledReleaseManifest is the release.MF of a compiled release
+type compiledReleaseManifest struct {
+	Name               string                   `yaml:"name"`
+	Version            string                   `yaml:"version"`
+	CommitHash         string                   `yaml:"commit_hash"`
+	UncommittedChanges bool                     `yaml:"uncommitted_changes"`
+	CompiledPackages   []compiledReleasePackage `yaml:"compiled_packages"`
+	Jobs               []compiledReleaseArchive `yaml:"jobs"`
+	License            *compiledReleaseArchive  `yaml:"license,omitempty"`
+}
+
+// compiledReleaseArchive describes a job or license archive in release.MF
+type compiledReleaseArchive struct {
+	Name        string `yaml:"name,omitempty"`
+	Version     string `yaml:"version"`
+	Fingerprint string `yaml:"fingerprint"`
+	SHA1        string `yaml:"sha1"`
+}
+
+// compiledReleasePackage describes a compiled package in release.MF
+type compiledReleasePackage struct {
+	Name         string   `yaml:"name"`
+	Version      string   `yaml:"version"`
+	Fingerprint  string   `yaml:"fingerprint"`
+	SHA1         string   `yaml:"sha1"`
+	Stemcell     string   `yaml:"stemcell"`
+	Dependencies []string `yaml:"dependencies"`
+}
+
+// NewCompiledReleaseBuilder creates a new CompiledReleaseBuilder for packages
+// compiled against the given stemcell image. The stemcell OS and version are
+// the BOSH names of the stemcell the image was built from.
+func NewCompiledReleaseBuilder(stemcell *model.Stemcell, compiledPackagesPath string, ui *termui.UI) (*CompiledReleaseBuilder, error) {
+	if stemcell.OS == "" || stemcell.Version == "" {
+		return nil, fmt.Errorf("The stemcell OS and version are required to create compiled releases")
+	}
+
+	return &CompiledReleaseBuilder{
+		stemcellOS:           stemcell.OS,
+		stemcellVersion:      stemcell.Version,
+		compiledPackagesPath: stemcell.FindCompilationDir(compiledPackagesPath),
+		ui:                   ui,
+	}, nil
+}
+
+// TarballName returns the file name of the compiled release tarball for the
+// given release, following the naming used by BOSH
+func (b *CompiledReleaseBuilder) TarballName(release *model.Release) string {
+	return fmt.Sprintf("%s-%s-%s-%s.tgz", release.Name, release.Version, b.stemcellOS, b.stemcellVersion)
+}
+
+// Build writes the compiled release tarball for the release into the output
+// directory, and returns the path to the tarball. Only the packages compiled
+// already, and the jobs using them, are exported; `fissile build packages`
+// compiles the packages used by the roles, together with their dependencies.
+func (b *CompiledReleaseBuilder) Build(release *model.Release, outputDirectory string) (string, error) {
+	var packages model.Packages
+	var missing []string
+	compiled := make(map[string]bool)
+	for _, pkg := range release.Packages {
+		if err := util.ValidatePath(pkg.GetPackageCompiledDir(b.compiledPackagesPath), true, "compiled package"); err != nil {
+			missing = append(missing, pkg.Name)
+		} else {
+			packages = append(packages, pkg)
+			compiled[pkg.Name] = true
+		}
+	}
+	sort.Strings(missing)
+	if len(packages) == 0 && len(missing) > 0 {
+		return "", fmt.Errorf("Release %s has packages that have not been compiled: %v; run `fissile build packages` first", release.Name, missing)
+	}
+	for _, pkg := range packages {
+		for _, dep := range pkg.Dependencies {
+			if !compiled[dep.Name] {
+				return "", fmt.Errorf("Release %s has package %s compiled, but not its dependency %s", release.Name, pkg.Name, dep.Name)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		b.ui.Printf("Leaving the packages of release %s that have not been compiled, and their jobs, out of it: %s\n",
+			color.CyanString(release.Name), color.YellowString(strings.Join(missing, ", ")))
+	}
+
+	if err := os.MkdirAll(outputDirectory, 0755); err != nil {
+		return "", err
+	}
+
+	stagingDir, err := ioutil.TempDir(outputDirectory, ".compiled-release-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(stagingDir)
+
+	manifest := compiledReleaseManifest{
+		Name:               release.Name,
+		Version:            release.Version,
+		CommitHash:         release.CommitHash,
+		UncommittedChanges: release.UncommittedChanges,
+		CompiledPackages:   []compiledReleasePackage{},
+		Jobs:               []compiledReleaseArchive{},
+	}
+
+	for _, pkg := range packages {
+		archivePath := filepath.Join(stagingDir, "compiled_packages", pkg.Name+".tgz")
+		archiveSHA1, err := writeTargz(archivePath, pkg.GetPackageCompiledDir(b.compiledPackagesPath))
+		if err != nil {
+			return "", fmt.Errorf("Error archiving compiled package %s: %s", pkg.Name, err.Error())
+		}
+
+		dependencies := make([]string, 0, len(pkg.Dependencies))
+		for _, dep := range pkg.Dependencies {
+			dependencies = append(dependencies, dep.Name)
+		}
+
+		manifest.CompiledPackages = append(manifest.CompiledPackages, compiledReleasePackage{
+			Name:         pkg.Name,
+			Version:      pkg.Version,
+			Fingerprint:  pkg.Fingerprint,
+			SHA1:         archiveSHA1,
+			Stemcell:     fmt.Sprintf("%s/%s", b.stemcellOS, b.stemcellVersion),
+			Dependencies: dependencies,
+		})
+	}
+
+	for _, job := range release.Jobs {
+		// Jobs need their packages, which only the unused jobs lack
+		usable := true
+		for _, pkg := range job.Packages {
+			usable = usable && compiled[pkg.Name]
+		}
+		if !usable {
+			continue
+		}
+
+		if err := copyFile(job.Path, filepath.Join(stagingDir, "jobs", job.Name+".tgz")); err != nil {
+			return "", fmt.Errorf("Error copying job %s: %s", job.Name, err.Error())
+		}
+
+		manifest.Jobs = append(manifest.Jobs, compiledReleaseArchive{
+			Name:        job.Name,
+			Version:     job.Version,
+			Fingerprint: job.Fingerprint,
+			SHA1:        job.SHA1,
+		})
+	}
+
+	if len(release.License.Files) > 0 {
+		licenseArchive, err := writeLicenseArchive(filepath.Join(stagingDir, "license.tgz"), release.License.Files)
+		if err != nil {
+			return "", fmt.Errorf("Error archiving license: %s", err.Error())
+		}
+		manifest.License = licenseArchive
+	}
+
+	manifestContents, err := yaml.Marshal(manifest)
+	if err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(filepath.Join(stagingDir, "release.MF"), manifestContents, 0644); err != nil {
+		return "", err
+	}
+
+	tarballPath := filepath.Join(outputDirectory, b.TarballName(release))
+	b.ui.Printf("Writing compiled release %s\n", color.CyanString(tarballPath))
+	if _, err := writeTargz(tarballPath, stagingDir); err != nil {
+		return "", fmt.Errorf("Error writing compiled release %s: %s", tarballPath, err.Error())
+	}
+
+	return tarballPath, nil
+}
+
+// writeTargz archives the contents of the source directory into a new tar.gz
+// file, returning the SHA1 of the archive
+func writeTargz(archivePath, sourceDir string) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(archivePath), 0755); err != nil {
+		return "", err
+	}
+
+	archive, err := os.Create(archivePath)
+	if err != nil {
+		return "", err
+	}
+	defer archive.Close()
+
+	hasher := sha1.New()
+	if err := util.WriteDirToTargz(io.MultiWriter(archive, hasher), sourceDir); err != nil {
+		return "", err
+	}
+	if err := archive.Close(); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// writeLicenseArchive writes the license files of a release into a
+// license.tgz, and returns its description for release.MF
+func writeLicenseArchive(archivePath string, files map[string][]byte) (*compiledReleaseArchive, error) {
+	archive, err := os.Create(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	hasher := sha1.New()
+	contentHasher := sha1.New()
+	gzipWriter := gzip.NewWriter(io.MultiWriter(archive, hasher))
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		contentHasher.Write(files[name])
+		if err := util.WriteToTarStream(tarWriter, files[name], tar.Header{Name: filepath.Join(".", name)}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return nil, err
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return nil, err
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+
+	fingerprint := hex.EncodeToString(contentHasher.Sum(nil))
+	return &compiledReleaseArchive{
+		Version:     fingerprint,
+		Fingerprint: fingerprint,
+		SHA1:        hex.EncodeToString(hasher.Sum(nil)),
+	}, nil
+}
+
+func copyFile(sourcePath, targetPath string) error {
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+		return err
+	}
+
+	source, err := os.Open(sourcePath)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	target, err := os.Create(targetPath)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(target, source); err != nil {
+		target.Close()
+		return err
+	}
+
+	return target.Close()
+}
@@ -0,0 +1,157 @@
+package builder
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SUSE/fissile/model"
+
+	"github.com/SUSE/termui"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompiledReleaseBuild(t *testing.T) {
+	assert := assert.New(t)
+
+	ui := termui.New(
+		&bytes.Buffer{},
+		ioutil.Discard,
+		nil,
+	)
+
+	workDir, err := os.Getwd()
+	require.NoError(t, err)
+
+	releasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	releasePathCache := filepath.Join(releasePath, "bosh-cache")
+	release, err := model.NewDevRelease(releasePath, "", "", releasePathCache)
+	require.NoError(t, err)
+
+	compiledPackagesDir := filepath.Join(workDir, "../test-assets/tor-boshrelease-fake-compiled")
+	outputDir, err := ioutil.TempDir("", "fissile-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(outputDir)
+
+	compiledReleaseBuilder, err := NewCompiledReleaseBuilder(&model.Stemcell{
+		ImageName: defaultDockerTestImage,
+		OS:        "ubuntu-trusty",
+		Version:   "3586.40",
+	}, compiledPackagesDir, ui)
+	require.NoError(t, err)
+
+	tarballPath, err := compiledReleaseBuilder.Build(release, outputDir)
+	require.NoError(t, err)
+	assert.Equal(filepath.Join(outputDir, "tor-0.3.5+dev.5-ubuntu-trusty-3586.40.tgz"), tarballPath)
+
+	// The tarball must be loadable as a compiled release again
+	compiledRelease, err := model.NewReleaseFromTarball(tarballPath, filepath.Join(outputDir, "cache"))
+	require.NoError(t, err)
+
+	assert.Equal(release.Name, compiledRelease.Name)
+	assert.Equal(release.Version, compiledRelease.Version)
+	assert.True(compiledRelease.IsCompiled())
+	assert.Len(compiledRelease.Packages, len(release.Packages))
+	assert.Len(compiledRelease.Jobs, len(release.Jobs))
+	assert.Equal(release.License.Files, compiledRelease.License.Files)
+
+	for _, pkg := range release.Packages {
+		compiledPkg, err := compiledRelease.LookupPackage(pkg.Name)
+		if assert.NoError(err) {
+			assert.Equal(pkg.Fingerprint, compiledPkg.Fingerprint)
+			assert.Equal("ubuntu-trusty/3586.40", compiledPkg.Stemcell)
+			assert.Len(compiledPkg.Dependencies, len(pkg.Dependencies))
+		}
+	}
+}
+
+func TestCompiledReleaseBuildUnusedPackages(t *testing.T) {
+	assert := assert.New(t)
+
+	ui := termui.New(
+		&bytes.Buffer{},
+		ioutil.Discard,
+		nil,
+	)
+
+	workDir, err := os.Getwd()
+	require.NoError(t, err)
+
+	releasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	releasePathCache := filepath.Join(releasePath, "bosh-cache")
+	release, err := model.NewDevRelease(releasePath, "", "", releasePathCache)
+	require.NoError(t, err)
+
+	outputDir, err := ioutil.TempDir("", "fissile-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(outputDir)
+
+	// Only libevent is used by the roles, and was compiled
+	libevent, err := release.LookupPackage("libevent")
+	require.NoError(t, err)
+	stemcell := &model.Stemcell{
+		ImageName: defaultDockerTestImage,
+		OS:        "ubuntu-trusty",
+		Version:   "3586.40",
+	}
+	compiledPackagesDir := filepath.Join(outputDir, "compilation")
+	compiledDir := libevent.GetPackageCompiledDir(stemcell.CompilationDir(compiledPackagesDir))
+	require.NoError(t, os.MkdirAll(compiledDir, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(compiledDir, "libevent.so"), []byte("libevent"), 0644))
+
+	compiledReleaseBuilder, err := NewCompiledReleaseBuilder(stemcell, compiledPackagesDir, ui)
+	require.NoError(t, err)
+
+	tarballPath, err := compiledReleaseBuilder.Build(release, outputDir)
+	require.NoError(t, err)
+
+	compiledRelease, err := model.NewReleaseFromTarball(tarballPath, filepath.Join(outputDir, "cache"))
+	require.NoError(t, err)
+	if assert.Len(compiledRelease.Packages, 1) {
+		assert.Equal("libevent", compiledRelease.Packages[0].Name)
+		assert.Equal(libevent.Fingerprint, compiledRelease.Packages[0].Fingerprint)
+	}
+	for _, job := range compiledRelease.Jobs {
+		assert.NotEqual("tor", job.Name, "Jobs of packages left out are left out")
+	}
+}
+
+func TestCompiledReleaseBuildMissingPackages(t *testing.T) {
+	assert := assert.New(t)
+
+	ui := termui.New(
+		&bytes.Buffer{},
+		ioutil.Discard,
+		nil,
+	)
+
+	workDir, err := os.Getwd()
+	require.NoError(t, err)
+
+	releasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	releasePathCache := filepath.Join(releasePath, "bosh-cache")
+	release, err := model.NewDevRelease(releasePath, "", "", releasePathCache)
+	require.NoError(t, err)
+
+	outputDir, err := ioutil.TempDir("", "fissile-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(outputDir)
+
+	compiledReleaseBuilder, err := NewCompiledReleaseBuilder(&model.Stemcell{
+		ImageName: defaultDockerTestImage,
+		OS:        "ubuntu-trusty",
+		Version:   "3586.40",
+	}, outputDir, ui)
+	require.NoError(t, err)
+
+	_, err = compiledReleaseBuilder.Build(release, outputDir)
+	if assert.Error(err) {
+		assert.Contains(err.Error(), "have not been compiled")
+	}
+
+	_, err = NewCompiledReleaseBuilder(&model.Stemcell{ImageName: defaultDockerTestImage}, outputDir, ui)
+	assert.Error(err)
+}
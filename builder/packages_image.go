@@ -57,16 +57,13 @@ func NewPackagesImageBuilder(repository, stemcellImageName, stemcellImageID, com
 		stemcellImageID = stemcellImage.ID
 	}
 
-	hasher := sha1.New()
-	if _, err := hasher.Write([]byte(stemcellImageName)); err != nil {
-		return nil, err
-	}
+	stemcell := model.Stemcell{ImageName: stemcellImageName, ImageID: stemcellImageID}
 
 	return &PackagesImageBuilder{
 		repository:           repository,
 		stemcellImageID:      stemcellImageID,
 		stemcellImageName:    stemcellImageName,
-		compiledPackagesPath: filepath.Join(compiledPackagesPath, hex.EncodeToString(hasher.Sum(nil))),
+		compiledPackagesPath: stemcell.FindCompilationDir(compiledPackagesPath),
 		targetPath:           targetPath,
 		fissileVersion:       fissileVersion,
 		ui:                   ui,
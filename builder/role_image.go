@@ -10,12 +10,12 @@ import (
 	"path/filepath"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/SUSE/fissile/docker"
 	"github.com/SUSE/fissile/model"
 	"github.com/SUSE/fissile/scripts/dockerfiles"
 	"github.com/SUSE/fissile/util"
-	"github.com/SUSE/stampy"
 	"github.com/SUSE/termui"
 
 	"github.com/fatih/color"
@@ -45,32 +45,38 @@ type RoleImageBuilder struct {
 	repository           string
 	compiledPackagesPath string
 	targetPath           string
-	metricsPath          string
 	tagExtra             string
 	fissileVersion       string
+	timings              *util.TimingCollector
 	lightOpinionsPath    string
 	darkOpinionsPath     string
 	ui                   *termui.UI
 	grapher              util.ModelGrapher
+	events               util.EventSink
 }
 
 // NewRoleImageBuilder creates a new RoleImageBuilder
-func NewRoleImageBuilder(repository, compiledPackagesPath, targetPath, lightOpinionsPath, darkOpinionsPath, metricsPath, tagExtra, fissileVersion string, ui *termui.UI, grapher util.ModelGrapher) (*RoleImageBuilder, error) {
+func NewRoleImageBuilder(repository, compiledPackagesPath, targetPath, lightOpinionsPath, darkOpinionsPath, tagExtra, fissileVersion string, timings *util.TimingCollector, ui *termui.UI, grapher util.ModelGrapher, events util.EventSink) (*RoleImageBuilder, error) {
 	if err := os.MkdirAll(targetPath, 0755); err != nil {
 		return nil, err
 	}
 
+	if events == nil {
+		events = util.NewHumanEventSink(ui)
+	}
+
 	return &RoleImageBuilder{
 		repository:           repository,
 		compiledPackagesPath: compiledPackagesPath,
 		targetPath:           targetPath,
-		metricsPath:          metricsPath,
 		fissileVersion:       fissileVersion,
+		timings:              timings,
 		tagExtra:             tagExtra,
 		lightOpinionsPath:    lightOpinionsPath,
 		darkOpinionsPath:     darkOpinionsPath,
 		ui:                   ui,
 		grapher:              grapher,
+		events:               events,
 	}, nil
 }
 
@@ -307,7 +313,7 @@ func (r *RoleImageBuilder) generateJobsConfig(role *model.Role) ([]byte, error)
 			files[src] = dest
 		}
 
-		if role.Type != "bosh-task" {
+		if !role.RunsToCompletion() {
 			src := fmt.Sprintf("/var/vcap/jobs-src/%s/monit", roleJob.Name)
 			dest := fmt.Sprintf("/var/vcap/monit/%s.monitrc", roleJob.Name)
 			files[src] = dest
@@ -356,6 +362,7 @@ type roleBuildJob struct {
 	builder         *RoleImageBuilder
 	ui              *termui.UI
 	grapher         util.ModelGrapher
+	events          util.EventSink
 	force           bool
 	noBuild         bool
 	dockerManager   dockerImageBuilder
@@ -376,7 +383,20 @@ func (j roleBuildJob) Run() {
 	default:
 	}
 
-	j.resultsCh <- func() error {
+	var roleImageName string
+	var outputPath string
+	var start time.Time
+	skipped := false
+
+	skip := func(event util.Event) {
+		event.Type = util.EventRoleImageBuildSkipped
+		event.Role = j.role.Name
+		event.Image = roleImageName
+		j.events.Emit(event)
+		skipped = true
+	}
+
+	err := func() error {
 		opinions, err := model.NewOpinions(j.builder.lightOpinionsPath, j.builder.darkOpinionsPath)
 		if err != nil {
 			return err
@@ -391,9 +411,6 @@ func (j roleBuildJob) Run() {
 			_ = j.grapher.GraphEdge(j.baseImageName, devVersion, nil)
 		}
 
-		var roleImageName string
-		var outputPath string
-
 		if j.outputDirectory == "" {
 			roleImageName = GetRoleDevImageName(j.registry, j.organization, j.repository, j.role, devVersion)
 			outputPath = fmt.Sprintf("%s.tar", roleImageName)
@@ -407,7 +424,7 @@ func (j roleBuildJob) Run() {
 				if hasImage, err := j.dockerManager.HasImage(roleImageName); err != nil {
 					return err
 				} else if hasImage {
-					j.ui.Printf("Skipping build of role image %s because it exists\n", color.YellowString(j.role.Name))
+					skip(util.Event{Message: "it exists"})
 					return nil
 				}
 			} else {
@@ -416,7 +433,7 @@ func (j roleBuildJob) Run() {
 					if info.IsDir() {
 						return fmt.Errorf("Output path %s exists but is a directory", outputPath)
 					}
-					j.ui.Printf("Skipping build of role tarball %s because it exists\n", color.YellowString(outputPath))
+					skip(util.Event{Path: outputPath, Message: "it exists"})
 					return nil
 				}
 				if !os.IsNotExist(err) {
@@ -425,23 +442,17 @@ func (j roleBuildJob) Run() {
 			}
 		}
 
-		if j.builder.metricsPath != "" {
-			seriesName := fmt.Sprintf("create-role-images::%s", roleImageName)
-
-			stampy.Stamp(j.builder.metricsPath, "fissile", seriesName, "start")
-			defer stampy.Stamp(j.builder.metricsPath, "fissile", seriesName, "done")
-		}
-
 		j.ui.Printf("Creating Dockerfile for role %s ...\n", color.YellowString(j.role.Name))
 		dockerPopulator := j.builder.NewDockerPopulator(j.role, j.baseImageName)
 
 		if j.noBuild {
-			j.ui.Printf("Skipping build of role image %s because of flag\n", color.YellowString(j.role.Name))
+			skip(util.Event{Message: "of flag"})
 			return nil
 		}
 
+		start = time.Now()
 		if j.outputDirectory == "" {
-			j.ui.Printf("Building docker image of %s...\n", color.YellowString(j.role.Name))
+			j.events.Emit(util.Event{Type: util.EventRoleImageBuildStart, Role: j.role.Name, Image: roleImageName})
 
 			log := new(bytes.Buffer)
 			stdoutWriter := docker.NewFormattingWriter(
@@ -455,7 +466,7 @@ func (j roleBuildJob) Run() {
 				return fmt.Errorf("Error building image: %s", err.Error())
 			}
 		} else {
-			j.ui.Printf("Building tarball of %s...\n", color.YellowString(j.role.Name))
+			j.events.Emit(util.Event{Type: util.EventRoleImageBuildStart, Role: j.role.Name, Image: roleImageName, Path: outputPath})
 
 			tarFile, err := os.Create(outputPath)
 			if err != nil {
@@ -475,6 +486,33 @@ func (j roleBuildJob) Run() {
 		}
 		return nil
 	}()
+
+	if !skipped {
+		event := util.Event{
+			Type:  util.EventRoleImageBuildDone,
+			Role:  j.role.Name,
+			Image: roleImageName,
+			Err:   err,
+		}
+		if j.outputDirectory != "" {
+			event.Path = outputPath
+		}
+		if !start.IsZero() {
+			event.Duration = time.Since(start)
+			j.builder.timings.Record(util.Timing{
+				Kind:     util.TimingRoleImage,
+				Name:     j.role.Name,
+				Duration: event.Duration,
+				Failed:   err != nil,
+			})
+		}
+		if err != nil {
+			event.Type = util.EventRoleImageBuildFailed
+		}
+		j.events.Emit(event)
+	}
+
+	j.resultsCh <- err
 }
 
 // BuildRoleImages triggers the building of the role docker images in parallel
@@ -505,6 +543,7 @@ func (r *RoleImageBuilder) BuildRoleImages(roles model.Roles, registry, organiza
 			builder:         r,
 			ui:              r.ui,
 			grapher:         r.grapher,
+			events:          r.events,
 			force:           force,
 			noBuild:         noBuild,
 			dockerManager:   dockerManager,
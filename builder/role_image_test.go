@@ -9,15 +9,16 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
 	"sync"
 	"testing"
 
 	"github.com/SUSE/fissile/model"
+	"github.com/SUSE/fissile/util"
 	"github.com/SUSE/termui"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGenerateRoleImageDockerfile(t *testing.T) {
@@ -49,7 +50,7 @@ func TestGenerateRoleImageDockerfile(t *testing.T) {
 	torOpinionsDir := filepath.Join(workDir, "../test-assets/tor-opinions")
 	lightOpinionsPath := filepath.Join(torOpinionsDir, "opinions.yml")
 	darkOpinionsPath := filepath.Join(torOpinionsDir, "dark-opinions.yml")
-	roleImageBuilder, err := NewRoleImageBuilder("foo", compiledPackagesDir, targetPath, lightOpinionsPath, darkOpinionsPath, "", "deadbeef", "6.28.30", ui, nil)
+	roleImageBuilder, err := NewRoleImageBuilder("foo", compiledPackagesDir, targetPath, lightOpinionsPath, darkOpinionsPath, "deadbeef", "6.28.30", nil, ui, nil, nil)
 	assert.NoError(err)
 
 	var dockerfileContents bytes.Buffer
@@ -102,7 +103,7 @@ func TestGenerateRoleImageRunScript(t *testing.T) {
 	lightOpinionsPath := filepath.Join(torOpinionsDir, "opinions.yml")
 	darkOpinionsPath := filepath.Join(torOpinionsDir, "dark-opinions.yml")
 
-	roleImageBuilder, err := NewRoleImageBuilder("foo", compiledPackagesDir, targetPath, lightOpinionsPath, darkOpinionsPath, "", "deadbeef", "6.28.30", ui, nil)
+	roleImageBuilder, err := NewRoleImageBuilder("foo", compiledPackagesDir, targetPath, lightOpinionsPath, darkOpinionsPath, "deadbeef", "6.28.30", nil, ui, nil, nil)
 	assert.NoError(err)
 
 	runScriptContents, err := roleImageBuilder.generateRunScript(roleManifest.Roles[0], "run.sh")
@@ -173,7 +174,7 @@ func TestGenerateRoleImageJobsConfig(t *testing.T) {
 	torOpinionsDir := filepath.Join(workDir, "../test-assets/tor-opinions")
 	lightOpinionsPath := filepath.Join(torOpinionsDir, "opinions.yml")
 	darkOpinionsPath := filepath.Join(torOpinionsDir, "dark-opinions.yml")
-	roleImageBuilder, err := NewRoleImageBuilder("foo", compiledPackagesDir, targetPath, lightOpinionsPath, darkOpinionsPath, "", "deadbeef", "6.28.30", ui, nil)
+	roleImageBuilder, err := NewRoleImageBuilder("foo", compiledPackagesDir, targetPath, lightOpinionsPath, darkOpinionsPath, "deadbeef", "6.28.30", nil, ui, nil, nil)
 	assert.NoError(err)
 
 	jobsConfigContents, err := roleImageBuilder.generateJobsConfig(roleManifest.Roles[0])
@@ -223,7 +224,7 @@ func TestGenerateRoleImageDockerfileDir(t *testing.T) {
 	lightOpinionsPath := filepath.Join(torOpinionsDir, "opinions.yml")
 	darkOpinionsPath := filepath.Join(torOpinionsDir, "dark-opinions.yml")
 
-	roleImageBuilder, err := NewRoleImageBuilder("foo", compiledPackagesDir, targetPath, lightOpinionsPath, darkOpinionsPath, "", "deadbeef", "6.28.30", ui, nil)
+	roleImageBuilder, err := NewRoleImageBuilder("foo", compiledPackagesDir, targetPath, lightOpinionsPath, darkOpinionsPath, "deadbeef", "6.28.30", nil, ui, nil, nil)
 	assert.NoError(err)
 
 	torPkg := getPackage(roleManifest.Roles, "myrole", "tor", "tor")
@@ -450,11 +451,12 @@ func TestBuildRoleImages(t *testing.T) {
 		targetPath,
 		lightOpinionsPath,
 		darkOpinionsPath,
-		"",
 		"deadbeef",
 		"6.28.30",
+		nil,
 		ui,
 		nil,
+		nil,
 	)
 	assert.NoError(err)
 
@@ -510,6 +512,8 @@ func TestBuildRoleImages(t *testing.T) {
 	assert.Contains(err.Error(), "count", "Building the image should have failed due to invalid worker count")
 
 	// Check that failing the first job will not run the second job
+	events := &bytes.Buffer{}
+	roleImageBuilder.events = util.NewJSONEventSink(events)
 	hasRunSecondJob := false
 	mockBuilder.callback = func(name string) error {
 		if strings.Contains(name, "-myrole:") {
@@ -539,6 +543,23 @@ func TestBuildRoleImages(t *testing.T) {
 	}
 	assert.False(hasRunSecondJob, "Second job should not have run")
 
+	var received []map[string]interface{}
+	decoder := json.NewDecoder(events)
+	for decoder.More() {
+		var event map[string]interface{}
+		require.NoError(t, decoder.Decode(&event))
+		received = append(received, event)
+	}
+	if assert.Len(received, 2) {
+		assert.Equal("role-image-build-start", received[0]["event"])
+		assert.Equal("myrole", received[0]["role"])
+		assert.Equal("role-image-build-failed", received[1]["event"])
+		assert.Equal("myrole", received[1]["role"])
+		assert.Contains(received[1]["image"], "test-repository-myrole:")
+		assert.Contains(received[1]["error"], "Deliberate failure")
+	}
+	roleImageBuilder.events = util.NewHumanEventSink(ui)
+
 	// Check that we do not attempt to rebuild images
 	mockBuilder.hasImage = true
 	var buildersRan []string
@@ -563,13 +584,9 @@ func TestBuildRoleImages(t *testing.T) {
 	assert.NoError(err)
 	assert.Empty(buildersRan, "should not have ran any builders")
 
-	// Check that we write timestamps to the metrics file
-	file, err := ioutil.TempFile("", "metrics")
-	assert.NoError(err)
-
-	metrics := file.Name()
-	defer os.Remove(metrics)
-	roleImageBuilder.metricsPath = metrics
+	// Check that we record the time spent building each image
+	timings := util.NewTimingCollector("build-images")
+	roleImageBuilder.timings = timings
 
 	err = os.RemoveAll(targetPath)
 	assert.NoError(err, "Failed to remove target")
@@ -596,14 +613,13 @@ func TestBuildRoleImages(t *testing.T) {
 	)
 	assert.NoError(err)
 
-	expected := `.*,fissile,create-role-images::test-registry.com:9000/test-organization/test-repository-myrole:[a-z0-9]{40},start
-.*,fissile,create-role-images::test-registry.com:9000/test-organization/test-repository-myrole:[a-z0-9]{40},done
-.*,fissile,create-role-images::test-registry.com:9000/test-organization/test-repository-foorole:[a-z0-9]{40},start
-.*,fissile,create-role-images::test-registry.com:9000/test-organization/test-repository-foorole:[a-z0-9]{40},done`
-
-	contents, err := ioutil.ReadFile(metrics)
-	assert.NoError(err)
-	assert.Regexp(regexp.MustCompile(expected), string(contents))
+	var timedRoles []string
+	for _, timing := range timings.Timings() {
+		assert.Equal(util.TimingRoleImage, timing.Kind)
+		assert.False(timing.Failed)
+		timedRoles = append(timedRoles, timing.Name)
+	}
+	assert.ElementsMatch([]string{"myrole", "foorole"}, timedRoles)
 }
 
 func TestGetRoleDevImageName(t *testing.T) {
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// buildCompiledReleaseCmd represents the compiled-release command
+var buildCompiledReleaseCmd = &cobra.Command{
+	Use:   "compiled-release",
+	Short: "Exports compiled BOSH packages as BOSH compiled release tarballs.",
+	Long: `
+This command takes the packages compiled by ` + "`fissile build packages`" + ` and
+writes one BOSH compiled release tarball per release, named
+` + "`<RELEASE_NAME>-<RELEASE_VERSION>-<STEMCELL_OS>-<STEMCELL_VERSION>.tgz`" + `.
+These can be used by BOSH deployments, or as releases for fissile itself.
+
+Only the packages compiled already, which are the packages used by the roles and
+their dependencies, are exported, together with the jobs using them. The stemcell OS and version recorded in the
+tarballs are read from the ` + "`stemcell.os`" + ` and
+` + "`stemcell.version`" + ` labels of the stemcell image.
+
+The tarballs are written to ` + "`<work-dir>/compiled-releases`" + ` unless
+` + "`--output-directory`" + ` is given.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		flagBuildCompiledReleaseOnlyReleases := buildCompiledReleaseViper.GetString("only-releases")
+		flagBuildCompiledReleaseStemcell := buildCompiledReleaseViper.GetString("stemcell")
+		flagBuildCompiledReleaseOutputDirectory := buildCompiledReleaseViper.GetString("output-directory")
+
+		if flagBuildCompiledReleaseOutputDirectory == "" {
+			flagBuildCompiledReleaseOutputDirectory = filepath.Join(flagWorkDir, "compiled-releases")
+		}
+		if err := absolutePaths(&flagBuildCompiledReleaseOutputDirectory); err != nil {
+			return err
+		}
+
+		err := fissile.LoadReleases(
+			flagRelease,
+			flagReleaseName,
+			flagReleaseVersion,
+			flagCacheDir,
+		)
+		if err != nil {
+			return err
+		}
+
+		return fissile.GenerateCompiledReleases(
+			flagBuildCompiledReleaseStemcell,
+			workPathCompilationDir,
+			flagBuildCompiledReleaseOutputDirectory,
+			strings.FieldsFunc(flagBuildCompiledReleaseOnlyReleases, func(r rune) bool { return r == ',' }),
+		)
+	},
+}
+
+var buildCompiledReleaseViper = viper.New()
+
+func init() {
+	initViper(buildCompiledReleaseViper)
+
+	buildCmd.AddCommand(buildCompiledReleaseCmd)
+
+	buildCompiledReleaseCmd.PersistentFlags().StringP(
+		"only-releases",
+		"",
+		"",
+		"Export only the given release names; comma separated.",
+	)
+
+	buildCompiledReleaseCmd.PersistentFlags().StringP(
+		"stemcell",
+		"s",
+		"",
+		"The stemcell the packages were compiled with",
+	)
+
+	buildCompiledReleaseCmd.PersistentFlags().StringP(
+		"output-directory",
+		"O",
+		"",
+		"Directory to write the compiled release tarballs to",
+	)
+
+	buildCompiledReleaseViper.BindPFlags(buildCompiledReleaseCmd.PersistentFlags())
+}
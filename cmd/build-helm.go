@@ -14,7 +14,14 @@ var (
 	flagBuildHelmUseMemoryLimits bool
 	flagBuildHelmUseCPULimits    bool
 	flagBuildHelmTagExtra        string
+	flagBuildHelmKubeVersion     string
+	flagBuildHelmNetworkPolicies bool
 	flagBuildHelmAuthType        string
+	flagBuildHelmChartName       string
+	flagBuildHelmChartVersion    string
+	flagBuildHelmChartDesc       string
+	flagBuildHelmChartIcon       string
+	flagBuildHelmChartMaintainer []string
 )
 
 // buildHelmCmd represents the helm command
@@ -29,8 +36,15 @@ var buildHelmCmd = &cobra.Command{
 		flagBuildHelmUseMemoryLimits = buildHelmViper.GetBool("use-memory-limits")
 		flagBuildHelmUseCPULimits = buildHelmViper.GetBool("use-cpu-limits")
 		flagBuildHelmTagExtra = buildHelmViper.GetString("tag-extra")
+		flagBuildHelmKubeVersion = buildHelmViper.GetString("kube-version")
+		flagBuildHelmNetworkPolicies = buildHelmViper.GetBool("network-policies")
 		flagBuildOutputGraph = buildViper.GetString("output-graph")
 		flagBuildHelmAuthType = buildHelmViper.GetString("auth-type")
+		flagBuildHelmChartName = buildHelmViper.GetString("chart-name")
+		flagBuildHelmChartVersion = buildHelmViper.GetString("chart-version")
+		flagBuildHelmChartDesc = buildHelmViper.GetString("chart-description")
+		flagBuildHelmChartIcon = buildHelmViper.GetString("chart-icon")
+		flagBuildHelmChartMaintainer = splitNonEmpty(buildHelmViper.GetString("chart-maintainers"), ",")
 
 		err := fissile.LoadReleases(
 			flagRelease,
@@ -42,6 +56,11 @@ var buildHelmCmd = &cobra.Command{
 			return err
 		}
 
+		kubeVersion, err := kube.ParseClusterVersion(flagBuildHelmKubeVersion)
+		if err != nil {
+			return err
+		}
+
 		opinions, err := model.NewOpinions(
 			flagLightOpinions,
 			flagDarkOpinions,
@@ -51,19 +70,26 @@ var buildHelmCmd = &cobra.Command{
 		}
 
 		settings := kube.ExportSettings{
-			OutputDir:       flagBuildHelmOutputDir,
-			Registry:        flagDockerRegistry,
-			Username:        flagDockerUsername,
-			Password:        flagDockerPassword,
-			Organization:    flagDockerOrganization,
-			Repository:      flagRepository,
-			UseMemoryLimits: flagBuildHelmUseMemoryLimits,
-			UseCPULimits:    flagBuildHelmUseCPULimits,
-			FissileVersion:  fissile.Version,
-			Opinions:        opinions,
-			CreateHelmChart: true,
-			TagExtra:        flagBuildHelmTagExtra,
-			AuthType:        flagBuildHelmAuthType,
+			OutputDir:        flagBuildHelmOutputDir,
+			Registry:         flagDockerRegistry,
+			Username:         flagDockerUsername,
+			Password:         flagDockerPassword,
+			Organization:     flagDockerOrganization,
+			Repository:       flagRepository,
+			UseMemoryLimits:  flagBuildHelmUseMemoryLimits,
+			UseCPULimits:     flagBuildHelmUseCPULimits,
+			FissileVersion:   fissile.Version,
+			Opinions:         opinions,
+			CreateHelmChart:  true,
+			TagExtra:         flagBuildHelmTagExtra,
+			KubeVersion:      kubeVersion,
+			NetworkPolicies:  flagBuildHelmNetworkPolicies,
+			AuthType:         flagBuildHelmAuthType,
+			ChartName:        flagBuildHelmChartName,
+			ChartVersion:     flagBuildHelmChartVersion,
+			ChartDescription: flagBuildHelmChartDesc,
+			ChartIcon:        flagBuildHelmChartIcon,
+			ChartMaintainers: flagBuildHelmChartMaintainer,
 		}
 
 		if flagBuildOutputGraph != "" {
@@ -121,6 +147,13 @@ func init() {
 		"Additional information to use in computing the image tags",
 	)
 
+	buildHelmCmd.PersistentFlags().StringP(
+		"kube-version",
+		"",
+		"",
+		"The Kubernetes version (e.g. 1.9) to generate the chart for, selecting the API versions of resources; defaults to choosing by the version of the cluster at install time",
+	)
+
 	buildHelmCmd.PersistentFlags().BoolP(
 		"use-secrets-generator",
 		"",
@@ -135,5 +168,47 @@ func init() {
 		"Sets the Kubernetes auth type",
 	)
 
+	buildHelmCmd.PersistentFlags().BoolP(
+		"network-policies",
+		"",
+		false,
+		"Generate a NetworkPolicy for each role, allowing traffic only on its exposed ports from the roles consuming its links, and from anywhere on public ports",
+	)
+
+	buildHelmCmd.PersistentFlags().StringP(
+		"chart-name",
+		"",
+		"",
+		"The name of the chart; defaults to the name of the output directory",
+	)
+
+	buildHelmCmd.PersistentFlags().StringP(
+		"chart-version",
+		"",
+		"0.1.0",
+		"The version of the chart",
+	)
+
+	buildHelmCmd.PersistentFlags().StringP(
+		"chart-description",
+		"",
+		"",
+		"A description of the chart",
+	)
+
+	buildHelmCmd.PersistentFlags().StringP(
+		"chart-icon",
+		"",
+		"",
+		"The URL of an icon for the chart",
+	)
+
+	buildHelmCmd.PersistentFlags().StringP(
+		"chart-maintainers",
+		"",
+		"",
+		"The maintainers of the chart, as a comma-separated list of <name> or <name> <<email>>",
+	)
+
 	buildHelmViper.BindPFlags(buildHelmCmd.PersistentFlags())
 }
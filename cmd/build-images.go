@@ -98,7 +98,7 @@ from other specs.  At most one is allowed.
 			flagRepository,
 			flagBuildImagesStemcell,
 			flagBuildImagesStemcellID,
-			flagMetrics,
+			timingReports(),
 			flagBuildImagesNoBuild,
 			flagBuildImagesForce,
 			flagBuildImagesTagExtra,
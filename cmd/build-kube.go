@@ -14,6 +14,8 @@ var (
 	flagBuildKubeUseMemoryLimits bool
 	flagBuildKubeUseCPULimits    bool
 	flagBuildKubeTagExtra        string
+	flagBuildKubeKubeVersion     string
+	flagBuildKubeNetworkPolicies bool
 )
 
 // buildKubeCmd represents the kube command
@@ -28,6 +30,8 @@ var buildKubeCmd = &cobra.Command{
 		flagBuildKubeUseMemoryLimits = buildKubeViper.GetBool("use-memory-limits")
 		flagBuildKubeUseCPULimits = buildKubeViper.GetBool("use-cpu-limits")
 		flagBuildKubeTagExtra = buildKubeViper.GetString("tag-extra")
+		flagBuildKubeKubeVersion = buildKubeViper.GetString("kube-version")
+		flagBuildKubeNetworkPolicies = buildKubeViper.GetBool("network-policies")
 		flagBuildOutputGraph = buildViper.GetString("output-graph")
 
 		err := fissile.LoadReleases(
@@ -40,6 +44,11 @@ var buildKubeCmd = &cobra.Command{
 			return err
 		}
 
+		kubeVersion, err := kube.ParseClusterVersion(flagBuildKubeKubeVersion)
+		if err != nil {
+			return err
+		}
+
 		opinions, err := model.NewOpinions(
 			flagLightOpinions,
 			flagDarkOpinions,
@@ -61,6 +70,8 @@ var buildKubeCmd = &cobra.Command{
 			Opinions:        opinions,
 			CreateHelmChart: false,
 			TagExtra:        flagBuildKubeTagExtra,
+			KubeVersion:     kubeVersion,
+			NetworkPolicies: flagBuildKubeNetworkPolicies,
 		}
 
 		if flagBuildOutputGraph != "" {
@@ -118,5 +129,19 @@ func init() {
 		"Additional information to use in computing the image tags",
 	)
 
+	buildKubeCmd.PersistentFlags().StringP(
+		"kube-version",
+		"",
+		"",
+		"The Kubernetes version (e.g. 1.9) to generate configurations for, selecting the API versions of resources; defaults to the current API versions",
+	)
+
+	buildKubeCmd.PersistentFlags().BoolP(
+		"network-policies",
+		"",
+		false,
+		"Generate a NetworkPolicy for each role, allowing traffic only on its exposed ports from the roles consuming its links, and from anywhere on public ports",
+	)
+
 	buildKubeViper.BindPFlags(buildKubeCmd.PersistentFlags())
 }
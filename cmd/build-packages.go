@@ -1,11 +1,10 @@
 package cmd
 
 import (
-	"crypto/sha1"
-	"encoding/hex"
-	"path/filepath"
 	"strings"
 
+	"github.com/SUSE/fissile/compilator"
+
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -24,18 +23,56 @@ All containers are removed, whether compilation is successful or not. However, i
 the compilation is interrupted during compilation (e.g. sending SIGINT), containers
 will most likely be left behind.
 
-Compiled packages are stored in ` + "`<work-dir>/compilation`" + `. Fissile uses the
-package's fingerprint as part of the directory structure. This means that if the
-same package (with the same version) is used by multiple releases, it will only be
-compiled once.
+Compiled packages are stored in ` + "`<work-dir>/compilation/<stemcell-image-id>`" + `.
+Fissile uses the package's fingerprint as part of the directory structure. This means
+that if the same package (with the same version) is used by multiple releases, it will
+only be compiled once; switching to a different stemcell image compiles it again.
+Packages compiled by older fissile versions (stored by stemcell image name) are moved
+to the new location the first time the stemcell is used, if they were compiled after
+the stemcell image was built.  Otherwise they may have been compiled against another
+image of that name, and are moved aside instead; ` + "`fissile show cache`" + ` reports them
+as unknown, and they are never reused.
+
+A shared package cache can be configured with ` + "`--package-cache`" + `; it is either a
+directory (e.g. on a network file system) or an HTTP(S) URL, such as a bucket of an
+S3-compatible object store.  Packages found in the cache (by fingerprint and stemcell)
+are downloaded instead of compiled, and newly compiled packages are uploaded to it.
+Requests are signed as S3 requests when ` + "`--package-cache-access-key`" + ` is set.
+Packages the cache denies access to are compiled with a warning, as S3 denies access
+to missing packages unless the credentials allow listing the bucket.
+
+Packages are compiled in the order of the longest chain of packages depending on
+them, using the compile durations of earlier builds recorded in
+` + "`<work-dir>/compilation/compile-durations.yml`" + `.  Use ` + "`fissile show compile-plan`" + `
+to see the schedule.
+
+The output of compiling each package is written to
+` + "`<work-dir>/logs/<release>/<package>-<fingerprint>.log`" + `; when a package fails to
+compile, the error points to its log.  Use ` + "`fissile show compile-log <package>`" + `
+to view the log of a package.
+
+By default, compilation stops at the first package that fails to compile.  With
+` + "`--keep-going`" + `, all packages that don't depend on a failed package are still
+compiled, and the failed packages, and those skipped because of them, are listed
+at the end.  As compiled packages are kept, running the command again only
+compiles what is left.
+
+Packages of compiled releases are not compiled again; they are unpacked into the
+compilation directory, provided the stemcell image's ` + "`stemcell.os`" + ` and
+` + "`stemcell.version`" + ` labels match the stemcell they were compiled against.
 `,
 	RunE: func(cmd *cobra.Command, args []string) error {
 
 		flagBuildPackagesRoles := buildPackagesViper.GetString("roles")
 		flagBuildPackagesOnlyReleases := buildPackagesViper.GetString("only-releases")
 		flagBuildPackagesWithoutDocker := buildPackagesViper.GetBool("without-docker")
+		flagBuildPackagesKeepGoing := buildPackagesViper.GetBool("keep-going")
 		flagBuildPackagesDockerNetworkMode := buildPackagesViper.GetString("docker-network-mode")
 		flagBuildPackagesStemcell := buildPackagesViper.GetString("stemcell")
+		flagBuildPackagesPackageCache := buildPackagesViper.GetString("package-cache")
+		flagBuildPackagesPackageCacheAccessKey := buildPackagesViper.GetString("package-cache-access-key")
+		flagBuildPackagesPackageCacheSecretKey := buildPackagesViper.GetString("package-cache-secret-key")
+		flagBuildPackagesPackageCacheRegion := buildPackagesViper.GetString("package-cache-region")
 		flagBuildOutputGraph = buildViper.GetString("output-graph")
 
 		err := fissile.LoadReleases(
@@ -58,21 +95,27 @@ compiled once.
 			}()
 		}
 
-		hasher := sha1.New()
-		if _, err := hasher.Write([]byte(flagBuildPackagesStemcell)); err != nil {
+		packageCache, err := compilator.NewPackageCache(flagBuildPackagesPackageCache, &compilator.S3Credentials{
+			AccessKeyID:     flagBuildPackagesPackageCacheAccessKey,
+			SecretAccessKey: flagBuildPackagesPackageCacheSecretKey,
+			Region:          flagBuildPackagesPackageCacheRegion,
+		})
+		if err != nil {
 			return err
 		}
-		compilationDir := filepath.Join(workPathCompilationDir, hex.EncodeToString(hasher.Sum(nil)))
 
 		return fissile.Compile(
 			flagBuildPackagesStemcell,
-			compilationDir,
+			workPathCompilationDir,
+			workPathLogDir,
 			flagRoleManifest,
-			flagMetrics,
+			timingReports(),
 			strings.FieldsFunc(flagBuildPackagesRoles, func(r rune) bool { return r == ',' }),
 			strings.FieldsFunc(flagBuildPackagesOnlyReleases, func(r rune) bool { return r == ',' }),
 			flagWorkers,
 			flagBuildPackagesDockerNetworkMode,
+			packageCache,
+			flagBuildPackagesKeepGoing,
 			flagBuildPackagesWithoutDocker,
 			flagVerbose,
 		)
@@ -108,6 +151,13 @@ func init() {
 		"Build without docker; this may adversely affect your system.  Only supported on Linux, and requires CAP_SYS_ADMIN.",
 	)
 
+	buildPackagesCmd.PersistentFlags().BoolP(
+		"keep-going",
+		"",
+		false,
+		"Keep compiling packages that don't depend on a failed package, and report all failures at the end.",
+	)
+
 	buildPackagesCmd.PersistentFlags().StringP(
 		"docker-network-mode",
 		"",
@@ -122,5 +172,33 @@ func init() {
 		"The source stemcell",
 	)
 
+	buildPackagesCmd.PersistentFlags().StringP(
+		"package-cache",
+		"",
+		"",
+		"Directory or HTTP(S) URL of a cache of compiled packages shared between builds.",
+	)
+
+	buildPackagesCmd.PersistentFlags().StringP(
+		"package-cache-access-key",
+		"",
+		"",
+		"Access key ID used to sign requests to an S3-compatible package cache.",
+	)
+
+	buildPackagesCmd.PersistentFlags().StringP(
+		"package-cache-secret-key",
+		"",
+		"",
+		"Secret access key used to sign requests to an S3-compatible package cache.",
+	)
+
+	buildPackagesCmd.PersistentFlags().StringP(
+		"package-cache-region",
+		"",
+		"us-east-1",
+		"Region of an S3-compatible package cache.",
+	)
+
 	buildPackagesViper.BindPFlags(buildPackagesCmd.PersistentFlags())
 }
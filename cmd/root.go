@@ -18,29 +18,34 @@ var (
 	fissile *app.Fissile
 	version string
 
-	flagRoleManifest       string
-	flagRelease            []string
-	flagReleaseName        []string
-	flagReleaseVersion     []string
-	flagCacheDir           string
-	flagWorkDir            string
-	flagDockerRegistry     string
-	flagDockerOrganization string
-	flagDockerUsername     string
-	flagDockerPassword     string
-	flagRepository         string
-	flagWorkers            int
-	flagLightOpinions      string
-	flagDarkOpinions       string
-	flagOutputFormat       string
-	flagMetrics            string
-	flagVerbose            bool
+	flagRoleManifest        string
+	flagRoleManifestOverlay []string
+	flagRelease             []string
+	flagReleaseName         []string
+	flagReleaseVersion      []string
+	flagCacheDir            string
+	flagWorkDir             string
+	flagDockerRegistry      string
+	flagDockerOrganization  string
+	flagDockerUsername      string
+	flagDockerPassword      string
+	flagRepository          string
+	flagWorkers             int
+	flagLightOpinions       string
+	flagDarkOpinions        string
+	flagOutputFormat        string
+	flagLogFormat           string
+	flagMetrics             string
+	flagMetricsTextfile     string
+	flagMetricsSummary      bool
+	flagVerbose             bool
 
 	// workPath* variables contain paths derived from flagWorkDir
 	workPathCompilationDir string
 	workPathConfigDir      string
 	workPathBaseDockerfile string
 	workPathDockerDir      string
+	workPathLogDir         string
 )
 
 // RootCmd represents the base command when called without any subcommands
@@ -62,6 +67,12 @@ agent.
 			return err
 		}
 
+		if err = fissile.SetLogFormat(flagLogFormat, reportsProgress(cmd)); err != nil {
+			return err
+		}
+
+		fissile.SetRoleManifestOverlays(flagRoleManifestOverlay)
+
 		return validateReleaseArgs()
 	},
 }
@@ -91,12 +102,20 @@ func init() {
 		"Path to a yaml file that details which jobs are used for each role.",
 	)
 
+	// We can't use slices here because of https://github.com/spf13/viper/issues/112
+	RootCmd.PersistentFlags().StringP(
+		"role-manifest-overlay",
+		"",
+		"",
+		"Path to role manifest overlay(s), applied in order; either roles and variables merged by name, or go-patch style operations.",
+	)
+
 	// We can't use slices here because of https://github.com/spf13/viper/issues/112
 	RootCmd.PersistentFlags().StringP(
 		"release",
 		"r",
 		"",
-		"Path to final or dev BOSH release(s).",
+		"Path to final or dev BOSH release(s), or to final release tarball(s).",
 	)
 
 	// We can't use slices here because of https://github.com/spf13/viper/issues/112
@@ -189,14 +208,35 @@ func init() {
 		"metrics",
 		"M",
 		"",
-		"Path to a CSV file to store timing metrics into.",
+		"Path to a CSV file to append the time spent on each package and role image to.",
+	)
+
+	RootCmd.PersistentFlags().StringP(
+		"metrics-textfile",
+		"",
+		"",
+		"Path to a file to write the time spent on each package and role image to, in the Prometheus textfile collector format.",
+	)
+
+	RootCmd.PersistentFlags().BoolP(
+		"metrics-summary",
+		"",
+		false,
+		"Print a table of the packages and role images that took the longest at the end of the run.",
 	)
 
 	RootCmd.PersistentFlags().StringP(
 		"output",
 		"o",
 		app.OutputFormatHuman,
-		"Choose output format, one of human, json, or yaml (currently only for 'show properties')",
+		"Choose output format, one of human, json, or yaml (for 'show cache', 'show compile-plan', 'show manifest', 'show properties', 'show release' and 'show schema')",
+	)
+
+	RootCmd.PersistentFlags().StringP(
+		"log-format",
+		"",
+		app.OutputFormatHuman,
+		"Choose how progress is reported, one of human, or json (one event per line on stdout, messages on stderr; commands other than 'build' keep their results on stdout, and events go to stderr)",
 	)
 
 	RootCmd.PersistentFlags().BoolP(
@@ -246,6 +286,7 @@ func extendPathsFromWorkDirectory() {
 	workPathConfigDir = filepath.Join(workDir, "config")
 	workPathBaseDockerfile = filepath.Join(workDir, "base_dockerfile")
 	workPathDockerDir = filepath.Join(workDir, "dockerfiles")
+	workPathLogDir = filepath.Join(workDir, "logs")
 
 	// Set defaults for empty flags
 	if flagRoleManifest == "" {
@@ -265,6 +306,7 @@ func validateBasicFlags() error {
 	var err error
 
 	flagRoleManifest = viper.GetString("role-manifest")
+	flagRoleManifestOverlay = splitNonEmpty(viper.GetString("role-manifest-overlay"), ",")
 	flagRelease = splitNonEmpty(viper.GetString("release"), ",")
 	flagReleaseName = splitNonEmpty(viper.GetString("release-name"), ",")
 	flagReleaseVersion = splitNonEmpty(viper.GetString("release-version"), ",")
@@ -279,7 +321,10 @@ func validateBasicFlags() error {
 	flagLightOpinions = viper.GetString("light-opinions")
 	flagDarkOpinions = viper.GetString("dark-opinions")
 	flagOutputFormat = viper.GetString("output")
+	flagLogFormat = viper.GetString("log-format")
 	flagMetrics = viper.GetString("metrics")
+	flagMetricsTextfile = viper.GetString("metrics-textfile")
+	flagMetricsSummary = viper.GetBool("metrics-summary")
 	flagVerbose = viper.GetBool("verbose")
 
 	extendPathsFromWorkDirectory()
@@ -294,22 +339,56 @@ func validateBasicFlags() error {
 		&flagWorkDir,
 		&flagLightOpinions,
 		&flagDarkOpinions,
-		&flagMetrics,
 		&workPathCompilationDir,
 		&workPathConfigDir,
 		&workPathBaseDockerfile,
 		&workPathDockerDir,
+		&workPathLogDir,
 	); err != nil {
 		return err
 	}
 
+	// The metrics files are optional, and an empty path must stay empty
+	for _, path := range []*string{&flagMetrics, &flagMetricsTextfile} {
+		if *path == "" {
+			continue
+		}
+		if err = absolutePaths(path); err != nil {
+			return err
+		}
+	}
+
 	if flagRelease, err = absolutePathsForArray(flagRelease); err != nil {
 		return err
 	}
 
+	if flagRoleManifestOverlay, err = absolutePathsForArray(flagRoleManifestOverlay); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// timingReports returns the timing reports selected by the --metrics flags
+func timingReports() app.TimingReports {
+	return app.TimingReports{
+		CSVPath:        flagMetrics,
+		PrometheusPath: flagMetricsTextfile,
+		Summary:        flagMetricsSummary,
+	}
+}
+
+// reportsProgress returns whether a command reports its progress as events;
+// these are the build commands, while the other commands report results
+func reportsProgress(cmd *cobra.Command) bool {
+	for ; cmd != nil; cmd = cmd.Parent() {
+		if cmd == buildCmd {
+			return true
+		}
+	}
+	return false
+}
+
 func validateReleaseArgs() error {
 	releasePathsCount := len(flagRelease)
 	releaseNamesCount := len(flagReleaseName)
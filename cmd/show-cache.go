@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"github.com/SUSE/fissile/app"
+
+	"github.com/spf13/cobra"
+)
+
+// showCacheCmd represents the cache command
+var showCacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Displays information about the compilation cache.",
+	Long: `
+Displays a report of the stemcells found in the compilation cache populated by
+` + "`fissile build packages`" + `, and for each package in the referenced releases
+the stemcells it has been compiled for.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		err := fissile.LoadReleases(
+			flagRelease,
+			flagReleaseName,
+			flagReleaseVersion,
+			flagCacheDir,
+		)
+		if err != nil {
+			return err
+		}
+
+		return fissile.ListCache(workPathCompilationDir, app.OutputFormat(flagOutputFormat))
+	},
+}
+
+func init() {
+	showCmd.AddCommand(showCacheCmd)
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// showCompileLogCmd represents the compile-log command
+var showCompileLogCmd = &cobra.Command{
+	Use:   "compile-log <package>",
+	Short: "Displays the log of the last compilation of a package.",
+	Long: `
+Displays the output of the last compilation of a package by ` + "`fissile build packages`" + `,
+stored in ` + "`<work-dir>/logs/<release>/<package>-<fingerprint>.log`" + `.  The log of the
+package version in the loaded releases is shown.
+
+If several releases have a package of the same name, give it as
+` + "`<release>/<package>`" + `.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("Expected exactly one package name, got %d", len(args))
+		}
+
+		err := fissile.LoadReleases(
+			flagRelease,
+			flagReleaseName,
+			flagReleaseVersion,
+			flagCacheDir,
+		)
+		if err != nil {
+			return err
+		}
+
+		return fissile.ShowCompileLog(workPathLogDir, args[0])
+	},
+}
+
+func init() {
+	showCmd.AddCommand(showCompileLogCmd)
+}
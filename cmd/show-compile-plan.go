@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/SUSE/fissile/app"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// showCompilePlanCmd represents the compile-plan command
+var showCompilePlanCmd = &cobra.Command{
+	Use:   "compile-plan",
+	Short: "Displays the order packages are compiled in, and how long it takes.",
+	Long: `
+Displays how ` + "`fissile build packages`" + ` would schedule compiling the packages
+needed by the roles of your role manifest from scratch: the dependencies of each
+package, the critical path (the longest chain of dependent packages), and the
+predicted wall clock time for the number of workers given with ` + "`--workers`" + `.
+
+Packages are scheduled by the longest chain of packages depending on them.
+Estimates are based on the compile durations recorded by earlier builds in
+` + "`<work-dir>/compilation/compile-durations.yml`" + `; packages never compiled before
+are marked as guesses.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		flagShowCompilePlanRoles := showCompilePlanViper.GetString("roles")
+
+		err := fissile.LoadReleases(
+			flagRelease,
+			flagReleaseName,
+			flagReleaseVersion,
+			flagCacheDir,
+		)
+		if err != nil {
+			return err
+		}
+
+		return fissile.ShowCompilePlan(
+			workPathCompilationDir,
+			flagRoleManifest,
+			strings.FieldsFunc(flagShowCompilePlanRoles, func(r rune) bool { return r == ',' }),
+			flagWorkers,
+			app.OutputFormat(flagOutputFormat),
+		)
+	},
+}
+
+var showCompilePlanViper = viper.New()
+
+func init() {
+	initViper(showCompilePlanViper)
+
+	showCmd.AddCommand(showCompilePlanCmd)
+
+	// viper is busted w/ string slice, https://github.com/spf13/viper/issues/200
+	showCompilePlanCmd.PersistentFlags().StringP(
+		"roles",
+		"",
+		"",
+		"Plan only packages for the given role names; comma separated.",
+	)
+
+	showCompilePlanViper.BindPFlags(showCompilePlanCmd.PersistentFlags())
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/SUSE/fissile/app"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// showManifestCmd represents the manifest command
+var showManifestCmd = &cobra.Command{
+	Use:   "manifest",
+	Short: "Displays the role manifest.",
+	Long: `
+Displays the role manifest given with ` + "`--role-manifest`" + `, as YAML or JSON: the
+files it includes are merged into it, and the overlays given with
+` + "`--role-manifest-overlay`" + ` are applied to it, in order.
+
+With ` + "`--resolved`" + `, displays the final role manifest fissile works with,
+resolved against the releases: the specs of the jobs, the roles and jobs
+providing the links they consume, and the computed settings of the roles, such
+as their flight stage and port numbers.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		flagShowManifestResolved := showManifestViper.GetBool("resolved")
+		flagShowManifestRoles := showManifestViper.GetString("roles")
+
+		if flagShowManifestResolved {
+			err := fissile.LoadReleases(
+				flagRelease,
+				flagReleaseName,
+				flagReleaseVersion,
+				flagCacheDir,
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		return fissile.ShowManifest(
+			flagRoleManifest,
+			flagShowManifestResolved,
+			strings.FieldsFunc(flagShowManifestRoles, func(r rune) bool { return r == ',' }),
+			app.OutputFormat(flagOutputFormat),
+		)
+	},
+}
+
+var showManifestViper = viper.New()
+
+func init() {
+	initViper(showManifestViper)
+
+	showCmd.AddCommand(showManifestCmd)
+
+	showManifestCmd.PersistentFlags().BoolP(
+		"resolved",
+		"",
+		false,
+		"Display the role manifest resolved against the releases.",
+	)
+
+	// viper is busted w/ string slice, https://github.com/spf13/viper/issues/200
+	showManifestCmd.PersistentFlags().StringP(
+		"roles",
+		"",
+		"",
+		"Display only the given role names; comma separated.",
+	)
+
+	showManifestViper.BindPFlags(showManifestCmd.PersistentFlags())
+}
@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"github.com/SUSE/fissile/app"
+
+	"github.com/spf13/cobra"
+)
+
+// showSchemaCmd represents the schema command
+var showSchemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Displays the JSON Schema of role manifests.",
+	Long: `
+Displays the JSON Schema of role manifests, as JSON or YAML. Role manifests, the
+files they include and the overlays merged into them are checked against it when
+loaded; editors can use it to complete and check role manifests.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return fissile.ShowSchema(app.OutputFormat(flagOutputFormat))
+	},
+}
+
+func init() {
+	showCmd.AddCommand(showSchemaCmd)
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// validateCmd represents the validate command
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validates the role manifest and opinions.",
+	Long: `
+Validates the role manifest given with ` + "`--role-manifest`" + ` and the light and dark
+opinions against the referenced releases, without building anything.
+
+The role manifest, the files it includes and the overlays given with
+` + "`--role-manifest-overlay`" + ` are checked against the schema of role manifests,
+displayed by ` + "`fissile show schema`" + `; unknown keys, such as misspelled ones,
+are reported with their line numbers. Then the same checks as ` + "`fissile build images`" + `
+are made: the properties of the role manifest and opinions must
+exist in the releases, dark opinions must be templated, and so on.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := fissile.LoadReleases(
+			flagRelease,
+			flagReleaseName,
+			flagReleaseVersion,
+			flagCacheDir,
+		)
+		if err != nil {
+			return err
+		}
+
+		return fissile.Validate(
+			flagRoleManifest,
+			flagLightOpinions,
+			flagDarkOpinions,
+		)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(validateCmd)
+}
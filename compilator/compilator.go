@@ -9,19 +9,18 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/SUSE/fissile/docker"
 	"github.com/SUSE/fissile/model"
 	"github.com/SUSE/fissile/scripts/compilation"
 	"github.com/SUSE/fissile/util"
-	"github.com/SUSE/stampy"
 	"github.com/SUSE/termui"
 
-	"github.com/fatih/color"
 	workerLib "github.com/jimmysawczuk/worker"
 	"github.com/pborman/uuid"
+	"github.com/pivotal-golang/archiver/extractor"
 	"github.com/termie/go-shutil"
 )
 
@@ -32,18 +31,28 @@ const (
 	// compile them.  We will add a volume mount there in the container to work around
 	// issues with AUFS not emulating a normal filesystem correctly.
 	ContainerSourceDir = "/var/vcap/source"
+
+	// StemcellOSLabel is the label on a stemcell image holding the OS of the
+	// stemcell, as named by BOSH (e.g. "ubuntu-trusty").  It is checked
+	// against the stemcell of packages from compiled releases.
+	StemcellOSLabel = "stemcell.os"
+	// StemcellVersionLabel is the label on a stemcell image holding the
+	// version of the BOSH stemcell it was built from (e.g. "3586.40").
+	StemcellVersionLabel = "stemcell.version"
 )
 
 // mocked out in tests
 var (
 	isPackageCompiledHarness = (*Compilator).isPackageCompiled
+	lookupStemcellHarness    = (*Compilator).lookupStemcell
 )
 
 // Compilator represents the BOSH compiler
 type Compilator struct {
 	dockerManager     *docker.ImageManager
 	hostWorkDir       string
-	metricsPath       string
+	logDir            string
+	timings           *util.TimingCollector
 	stemcellImageName string
 	baseType          string
 	fissileVersion    string
@@ -66,12 +75,32 @@ type Compilator struct {
 	keepContainer      bool
 	ui                 *termui.UI
 	grapher            util.ModelGrapher
+
+	// failedDependencies maps the fingerprints of packages that could not
+	// be compiled to the reason, in keep-going mode.  Their entry is added
+	// before their signalDependencies channel is closed, so that waiting
+	// dependents can tell failure from success.
+	failedDependencies     map[string]error
+	failedDependenciesLock sync.Mutex
+
+	// stemcell caches the details of the stemcell image, needed to verify
+	// packages from compiled releases and for the package cache
+	stemcell *model.Stemcell
+
+	// packageCache is an optional store of compiled packages shared with
+	// other fissile runs
+	packageCache PackageCache
+
+	// events receives progress events; it prints to ui unless configured
+	// otherwise
+	events util.EventSink
 }
 
 type compileJob struct {
 	workerPackage *workerLib.Package
 	pkg           *model.Package
 	compilator    *Compilator
+	durations     *CompileDurations
 	doneCh        chan<- compileResult
 	killCh        <-chan struct{}
 }
@@ -80,7 +109,8 @@ type compileJob struct {
 func NewDockerCompilator(
 	dockerManager *docker.ImageManager,
 	hostWorkDir string,
-	metricsPath string,
+	logDir string,
+	timings *util.TimingCollector,
 	stemcellImageName string,
 	baseType string,
 	fissileVersion string,
@@ -88,12 +118,15 @@ func NewDockerCompilator(
 	keepContainer bool,
 	ui *termui.UI,
 	grapher util.ModelGrapher,
+	packageCache PackageCache,
+	events util.EventSink,
 ) (*Compilator, error) {
 
 	compilator := &Compilator{
 		dockerManager:     dockerManager,
 		hostWorkDir:       hostWorkDir,
-		metricsPath:       metricsPath,
+		logDir:            logDir,
+		timings:           timings,
 		stemcellImageName: stemcellImageName,
 		baseType:          baseType,
 		fissileVersion:    fissileVersion,
@@ -102,8 +135,15 @@ func NewDockerCompilator(
 		keepContainer:     keepContainer,
 		ui:                ui,
 		grapher:           grapher,
+		packageCache:      packageCache,
+		events:            events,
 
 		signalDependencies: make(map[string]chan struct{}),
+		failedDependencies: make(map[string]error),
+	}
+
+	if compilator.events == nil {
+		compilator.events = util.NewHumanEventSink(ui)
 	}
 
 	return compilator, nil
@@ -113,25 +153,36 @@ func NewDockerCompilator(
 // namespace (Linux only)
 func NewMountNSCompilator(
 	hostWorkDir string,
-	metricsPath string,
+	logDir string,
+	timings *util.TimingCollector,
 	stemcellImageName string,
 	baseType string,
 	fissileVersion string,
 	ui *termui.UI,
 	grapher util.ModelGrapher,
+	packageCache PackageCache,
+	events util.EventSink,
 ) (*Compilator, error) {
 
 	compilator := &Compilator{
 		hostWorkDir:       hostWorkDir,
-		metricsPath:       metricsPath,
+		logDir:            logDir,
+		timings:           timings,
 		stemcellImageName: stemcellImageName,
 		baseType:          baseType,
 		fissileVersion:    fissileVersion,
 		compilePackage:    (*Compilator).compilePackageInMountNS,
 		ui:                ui,
 		grapher:           grapher,
+		packageCache:      packageCache,
+		events:            events,
 
 		signalDependencies: make(map[string]chan struct{}),
+		failedDependencies: make(map[string]error),
+	}
+
+	if compilator.events == nil {
+		compilator.events = util.NewHumanEventSink(ui)
 	}
 
 	return compilator, nil
@@ -139,9 +190,57 @@ func NewMountNSCompilator(
 
 var errWorkerAbort = errors.New("worker aborted")
 
+// dependencyFailedError is the reason for skipping a package in keep-going
+// mode; failed is the package whose compilation failed, which may be an
+// indirect dependency
+type dependencyFailedError struct {
+	failed *model.Package
+}
+
+func (e *dependencyFailedError) Error() string {
+	return fmt.Sprintf("dependency %s/%s failed to compile", e.failed.Release.Name, e.failed.Name)
+}
+
+// PackageFailure describes a package that was not compiled
+type PackageFailure struct {
+	Package *model.Package
+	Err     error
+}
+
+// CompilationFailures is the error returned by Compile in keep-going mode.
+// It lists the packages that failed to compile, and the packages skipped
+// because one of their dependencies failed.
+type CompilationFailures struct {
+	Failed  []PackageFailure
+	Skipped []PackageFailure
+}
+
+func (e *CompilationFailures) Error() string {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "%d package(s) failed to compile, %d package(s) were skipped", len(e.Failed), len(e.Skipped))
+	for _, failure := range e.Failed {
+		fmt.Fprintf(&b, "\n  failed:  %s/%s: %s", failure.Package.Release.Name, failure.Package.Name, failure.Err.Error())
+	}
+	for _, failure := range e.Skipped {
+		fmt.Fprintf(&b, "\n  skipped: %s/%s: %s", failure.Package.Release.Name, failure.Package.Name, failure.Err.Error())
+	}
+
+	return b.String()
+}
+
+func (e *CompilationFailures) add(pkg *model.Package, err error) {
+	if _, skipped := err.(*dependencyFailedError); skipped {
+		e.Skipped = append(e.Skipped, PackageFailure{Package: pkg, Err: err})
+	} else {
+		e.Failed = append(e.Failed, PackageFailure{Package: pkg, Err: err})
+	}
+}
+
 type compileResult struct {
-	pkg *model.Package
-	err error
+	pkg      *model.Package
+	err      error
+	duration time.Duration
 }
 
 // Compile concurrency works like this:
@@ -150,13 +249,23 @@ type compileResult struct {
 // 1 synchronizer consuming EXACTLY 1 <-doneCh for every <-todoCh  <=> Compile() again.
 //
 // Dependencies:
-// - Packages with the least dependencies are queued first.
+// - Packages are queued by the longest chain of packages depending on them,
+//   estimated from the compile durations of earlier runs (see
+//   planCompilation); every package is queued after its dependencies.
 // - Workers wait for their dependencies by waiting on a map of
 //   broadcasting channels that are closed by the synchronizer when
 //   something is done compiling successfully
 //   ==> c.signalDependencies [<fingerprint>]
 //
-// In the event of an error:
+// In the event of an error in keep-going mode:
+// - the synchronizer records the failure in c.failedDependencies and then
+//   signals the dependents as if the package was done
+// - workers seeing a failed dependency skip their package; being reported as
+//   failed in turn, its dependents are skipped as well
+// - everything else is compiled, and the failures are returned together as
+//   CompilationFailures
+//
+// In the event of an error otherwise:
 // - workers will try to bail out of waiting on <-todo or
 //   <-c.signalDependencies[<fingerprint>] early if it finds the killCh has been
 //   activated. There is a "race" here to see if the synchronizer will
@@ -169,7 +278,7 @@ type compileResult struct {
 // - synchronizer will greedily drain the <-todoCh to starve the
 //   workers out and won't wait for the <-doneCh for the N packages it
 //   drained.
-func (c *Compilator) Compile(workerCount int, releases []*model.Release, roles model.Roles, verbose bool) error {
+func (c *Compilator) Compile(workerCount int, releases []*model.Release, roles model.Roles, durations *CompileDurations, keepGoing, verbose bool) error {
 	packages, err := c.removeCompiledPackages(c.gatherPackages(releases, roles), verbose)
 
 	if err != nil {
@@ -188,13 +297,14 @@ func (c *Compilator) Compile(workerCount int, releases []*model.Release, roles m
 	workerLib.MaxJobs = workerCount
 
 	worker := workerLib.NewWorker()
-	buckets := createDepBuckets(packages)
+	plan := planCompilation(packages, workerCount, durations)
 
 	// ... load it with the jobs to run ...
-	for _, pkg := range buckets {
+	for _, pkg := range plan.Order() {
 		worker.Add(compileJob{
 			pkg:        pkg,
 			compilator: c,
+			durations:  durations,
 			killCh:     killCh,
 			doneCh:     doneCh,
 		})
@@ -217,23 +327,31 @@ func (c *Compilator) Compile(workerCount int, releases []*model.Release, roles m
 	// may still run to regular completion.
 
 	killed := false
+	failures := &CompilationFailures{}
 	for result := range doneCh {
 		if result.err == nil {
 			close(c.signalDependencies[result.pkg.Fingerprint])
-			c.ui.Printf("%s   > success: %s/%s\n",
-				color.YellowString("result"),
-				color.GreenString(result.pkg.Release.Name),
-				color.GreenString(result.pkg.Name))
+			c.emitPackageEvent(util.EventPackageCompileDone, result.pkg, util.Event{Duration: result.duration})
 			continue
 		}
 
-		c.ui.Printf(
-			"%s   > failure: %s/%s - %s\n",
-			color.YellowString("result"),
-			color.RedString(result.pkg.Release.Name),
-			color.RedString(result.pkg.Name),
-			color.RedString(result.err.Error()),
-		)
+		if dependencyErr, ok := result.err.(*dependencyFailedError); ok {
+			c.emitPackageEvent(util.EventPackageCompileSkipped, result.pkg, util.Event{
+				Dependency: dependencyErr.failed.Name,
+				Err:        result.err,
+			})
+		} else {
+			c.emitPackageEvent(util.EventPackageCompileFailed, result.pkg, util.Event{Duration: result.duration, Err: result.err})
+		}
+
+		if keepGoing {
+			failures.add(result.pkg, result.err)
+			c.failedDependenciesLock.Lock()
+			c.failedDependencies[result.pkg.Fingerprint] = result.err
+			c.failedDependenciesLock.Unlock()
+			close(c.signalDependencies[result.pkg.Fingerprint])
+			continue
+		}
 
 		err = result.err
 		if !killed {
@@ -242,9 +360,35 @@ func (c *Compilator) Compile(workerCount int, releases []*model.Release, roles m
 		}
 	}
 
+	if len(failures.Failed) > 0 || len(failures.Skipped) > 0 {
+		sort.Slice(failures.Failed, func(i, j int) bool {
+			return failures.Failed[i].Package.Name < failures.Failed[j].Package.Name
+		})
+		sort.Slice(failures.Skipped, func(i, j int) bool {
+			return failures.Skipped[i].Package.Name < failures.Skipped[j].Package.Name
+		})
+		return failures
+	}
+
 	return err
 }
 
+// dependencyFailure returns the reason to skip a package whose dependency
+// has been signalled, or nil if the dependency compiled successfully
+func (c *Compilator) dependencyFailure(dep *model.Package) error {
+	c.failedDependenciesLock.Lock()
+	defer c.failedDependenciesLock.Unlock()
+
+	err, failed := c.failedDependencies[dep.Fingerprint]
+	if !failed {
+		return nil
+	}
+	if dependencyErr, ok := err.(*dependencyFailedError); ok {
+		return dependencyErr
+	}
+	return &dependencyFailedError{failed: dep}
+}
+
 func (c *Compilator) gatherPackages(releases []*model.Release, roles model.Roles) model.Packages {
 	var packages []*model.Package
 
@@ -275,193 +419,56 @@ func (c *Compilator) gatherPackages(releases []*model.Release, roles model.Roles
 func (j compileJob) Run() {
 	c := j.compilator
 
-	// Metrics: Overall time for the specific job
-	var waitSeriesName string
-	var runSeriesName string
-	if c.metricsPath != "" {
-		seriesName := fmt.Sprintf("compile-packages::%s/%s", j.pkg.Release.Name, j.pkg.Name)
-		waitSeriesName = fmt.Sprintf("compile-packages::wait::%s/%s", j.pkg.Release.Name, j.pkg.Name)
-		runSeriesName = fmt.Sprintf("compile-packages::run::%s/%s", j.pkg.Release.Name, j.pkg.Name)
-
-		stampy.Stamp(c.metricsPath, "fissile", seriesName, "start")
-		defer stampy.Stamp(c.metricsPath, "fissile", seriesName, "done")
-
-		stampy.Stamp(c.metricsPath, "fissile", waitSeriesName, "start")
-	}
-
 	// (xx) Wait for our deps. Note how without deps the killCh is
 	// not checked and ignored. It is also in a race with (**)
 	// draining doneCh and actually signaling the kill.
 
 	// Time spent waiting
+	waitStart := time.Now()
 	for _, dep := range j.pkg.Dependencies {
 		done := false
 		for !done {
 			select {
 			case <-j.killCh:
-				c.ui.Printf("killed:  %s/%s\n",
-					color.MagentaString(j.pkg.Release.Name),
-					color.MagentaString(j.pkg.Name))
 				j.doneCh <- compileResult{pkg: j.pkg, err: errWorkerAbort}
-
-				if c.metricsPath != "" {
-					stampy.Stamp(c.metricsPath, "fissile", waitSeriesName, "done")
-				}
 				return
 			case <-time.After(5 * time.Second):
-				c.ui.Printf("waiting: %s/%s - %s\n",
-					color.MagentaString(j.pkg.Release.Name),
-					color.MagentaString(j.pkg.Name),
-					color.MagentaString(dep.Name))
+				c.emitPackageEvent(util.EventPackageWaiting, j.pkg, util.Event{Dependency: dep.Name})
 			case <-c.signalDependencies[dep.Fingerprint]:
-				c.ui.Printf("depdone: %s/%s - %s\n",
-					color.MagentaString(j.pkg.Release.Name),
-					color.MagentaString(j.pkg.Name),
-					color.MagentaString(dep.Name))
+				if err := c.dependencyFailure(dep); err != nil {
+					j.doneCh <- compileResult{pkg: j.pkg, err: err}
+					return
+				}
+				c.emitPackageEvent(util.EventPackageDependencyDone, j.pkg, util.Event{Dependency: dep.Name})
 				done = true
 			}
 		}
 	}
+	wait := time.Since(waitStart)
 
-	if c.metricsPath != "" {
-		stampy.Stamp(c.metricsPath, "fissile", waitSeriesName, "done")
-	}
-
-	c.ui.Printf("compile: %s/%s\n",
-		color.MagentaString(j.pkg.Release.Name),
-		color.MagentaString(j.pkg.Name))
+	c.emitPackageEvent(util.EventPackageCompileStart, j.pkg, util.Event{})
 
 	// Time spent in actual compilation
-	if c.metricsPath != "" {
-		stampy.Stamp(c.metricsPath, "fissile", runSeriesName, "start")
-	}
-
+	start := time.Now()
 	workerErr := c.compilePackage(c, j.pkg)
+	duration := time.Since(start)
+
+	c.timings.Record(util.Timing{
+		Kind:        util.TimingPackage,
+		Release:     j.pkg.Release.Name,
+		Name:        j.pkg.Name,
+		Fingerprint: j.pkg.Fingerprint,
+		Wait:        wait,
+		Duration:    duration,
+		Failed:      workerErr != nil,
+	})
 
-	if c.metricsPath != "" {
-		stampy.Stamp(c.metricsPath, "fissile", runSeriesName, "done")
-	}
-
-	c.ui.Printf("done:    %s/%s\n",
-		color.MagentaString(j.pkg.Release.Name),
-		color.MagentaString(j.pkg.Name))
-
-	j.doneCh <- compileResult{pkg: j.pkg, err: workerErr}
-}
-
-func createDepBuckets(packages []*model.Package) []*model.Package {
-	var buckets []*model.Package
-
-	// ruby takes forever and has no deps,
-	// so optimize by moving ruby packages to the front
-	var rubies []*model.Package
-
-	// topological sort, ensuring that each package X is queued
-	// only after all of its dependencies.
-
-	// helper data structures:
-	// 1. map: package fingerprint -> #(unqueued deps)
-	// 2. map: package fingerprint -> list of using packages (inverted dependencies)
-	//
-	// The counters in the 1st map are initialized with the number
-	// of actual dependencies, and then counted down as
-	// these dependencies are queued up.
-	//
-	// When the counter for a package P reaches 0 then P can be
-	// queued, and in turn bumps the counters of all packages
-	// using it.
-	//
-	// The counters additionally serve as flags for when a package
-	// is queued/processed, saving us a separate boolean
-	// flag. This is done by dropping the respective depCount to -1 (**).
-
-	revDeps := make(map[string][]*model.Package)
-	depCount := make(map[string]int)
-
-	// Initialize the depCount first. In the next loop we can use
-	// the presence of a package P in depCount as the indicator
-	// that this package P is not yet compiled. No need to check
-	// with 'isPackageCompiledHarness' and incurring a dependency
-	// on a Compilator structure.
-
-	for _, pkg := range packages {
-		depCount[pkg.Fingerprint] = 0
-	}
-
-	// Finalize the depCount and initialize the map of reverse
-	// dependencies.
-
-	for _, pkg := range packages {
-		// Note! While the packages we loop over are all not yet
-		// compiled (due to coming out of
-		// 'removeCompiledPackages') we have to check this for
-		// the dependencies as well.  Otherwise we list
-		// dependencies which are not real.
-
-		for _, dep := range pkg.Dependencies {
-			// (dep in packages)
-			// <=> (dep in depCount[])
-			// <=> (dep not compiled, use dep)
-
-			if _, known := depCount[dep.Fingerprint]; !known {
-				// The package is compiled and thus
-				// not a true dependency. Skip it.
-				continue
-			}
-
-			// Record the true dependency
-			depCount[pkg.Fingerprint]++
-			revDeps[dep.Fingerprint] = append(revDeps[dep.Fingerprint], pkg)
-		}
-	}
-
-	// Iterate until we have handled all packages.  We expect each
-	// iteration to handle at least one package, because the input
-	// is a DAG, i.e. has no cycles. Therefore each iteration will
-	// have at least one package with no dependencies, and being
-	// handled.
-
-	keepRunning := true
-	for keepRunning {
-		keepRunning = false
-
-		for _, pkg := range packages {
-
-			// The package either still has dependencies waiting (depCount > 0),
-			// or is enqueued and processed ((**) depCount == -1 < 0)
-			if depCount[pkg.Fingerprint] != 0 {
-				continue
-			}
-
-			// depCount == 0, time to
-			// - queue the package
-			// - notify the outer loop to keep running, and
-			// - force the following iterations to ignore
-			//   the package (See (**)).
-			depCount[pkg.Fingerprint]--
-			keepRunning = true
-
-			// notify the users of the queued that another
-			// of their dependencies is handled
-			for _, usr := range revDeps[pkg.Fingerprint] {
-				depCount[usr.Fingerprint]--
-			}
-
-			// rubies are special, see notes at top of function
-			if strings.HasPrefix(pkg.Name, "ruby-2.") {
-				rubies = append(rubies, pkg)
-				continue
-			}
-
-			// queue regular
-			buckets = append(buckets, pkg)
-		}
+	if workerErr == nil {
+		j.durations.Record(j.pkg, duration)
+		c.storeCachedPackage(j.pkg)
 	}
 
-	// prepend rubies to get them out of the way first
-	buckets = append(rubies, buckets...)
-
-	return buckets
+	j.doneCh <- compileResult{pkg: j.pkg, err: workerErr, duration: duration}
 }
 
 func (c *Compilator) compilePackageInDocker(pkg *model.Package) (err error) {
@@ -491,22 +498,12 @@ func (c *Compilator) compilePackageInDocker(pkg *model.Package) (err error) {
 	// Run compilation in container
 	containerName := c.getPackageContainerName(pkg)
 
-	// in-memory buffer of the log
-	log := new(bytes.Buffer)
-	logWriter := util.NewSyncedWriter(log)
-
-	stdoutWriter := docker.NewFormattingWriter(
-		logWriter,
-		func(line string) string {
-			return color.GreenString("compilation-%s > %s", color.MagentaString("%s", pkg.Name), color.WhiteString("%s", line))
-		},
-	)
-	stderrWriter := docker.NewFormattingWriter(
-		logWriter,
-		func(line string) string {
-			return color.GreenString("compilation-%s > %s", color.MagentaString("%s", pkg.Name), color.RedString("%s", line))
-		},
-	)
+	log, err := c.newPackageLog(pkg)
+	if err != nil {
+		return err
+	}
+	defer log.Close()
+
 	sourceMountName := fmt.Sprintf("source_mount-%s", uuid.New())
 	mounts := map[string]string{
 		pkg.GetTargetPackageSourcesDir(c.hostWorkDir): docker.ContainerInPath,
@@ -526,8 +523,8 @@ func (c *Compilator) compilePackageInDocker(pkg *model.Package) (err error) {
 		NetworkMode:   c.dockerNetworkMode,
 		Volumes:       map[string]map[string]string{sourceMountName: nil},
 		KeepContainer: c.keepContainer,
-		StdoutWriter:  stdoutWriter,
-		StderrWriter:  stderrWriter,
+		StdoutWriter:  log.stdout,
+		StderrWriter:  log.stderr,
 	})
 
 	if container != nil && (!c.keepContainer || err == nil || exitCode == 0) {
@@ -558,13 +555,11 @@ func (c *Compilator) compilePackageInDocker(pkg *model.Package) (err error) {
 	}
 
 	if err != nil {
-		log.WriteTo(c.ui)
-		return fmt.Errorf("Error compiling package %s: %s", pkg.Name, err.Error())
+		return log.fail(c.ui, fmt.Errorf("Error compiling package %s: %s", pkg.Name, err.Error()))
 	}
 
 	if exitCode != 0 {
-		log.WriteTo(c.ui)
-		return fmt.Errorf("Error - compilation for package %s exited with code %d", pkg.Name, exitCode)
+		return log.fail(c.ui, fmt.Errorf("Error - compilation for package %s exited with code %d", pkg.Name, exitCode))
 	}
 
 	return os.Rename(
@@ -717,15 +712,27 @@ func (c *Compilator) removeCompiledPackages(packages model.Packages, verbose boo
 			}
 		}
 
+		if !compiled && pkg.IsCompiled() {
+			// Packages from compiled releases only need to be unpacked
+			if err := c.seedCompiledPackage(pkg); err != nil {
+				return nil, err
+			}
+			compiled = true
+		}
+
+		if !compiled && c.packageCache != nil {
+			compiled = c.fetchCachedPackage(pkg)
+		}
+
 		if compiled {
 			close(c.signalDependencies[pkg.Fingerprint])
 			if verbose {
-				c.ui.Printf("found %s in %s\n", color.YellowString(pkg.Name), pkg.GetPackageCompiledDir(c.hostWorkDir))
+				c.emitPackageEvent(util.EventPackageFound, pkg, util.Event{Path: pkg.GetPackageCompiledDir(c.hostWorkDir)})
 			}
 		} else {
 			culledPackages = append(culledPackages, pkg)
 			if verbose {
-				c.ui.Printf("building %s in %s\n", color.YellowString(pkg.Name), pkg.GetPackageCompiledDir(c.hostWorkDir))
+				c.emitPackageEvent(util.EventPackageQueued, pkg, util.Event{Path: pkg.GetPackageCompiledDir(c.hostWorkDir)})
 			}
 		}
 	}
@@ -733,6 +740,175 @@ func (c *Compilator) removeCompiledPackages(packages model.Packages, verbose boo
 	return culledPackages, nil
 }
 
+// seedCompiledPackage populates the compiled directory of a package from a
+// compiled release with the contents of its archive, after making sure that
+// the package was compiled against the stemcell we are using.
+func (c *Compilator) seedCompiledPackage(pkg *model.Package) error {
+	if err := c.verifyStemcell(pkg); err != nil {
+		return err
+	}
+
+	if err := pkg.ValidateSHA1(); err != nil {
+		return err
+	}
+
+	tempDir := pkg.GetPackageCompiledTempDir(c.hostWorkDir)
+	if err := os.RemoveAll(tempDir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		return err
+	}
+
+	if err := extractor.NewTgz().Extract(pkg.Path, tempDir); err != nil {
+		return fmt.Errorf("Error extracting compiled package %s/%s: %s", pkg.Release.Name, pkg.Name, err.Error())
+	}
+
+	compiledDir := pkg.GetPackageCompiledDir(c.hostWorkDir)
+	if err := os.RemoveAll(compiledDir); err != nil {
+		return err
+	}
+
+	return os.Rename(tempDir, compiledDir)
+}
+
+// fetchCachedPackage tries to populate the compiled directory of a package
+// from the package cache.  Problems with the cache are not fatal; the package
+// is compiled locally instead.
+func (c *Compilator) fetchCachedPackage(pkg *model.Package) bool {
+	tempDir := pkg.GetPackageCompiledTempDir(c.hostWorkDir)
+
+	found := false
+	stemcell, err := c.getStemcell()
+	if err == nil {
+		found, err = c.packageCache.Fetch(PackageCacheKey(pkg, stemcell), tempDir)
+	}
+	if err == nil && found {
+		compiledDir := pkg.GetPackageCompiledDir(c.hostWorkDir)
+		if err = os.RemoveAll(compiledDir); err == nil {
+			err = os.Rename(tempDir, compiledDir)
+		}
+	}
+	if err != nil {
+		c.emitPackageEvent(util.EventWarning, pkg, util.Event{
+			Message: fmt.Sprintf("Error fetching %s/%s from package cache %s: %s", pkg.Release.Name, pkg.Name, c.packageCache.String(), err.Error()),
+			Err:     err,
+		})
+		os.RemoveAll(tempDir)
+		return false
+	}
+
+	if found {
+		c.emitPackageEvent(util.EventPackageCached, pkg, util.Event{})
+	}
+
+	return found
+}
+
+// storeCachedPackage uploads a freshly compiled package to the package cache,
+// if there is one.  Failures only result in a warning.
+func (c *Compilator) storeCachedPackage(pkg *model.Package) {
+	if c.packageCache == nil {
+		return
+	}
+
+	stemcell, err := c.getStemcell()
+	if err == nil {
+		err = c.packageCache.Store(PackageCacheKey(pkg, stemcell), pkg.GetPackageCompiledDir(c.hostWorkDir))
+	}
+	if err != nil {
+		c.emitPackageEvent(util.EventWarning, pkg, util.Event{
+			Message: fmt.Sprintf("Error storing %s/%s in package cache %s: %s", pkg.Release.Name, pkg.Name, c.packageCache.String(), err.Error()),
+			Err:     err,
+		})
+	}
+}
+
+// emitPackageEvent sends an event about the package to the event sink
+func (c *Compilator) emitPackageEvent(eventType util.EventType, pkg *model.Package, event util.Event) {
+	event.Type = eventType
+	event.Release = pkg.Release.Name
+	event.Package = pkg.Name
+	event.Fingerprint = pkg.Fingerprint
+	c.events.Emit(event)
+}
+
+// verifyStemcell checks that a package from a compiled release was compiled
+// against the same stemcell OS and version as the stemcell image
+func (c *Compilator) verifyStemcell(pkg *model.Package) error {
+	pkgOS, pkgVersion, err := pkg.StemcellOSAndVersion()
+	if err != nil {
+		return err
+	}
+
+	stemcell, err := c.getStemcell()
+	if err != nil {
+		return err
+	}
+
+	if stemcell.OS == "" || stemcell.Version == "" {
+		return fmt.Errorf("Stemcell image %s has no %s and %s labels; cannot use compiled package %s/%s",
+			c.stemcellImageName, StemcellOSLabel, StemcellVersionLabel, pkg.Release.Name, pkg.Name)
+	}
+
+	if stemcell.OS != pkgOS || stemcell.Version != pkgVersion {
+		return fmt.Errorf("Compiled package %s/%s was compiled against stemcell %s, but stemcell image %s is %s/%s",
+			pkg.Release.Name, pkg.Name, pkg.Stemcell, c.stemcellImageName, stemcell.OS, stemcell.Version)
+	}
+
+	return nil
+}
+
+// getStemcell returns the stemcell image packages are compiled against,
+// looking it up the first time it is needed
+func (c *Compilator) getStemcell() (*model.Stemcell, error) {
+	if c.stemcell == nil {
+		stemcell, err := lookupStemcellHarness(c)
+		if err != nil {
+			return nil, fmt.Errorf("Error looking up stemcell image %s: %s", c.stemcellImageName, err.Error())
+		}
+		c.stemcell = stemcell
+	}
+
+	return c.stemcell, nil
+}
+
+// lookupStemcell inspects the stemcell image
+func (c *Compilator) lookupStemcell() (*model.Stemcell, error) {
+	dockerManager := c.dockerManager
+	if dockerManager == nil {
+		// The mount namespace compilator doesn't need docker otherwise
+		var err error
+		dockerManager, err = docker.NewImageManager()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return LookupStemcell(dockerManager, c.stemcellImageName)
+}
+
+// LookupStemcell inspects the named stemcell image, returning its ID and the
+// BOSH stemcell OS and version from its labels (if set)
+func LookupStemcell(dockerManager *docker.ImageManager, stemcellImageName string) (*model.Stemcell, error) {
+	image, err := dockerManager.FindImage(stemcellImageName)
+	if err != nil {
+		return nil, err
+	}
+
+	stemcell := &model.Stemcell{
+		ImageName: stemcellImageName,
+		ImageID:   image.ID,
+		Created:   image.Created,
+	}
+	if image.Config != nil {
+		stemcell.OS = image.Config.Labels[StemcellOSLabel]
+		stemcell.Version = image.Config.Labels[StemcellVersionLabel]
+	}
+
+	return stemcell, nil
+}
+
 // gatherPackagesFromRoles gathers the list of packages of the release, from a list of roles, as well as all needed dependencies
 // This happens to be a subset of release.Packages, which helps avoid compiling unneeded packages
 func (c *Compilator) gatherPackagesFromRoles(release *model.Release, roles model.Roles) []*model.Package {
@@ -1,17 +1,14 @@
 package compilator
 
 import (
-	"bytes"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"syscall"
 
-	"github.com/SUSE/fissile/docker"
 	"github.com/SUSE/fissile/model"
 	"github.com/SUSE/fissile/scripts/compilation"
-	"github.com/fatih/color"
 )
 
 func (c *Compilator) compilePackageInMountNS(pkg *model.Package) (err error) {
@@ -37,21 +34,11 @@ func (c *Compilator) compilePackageInMountNS(pkg *model.Package) (err error) {
 		return fmt.Errorf("faile to extract package: %s", err)
 	}
 
-	// in-memory buffer of the log
-	log := new(bytes.Buffer)
-
-	stdoutWriter := docker.NewFormattingWriter(
-		log,
-		func(line string) string {
-			return color.GreenString("compilation-%s > %s", color.MagentaString("%s", pkg.Name), color.WhiteString("%s", line))
-		},
-	)
-	stderrWriter := docker.NewFormattingWriter(
-		log,
-		func(line string) string {
-			return color.GreenString("compilation-%s > %s", color.MagentaString("%s", pkg.Name), color.RedString("%s", line))
-		},
-	)
+	log, err := c.newPackageLog(pkg)
+	if err != nil {
+		return err
+	}
+	defer log.Close()
 
 	bashPath, err := exec.LookPath("bash")
 	if err != nil {
@@ -62,21 +49,20 @@ func (c *Compilator) compilePackageInMountNS(pkg *model.Package) (err error) {
 		Args:   []string{"bash", hostScriptPath, pkg.Name, pkg.Version, c.hostWorkDir},
 		Env:    append(os.Environ(), "HOST_USERID=1000", "HOST_USERGID=1000"),
 		Dir:    c.hostWorkDir,
-		Stdout: stdoutWriter,
-		Stderr: stderrWriter,
+		Stdout: log.stdout,
+		Stderr: log.stderr,
 		SysProcAttr: &syscall.SysProcAttr{
 			Cloneflags: syscall.CLONE_NEWNS,
 		},
 	}
 	err = cmd.Run()
 	if err != nil {
-		log.WriteTo(c.ui)
 		if exitError, ok := err.(*exec.ExitError); ok {
 			if waitStatus, ok := exitError.Sys().(*syscall.WaitStatus); ok {
-				return fmt.Errorf("Error - compilation for package %s exited with code %d", pkg.Name, waitStatus.ExitStatus())
+				return log.fail(c.ui, fmt.Errorf("Error - compilation for package %s exited with code %d", pkg.Name, waitStatus.ExitStatus()))
 			}
 		}
-		return fmt.Errorf("Error compiling package %s: %s", pkg.Name, err)
+		return log.fail(c.ui, fmt.Errorf("Error compiling package %s: %s", pkg.Name, err))
 	}
 
 	return os.Rename(
@@ -56,9 +56,9 @@ func TestCompilePackageInMountNS(t *testing.T) {
 	}
 	defer os.RemoveAll(tempDir)
 
-	c, err := NewMountNSCompilator(tempDir, "", "repo", "linux", "0", ui, nil)
+	c, err := NewMountNSCompilator(tempDir, "", nil, "repo", "linux", "0", ui, nil, nil, nil)
 	assert.NoError(err)
 
-	err = c.Compile(2, []*model.Release{release}, nil, false)
+	err = c.Compile(2, []*model.Release{release}, nil, nil, false, false)
 	assert.NoError(err, stderr.String())
 }
@@ -2,6 +2,7 @@ package compilator
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -49,12 +50,12 @@ func TestMain(m *testing.M) {
 func TestCompilationEmpty(t *testing.T) {
 	assert := assert.New(t)
 
-	c, err := NewDockerCompilator(nil, "", "", "", "", "", "", false, ui, nil)
+	c, err := NewDockerCompilator(nil, "", "", nil, "", "", "", "", false, ui, nil, nil, nil)
 	assert.NoError(err)
 
 	waitCh := make(chan struct{})
 	go func() {
-		err := c.Compile(1, genTestCase(), nil, false)
+		err := c.Compile(1, genTestCase(), nil, nil, false, false)
 		close(waitCh)
 		assert.NoError(err)
 	}()
@@ -65,13 +66,9 @@ func TestCompilationEmpty(t *testing.T) {
 func TestCompilationBasic(t *testing.T) {
 	assert := assert.New(t)
 
-	file, err := ioutil.TempFile("", "metrics")
-	assert.NoError(err)
-
-	metrics := file.Name()
-	defer os.Remove(metrics)
+	timings := util.NewTimingCollector("build-packages")
 
-	c, err := NewDockerCompilator(nil, "", metrics, "", "", "", "", false, ui, nil)
+	c, err := NewDockerCompilator(nil, "", "", timings, "", "", "", "", false, ui, nil, nil, nil)
 	assert.NoError(err)
 
 	compileChan := make(chan string)
@@ -84,7 +81,7 @@ func TestCompilationBasic(t *testing.T) {
 
 	waitCh := make(chan struct{})
 	go func() {
-		c.Compile(1, release, nil, false)
+		c.Compile(1, release, nil, nil, false, false)
 		close(waitCh)
 	}()
 
@@ -102,38 +99,18 @@ func TestCompilationBasic(t *testing.T) {
 		assert.Fail("Timed out waiting for overall completion")
 	}
 
-	expected := []string{
-		",compile-packages::test-release/ruby-2.5,start",
-		",compile-packages::wait::test-release/ruby-2.5,start",
-		",compile-packages::wait::test-release/ruby-2.5,done",
-		",compile-packages::run::test-release/ruby-2.5,start",
-		",compile-packages::run::test-release/ruby-2.5,done",
-		",compile-packages::test-release/ruby-2.5,done",
-		",compile-packages::test-release/go-1.4,start",
-		",compile-packages::wait::test-release/go-1.4,start",
-		",compile-packages::wait::test-release/go-1.4,done",
-		",compile-packages::run::test-release/go-1.4,start",
-		",compile-packages::run::test-release/go-1.4,done",
-		",compile-packages::test-release/go-1.4,done",
-		",compile-packages::test-release/consul,start",
-		",compile-packages::wait::test-release/consul,start",
-		",compile-packages::wait::test-release/consul,done",
-		",compile-packages::run::test-release/consul,start",
-		",compile-packages::run::test-release/consul,done",
-		",compile-packages::test-release/consul,done",
-	}
-
-	contents, err := ioutil.ReadFile(metrics)
-	assert.NoError(err)
-
-	actual := strings.Split(strings.TrimSpace(string(contents)), "\n")
-	if assert.Len(actual, len(expected)) {
-		for lineno, suffix := range expected {
-			if !strings.HasSuffix(actual[lineno], suffix) {
-				assert.Fail(fmt.Sprintf("Doesn't have suffix: \n"+
-					"value: %s\nsuffix: %s\n",
-					actual[lineno], suffix))
-			}
+	recorded := map[string]util.Timing{}
+	for _, timing := range timings.Timings() {
+		recorded[timing.Name] = timing
+	}
+
+	if assert.Len(recorded, 3) {
+		for _, name := range []string{"ruby-2.5", "go-1.4", "consul"} {
+			timing := recorded[name]
+			assert.Equal(util.TimingPackage, timing.Kind)
+			assert.Equal("test-release", timing.Release)
+			assert.Equal(name, timing.Fingerprint)
+			assert.False(timing.Failed)
 		}
 	}
 }
@@ -150,7 +127,7 @@ func TestCompilationSkipCompiled(t *testing.T) {
 
 	assert := assert.New(t)
 
-	c, err := NewDockerCompilator(nil, "", "", "", "", "", "", false, ui, nil)
+	c, err := NewDockerCompilator(nil, "", "", nil, "", "", "", "", false, ui, nil, nil, nil)
 	assert.NoError(err)
 
 	compileChan := make(chan string)
@@ -163,7 +140,7 @@ func TestCompilationSkipCompiled(t *testing.T) {
 
 	waitCh := make(chan struct{})
 	go func() {
-		c.Compile(1, release, nil, false)
+		c.Compile(1, release, nil, nil, false, false)
 		close(waitCh)
 	}()
 
@@ -173,7 +150,7 @@ func TestCompilationSkipCompiled(t *testing.T) {
 }
 
 func TestCompilationRoleManifest(t *testing.T) {
-	c, err := NewDockerCompilator(nil, "", "", "", "", "", "", false, ui, nil)
+	c, err := NewDockerCompilator(nil, "", "", nil, "", "", "", "", false, ui, nil, nil, nil)
 	assert.NoError(t, err)
 
 	compileChan := make(chan string, 2)
@@ -202,7 +179,7 @@ func TestCompilationRoleManifest(t *testing.T) {
 	waitCh := make(chan struct{})
 	errCh := make(chan error)
 	go func() {
-		errCh <- c.Compile(1, []*model.Release{release}, roleManifest.Roles, false)
+		errCh <- c.Compile(1, []*model.Release{release}, roleManifest.Roles, nil, false, false)
 	}()
 	go func() {
 		// `libevent` is a dependency of `tor` and will be compiled first
@@ -267,7 +244,7 @@ func doTestContainerKeptAfterCompilationWithErrors(t *testing.T, keepContainer b
 
 	imageName := "splatform/fissile-stemcell-opensuse:42.2"
 
-	comp, err := NewDockerCompilator(dockerManager, compilationWorkDir, "", imageName, compilation.FakeBase, "3.14.15", "", keepContainer, ui, nil)
+	comp, err := NewDockerCompilator(dockerManager, compilationWorkDir, "", nil, imageName, compilation.FakeBase, "3.14.15", "", keepContainer, ui, nil, nil, nil)
 	assert.NoError(err)
 
 	beforeCompileContainers, err := getContainerIDs(imageName)
@@ -355,7 +332,7 @@ func TestCompilationMultipleErrors(t *testing.T) {
 
 	assert := assert.New(t)
 
-	c, err := NewDockerCompilator(nil, "", "", "", "", "", "", false, ui, nil)
+	c, err := NewDockerCompilator(nil, "", "", nil, "", "", "", "", false, ui, nil, nil, nil)
 	assert.NoError(err)
 
 	c.compilePackage = func(c *Compilator, pkg *model.Package) error {
@@ -364,10 +341,120 @@ func TestCompilationMultipleErrors(t *testing.T) {
 
 	release := genTestCase("ruby-2.5", "consul>go-1.4", "go-1.4")
 
-	err = c.Compile(1, release, nil, false)
+	err = c.Compile(1, release, nil, nil, false, false)
 	assert.NotNil(err)
 }
 
+// TestCompilationKeepGoing checks that in keep-going mode a failure only
+// stops the packages depending on the failed one
+func TestCompilationKeepGoing(t *testing.T) {
+	saveIsPackageCompiled := isPackageCompiledHarness
+	defer func() {
+		isPackageCompiledHarness = saveIsPackageCompiled
+	}()
+
+	isPackageCompiledHarness = func(c *Compilator, pkg *model.Package) (bool, error) {
+		return false, nil
+	}
+
+	assert := assert.New(t)
+
+	c, err := NewDockerCompilator(nil, "", "", nil, "", "", "", "", false, ui, nil, nil, nil)
+	require.NoError(t, err)
+
+	var lock sync.Mutex
+	var compiled []string
+	c.compilePackage = func(c *Compilator, pkg *model.Package) error {
+		if pkg.Name == "go-1.4" {
+			return fmt.Errorf("Intentional error compiling %s", pkg.Name)
+		}
+		lock.Lock()
+		compiled = append(compiled, pkg.Name)
+		lock.Unlock()
+		return nil
+	}
+
+	release := genTestCase("ruby-2.5", "consul>go-1.4", "go-1.4", "cf-cli>consul", "nats")
+
+	err = c.Compile(2, release, nil, nil, true, false)
+	require.Error(t, err)
+	assert.ElementsMatch([]string{"ruby-2.5", "nats"}, compiled)
+
+	failures, ok := err.(*CompilationFailures)
+	require.True(t, ok, "Expected CompilationFailures, got %T", err)
+
+	if assert.Len(failures.Failed, 1) {
+		assert.Equal("go-1.4", failures.Failed[0].Package.Name)
+		assert.Contains(failures.Failed[0].Err.Error(), "Intentional error")
+	}
+	if assert.Len(failures.Skipped, 2) {
+		assert.Equal("cf-cli", failures.Skipped[0].Package.Name)
+		assert.Equal("consul", failures.Skipped[1].Package.Name)
+		for _, skipped := range failures.Skipped {
+			assert.Equal("dependency test-release/go-1.4 failed to compile", skipped.Err.Error())
+		}
+	}
+
+	assert.Contains(err.Error(), "1 package(s) failed to compile, 2 package(s) were skipped")
+	assert.Contains(err.Error(), "failed:  test-release/go-1.4: Intentional error compiling go-1.4")
+	assert.Contains(err.Error(), "skipped: test-release/cf-cli: dependency test-release/go-1.4 failed to compile")
+}
+
+func TestCompilationEvents(t *testing.T) {
+	saveIsPackageCompiled := isPackageCompiledHarness
+	defer func() {
+		isPackageCompiledHarness = saveIsPackageCompiled
+	}()
+
+	isPackageCompiledHarness = func(c *Compilator, pkg *model.Package) (bool, error) {
+		return false, nil
+	}
+
+	assert := assert.New(t)
+
+	events := &bytes.Buffer{}
+	c, err := NewDockerCompilator(nil, "", "", nil, "", "", "", "", false, ui, nil, nil, util.NewJSONEventSink(events))
+	require.NoError(t, err)
+
+	c.compilePackage = func(c *Compilator, pkg *model.Package) error {
+		if pkg.Name == "go-1.4" {
+			return fmt.Errorf("Intentional error compiling %s", pkg.Name)
+		}
+		return nil
+	}
+
+	release := genTestCase("ruby-2.5", "go-1.4")
+	assert.Error(c.Compile(1, release, nil, nil, false, false))
+
+	var received []map[string]interface{}
+	decoder := json.NewDecoder(events)
+	for decoder.More() {
+		var event map[string]interface{}
+		require.NoError(t, decoder.Decode(&event))
+		received = append(received, event)
+	}
+
+	// Workers and the result loop run concurrently, so only the order of
+	// events for each package is fixed
+	byPackage := map[string][]map[string]interface{}{}
+	for _, event := range received {
+		assert.Equal("test-release", event["release"])
+		assert.Equal(event["package"], event["fingerprint"])
+		pkgName := event["package"].(string)
+		byPackage[pkgName] = append(byPackage[pkgName], event)
+	}
+
+	if assert.Len(byPackage["ruby-2.5"], 2) {
+		assert.Equal("package-compile-start", byPackage["ruby-2.5"][0]["event"])
+		assert.Equal("package-compile-done", byPackage["ruby-2.5"][1]["event"])
+	}
+	if assert.Len(byPackage["go-1.4"], 2) {
+		assert.Equal("package-compile-start", byPackage["go-1.4"][0]["event"])
+		assert.Equal("package-compile-failed", byPackage["go-1.4"][1]["event"])
+		assert.Equal("Intentional error compiling go-1.4", byPackage["go-1.4"][1]["error"])
+	}
+}
+
 func TestGetPackageStatusCompiled(t *testing.T) {
 	assert := assert.New(t)
 
@@ -385,7 +472,7 @@ func TestGetPackageStatusCompiled(t *testing.T) {
 	// For this test we assume that the release does not have multiple packages with a single fingerprint
 	assert.NoError(err)
 
-	compilator, err := NewDockerCompilator(dockerManager, compilationWorkDir, "", "fissile-test-compilator", compilation.FakeBase, "3.14.15", "", false, ui, nil)
+	compilator, err := NewDockerCompilator(dockerManager, compilationWorkDir, "", nil, "fissile-test-compilator", compilation.FakeBase, "3.14.15", "", false, ui, nil, nil, nil)
 	assert.NoError(err)
 
 	compiledPackagePath := filepath.Join(compilationWorkDir, release.Packages[0].Fingerprint, "compiled")
@@ -444,7 +531,7 @@ func TestCompilationParallel(t *testing.T) {
 
 	assert := assert.New(t)
 
-	c, err := NewDockerCompilator(nil, "", "", "", "", "", "", false, ui, nil)
+	c, err := NewDockerCompilator(nil, "", "", nil, "", "", "", "", false, ui, nil, nil, nil)
 	assert.NoError(err)
 	c.compilePackage = func(c *Compilator, pkg *model.Package) error {
 		mutex.Lock()
@@ -467,7 +554,7 @@ func TestCompilationParallel(t *testing.T) {
 
 	testDoneCh := make(chan struct{})
 	go func() {
-		err = c.Compile(2, releases, nil, false)
+		err = c.Compile(2, releases, nil, nil, false, false)
 		assert.NoError(err)
 		close(testDoneCh)
 	}()
@@ -499,7 +586,7 @@ func TestGetPackageStatusNone(t *testing.T) {
 	// For this test we assume that the release does not have multiple packages with a single fingerprint
 	assert.NoError(err)
 
-	compilator, err := NewDockerCompilator(dockerManager, compilationWorkDir, "", "fissile-test-compilator", compilation.FakeBase, "3.14.15", "", false, ui, nil)
+	compilator, err := NewDockerCompilator(dockerManager, compilationWorkDir, "", nil, "fissile-test-compilator", compilation.FakeBase, "3.14.15", "", false, ui, nil, nil, nil)
 	assert.NoError(err)
 
 	status, err := compilator.isPackageCompiled(release.Packages[0])
@@ -524,7 +611,7 @@ func TestPackageFolderStructure(t *testing.T) {
 	release, err := model.NewDevRelease(ntpReleasePath, "", "", ntpReleasePathBoshCache)
 	assert.NoError(err)
 
-	compilator, err := NewDockerCompilator(dockerManager, compilationWorkDir, "", "fissile-test-compilator", compilation.FakeBase, "3.14.15", "", false, ui, nil)
+	compilator, err := NewDockerCompilator(dockerManager, compilationWorkDir, "", nil, "fissile-test-compilator", compilation.FakeBase, "3.14.15", "", false, ui, nil, nil, nil)
 	assert.NoError(err)
 
 	err = compilator.createCompilationDirStructure(release.Packages[0])
@@ -555,7 +642,7 @@ func TestPackageDependenciesPreparation(t *testing.T) {
 	release, err := model.NewDevRelease(torReleasePath, "", "", torReleasePathBoshCache)
 	assert.NoError(err)
 
-	compilator, err := NewDockerCompilator(dockerManager, compilationWorkDir, "", "fissile-test-compilator", compilation.FakeBase, "3.14.15", "", false, ui, nil)
+	compilator, err := NewDockerCompilator(dockerManager, compilationWorkDir, "", nil, "fissile-test-compilator", compilation.FakeBase, "3.14.15", "", false, ui, nil, nil, nil)
 	assert.NoError(err)
 
 	pkg, err := release.LookupPackage("tor")
@@ -605,7 +692,7 @@ func doTestCompilePackageInDocker(t *testing.T, keepInContainer bool) {
 
 	imageName := "splatform/fissile-stemcell-opensuse:42.2"
 
-	comp, err := NewDockerCompilator(dockerManager, compilationWorkDir, "", imageName, compilation.FakeBase, "3.14.15", "", keepInContainer, ui, nil)
+	comp, err := NewDockerCompilator(dockerManager, compilationWorkDir, "", nil, imageName, compilation.FakeBase, "3.14.15", "", keepInContainer, ui, nil, nil, nil)
 	assert.NoError(err)
 
 	containerName := comp.getPackageContainerName(release.Packages[0])
@@ -636,7 +723,7 @@ func doTestCompilePackageInDocker(t *testing.T, keepInContainer bool) {
 	}
 }
 
-func TestCreateDepBuckets(t *testing.T) {
+func TestPlanCompilationOrder(t *testing.T) {
 	t.Parallel()
 
 	packages := []*model.Package{
@@ -667,7 +754,7 @@ func TestCreateDepBuckets(t *testing.T) {
 		},
 	}
 
-	buckets := createDepBuckets(packages)
+	buckets := planCompilation(packages, 1, nil).Order()
 	assert.Equal(t, len(buckets), 4)
 	assert.Equal(t, buckets[0].Name, "ruby-2.5") // Ruby should be first
 	assert.Equal(t, buckets[1].Name, "go-1.4")
@@ -675,7 +762,7 @@ func TestCreateDepBuckets(t *testing.T) {
 	assert.Equal(t, buckets[3].Name, "cloud_controller_go")
 }
 
-func TestCreateDepBucketsOnChain(t *testing.T) {
+func TestPlanCompilationOrderOnChain(t *testing.T) {
 	t.Parallel()
 
 	packages := []*model.Package{
@@ -702,7 +789,7 @@ func TestCreateDepBucketsOnChain(t *testing.T) {
 		},
 	}
 
-	buckets := createDepBuckets(packages)
+	buckets := planCompilation(packages, 1, nil).Order()
 	assert.Equal(t, len(buckets), 3)
 	assert.Equal(t, buckets[0].Name, "A")
 	assert.Equal(t, buckets[1].Name, "C")
@@ -712,7 +799,7 @@ func TestCreateDepBucketsOnChain(t *testing.T) {
 func TestGatherPackages(t *testing.T) {
 	assert := assert.New(t)
 
-	c, err := NewDockerCompilator(nil, "", "", "", "", "", "", false, ui, nil)
+	c, err := NewDockerCompilator(nil, "", "", nil, "", "", "", "", false, ui, nil, nil, nil)
 	assert.NoError(err)
 
 	releases := genTestCase("ruby-2.5", "go-1.4.1:G", "go-1.4:G")
@@ -735,7 +822,7 @@ func TestRemoveCompiledPackages(t *testing.T) {
 
 	assert := assert.New(t)
 
-	c, err := NewDockerCompilator(nil, "", "", "", "", "", "", false, ui, nil)
+	c, err := NewDockerCompilator(nil, "", "", nil, "", "", "", "", false, ui, nil, nil, nil)
 	assert.NoError(err)
 
 	releases := genTestCase("ruby-2.5", "consul>go-1.4", "go-1.4")
@@ -790,3 +877,86 @@ func genTestCase(args ...string) []*model.Release {
 
 	return []*model.Release{&release}
 }
+
+func TestRemoveCompiledPackagesSeedsCompiledRelease(t *testing.T) {
+	saveLookupStemcell := lookupStemcellHarness
+	defer func() {
+		lookupStemcellHarness = saveLookupStemcell
+	}()
+
+	workDir, err := os.Getwd()
+	require.NoError(t, err)
+
+	release, err := model.NewFinalRelease(filepath.Join(workDir, "../test-assets/test-compiled-release"))
+	require.NoError(t, err)
+
+	compilationWorkDir, err := util.TempDir("", "fissile-tests")
+	require.NoError(t, err)
+	defer os.RemoveAll(compilationWorkDir)
+
+	t.Run("matching stemcell", func(t *testing.T) {
+		assert := assert.New(t)
+		lookupStemcellHarness = func(c *Compilator) (*model.Stemcell, error) {
+			return &model.Stemcell{
+				ImageName: c.stemcellImageName,
+				ImageID:   "sha256:0123456789abcdef",
+				OS:        "ubuntu-trusty",
+				Version:   "3586.40",
+			}, nil
+		}
+
+		c, err := NewDockerCompilator(nil, compilationWorkDir, "", nil, "stemcell:latest", "", "", "", false, ui, nil, nil, nil)
+		assert.NoError(err)
+
+		packages, err := c.removeCompiledPackages(c.gatherPackages([]*model.Release{release}, nil), false)
+		assert.NoError(err)
+		assert.Empty(packages, "Packages from compiled releases should not need compilation")
+
+		for _, pkg := range release.Packages {
+			compiled, err := c.isPackageCompiled(pkg)
+			assert.NoError(err)
+			assert.True(compiled, "Package %s should have been seeded", pkg.Name)
+			assert.NoError(util.ValidatePath(filepath.Join(pkg.GetPackageCompiledDir(compilationWorkDir), "bin", pkg.Name), false, "compiled package binary"))
+		}
+	})
+
+	t.Run("mismatched stemcell", func(t *testing.T) {
+		assert := assert.New(t)
+		lookupStemcellHarness = func(c *Compilator) (*model.Stemcell, error) {
+			return &model.Stemcell{
+				ImageName: c.stemcellImageName,
+				ImageID:   "sha256:0123456789abcdef",
+				OS:        "opensuse-42.3",
+				Version:   "3586.40",
+			}, nil
+		}
+
+		otherWorkDir := filepath.Join(compilationWorkDir, "other")
+		c, err := NewDockerCompilator(nil, otherWorkDir, "", nil, "stemcell:latest", "", "", "", false, ui, nil, nil, nil)
+		assert.NoError(err)
+
+		_, err = c.removeCompiledPackages(c.gatherPackages([]*model.Release{release}, nil), false)
+		if assert.Error(err) {
+			assert.Contains(err.Error(), "was compiled against stemcell ubuntu-trusty/3586.40")
+		}
+	})
+
+	t.Run("unlabeled stemcell", func(t *testing.T) {
+		assert := assert.New(t)
+		lookupStemcellHarness = func(c *Compilator) (*model.Stemcell, error) {
+			return &model.Stemcell{
+				ImageName: c.stemcellImageName,
+				ImageID:   "sha256:0123456789abcdef",
+			}, nil
+		}
+
+		otherWorkDir := filepath.Join(compilationWorkDir, "unlabeled")
+		c, err := NewDockerCompilator(nil, otherWorkDir, "", nil, "stemcell:latest", "", "", "", false, ui, nil, nil, nil)
+		assert.NoError(err)
+
+		_, err = c.removeCompiledPackages(c.gatherPackages([]*model.Release{release}, nil), false)
+		if assert.Error(err) {
+			assert.Contains(err.Error(), "has no stemcell.os and stemcell.version labels")
+		}
+	})
+}
@@ -0,0 +1,317 @@
+package compilator
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SUSE/fissile/model"
+
+	"gopkg.in/yaml.v2"
+)
+
+// CompileDurationsFile is the name of the file in the compilation directory
+// recording how long packages took to compile
+const CompileDurationsFile = "compile-durations.yml"
+
+const (
+	// defaultCompileEstimate is assumed for packages never compiled before
+	defaultCompileEstimate = time.Minute
+	// rubyCompileEstimate is assumed for ruby packages never compiled
+	// before; ruby takes forever, and gets scheduled early because of it
+	rubyCompileEstimate = 10 * time.Minute
+)
+
+// CompileDurations records how long packages took to compile, in seconds.
+// Estimates use the duration of the same fingerprint if known, and otherwise
+// the latest duration of a package with the same release and name.  It is
+// safe for concurrent use.
+type CompileDurations struct {
+	Fingerprints map[string]float64 `yaml:"fingerprints"`
+	Packages     map[string]float64 `yaml:"packages"`
+
+	lock sync.Mutex
+}
+
+// LoadCompileDurations loads the durations recorded in a file; a missing
+// file has no durations
+func LoadCompileDurations(path string) (*CompileDurations, error) {
+	durations := &CompileDurations{}
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := yaml.Unmarshal(contents, durations); err != nil {
+			return nil, fmt.Errorf("Error loading compile durations %s: %s", path, err.Error())
+		}
+	}
+
+	if durations.Fingerprints == nil {
+		durations.Fingerprints = make(map[string]float64)
+	}
+	if durations.Packages == nil {
+		durations.Packages = make(map[string]float64)
+	}
+
+	return durations, nil
+}
+
+// Save writes the durations to a file
+func (d *CompileDurations) Save(path string) error {
+	d.lock.Lock()
+	contents, err := yaml.Marshal(d)
+	d.lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, contents, 0644)
+}
+
+// Record remembers how long the package took to compile
+func (d *CompileDurations) Record(pkg *model.Package, duration time.Duration) {
+	if d == nil {
+		return
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.Fingerprints[pkg.Fingerprint] = duration.Seconds()
+	d.Packages[compileDurationsPackageKey(pkg)] = duration.Seconds()
+}
+
+// Estimate returns the expected compilation time of the package, and whether
+// it is based on an earlier compilation
+func (d *CompileDurations) Estimate(pkg *model.Package) (time.Duration, bool) {
+	if d != nil {
+		d.lock.Lock()
+		defer d.lock.Unlock()
+
+		seconds, ok := d.Fingerprints[pkg.Fingerprint]
+		if !ok {
+			seconds, ok = d.Packages[compileDurationsPackageKey(pkg)]
+		}
+		if ok {
+			return time.Duration(seconds * float64(time.Second)), true
+		}
+	}
+
+	if strings.HasPrefix(pkg.Name, "ruby-2.") {
+		return rubyCompileEstimate, false
+	}
+	return defaultCompileEstimate, false
+}
+
+func compileDurationsPackageKey(pkg *model.Package) string {
+	if pkg.Release == nil {
+		return pkg.Name
+	}
+	return fmt.Sprintf("%s/%s", pkg.Release.Name, pkg.Name)
+}
+
+// PlannedPackage is a package in a CompilePlan
+type PlannedPackage struct {
+	Package *model.Package
+	// Dependencies are the dependencies compiled as part of the plan
+	Dependencies []*model.Package
+	// Estimate is the expected compilation time, Historical says whether
+	// it is based on an earlier compilation
+	Estimate   time.Duration
+	Historical bool
+	// RemainingPath is the estimated time of the longest chain of packages
+	// starting with this one
+	RemainingPath time.Duration
+	// Start and End are the predicted times, relative to the start of the
+	// compilation, and Worker the predicted worker
+	Start  time.Duration
+	End    time.Duration
+	Worker int
+}
+
+// CompilePlan is the order packages are compiled in, and the prediction of
+// how long that takes
+type CompilePlan struct {
+	WorkerCount int
+	// Packages are in the order they are queued for compilation
+	Packages []*PlannedPackage
+	// CriticalPath is the longest chain of dependent packages
+	CriticalPath []*PlannedPackage
+	// WallClock is the predicted time to compile all packages
+	WallClock time.Duration
+}
+
+// PlanCompilation plans compiling all packages needed by the roles (or all
+// packages of the releases, if roles is nil) from scratch
+func PlanCompilation(releases []*model.Release, roles model.Roles, workerCount int, durations *CompileDurations) *CompilePlan {
+	c := &Compilator{signalDependencies: make(map[string]chan struct{})}
+	packages := c.gatherPackages(releases, roles)
+	sort.Sort(packages)
+
+	return planCompilation(packages, workerCount, durations)
+}
+
+// planCompilation orders the packages by the longest remaining path of
+// dependent packages, so that long chains start as early as possible.  The
+// order is that of a simulated compilation with the given number of workers,
+// which always picks the ready package with the longest remaining path.
+// Because each package is started after its dependencies, the order is also
+// a valid queue order for the worker pool.
+func planCompilation(packages []*model.Package, workerCount int, durations *CompileDurations) *CompilePlan {
+	if workerCount < 1 {
+		workerCount = 1
+	}
+
+	plan := &CompilePlan{WorkerCount: workerCount}
+
+	planned := make(map[string]*PlannedPackage, len(packages))
+	index := make(map[string]int, len(packages))
+	for i, pkg := range packages {
+		estimate, historical := durations.Estimate(pkg)
+		planned[pkg.Fingerprint] = &PlannedPackage{
+			Package:    pkg,
+			Estimate:   estimate,
+			Historical: historical,
+		}
+		index[pkg.Fingerprint] = i
+	}
+
+	// Dependencies which are not part of the plan are compiled already,
+	// and not real dependencies
+	users := make(map[string][]*PlannedPackage)
+	for _, pkg := range packages {
+		for _, dep := range pkg.Dependencies {
+			if _, known := planned[dep.Fingerprint]; !known {
+				continue
+			}
+			planned[pkg.Fingerprint].Dependencies = append(planned[pkg.Fingerprint].Dependencies, planned[dep.Fingerprint].Package)
+			users[dep.Fingerprint] = append(users[dep.Fingerprint], planned[pkg.Fingerprint])
+		}
+	}
+
+	var remainingPath func(p *PlannedPackage) time.Duration
+	remainingPath = func(p *PlannedPackage) time.Duration {
+		if p.RemainingPath == 0 {
+			var longest time.Duration
+			for _, user := range users[p.Package.Fingerprint] {
+				if path := remainingPath(user); path > longest {
+					longest = path
+				}
+			}
+			p.RemainingPath = p.Estimate + longest
+		}
+		return p.RemainingPath
+	}
+	for _, pkg := range packages {
+		remainingPath(planned[pkg.Fingerprint])
+	}
+
+	// morePressing orders ready packages: longest remaining path first,
+	// then in input order
+	morePressing := func(a, b *PlannedPackage) bool {
+		if a.RemainingPath != b.RemainingPath {
+			return a.RemainingPath > b.RemainingPath
+		}
+		return index[a.Package.Fingerprint] < index[b.Package.Fingerprint]
+	}
+
+	// Simulate the compilation to find the queue order
+	unfinished := make(map[string]int, len(packages))
+	var ready []*PlannedPackage
+	for _, pkg := range packages {
+		p := planned[pkg.Fingerprint]
+		unfinished[pkg.Fingerprint] = len(p.Dependencies)
+		if len(p.Dependencies) == 0 {
+			ready = append(ready, p)
+		}
+	}
+
+	var running []*PlannedPackage
+	var now time.Duration
+	for len(ready) > 0 || len(running) > 0 {
+		sort.SliceStable(ready, func(i, j int) bool { return morePressing(ready[i], ready[j]) })
+		for len(running) < workerCount && len(ready) > 0 {
+			p := ready[0]
+			ready = ready[1:]
+			p.Start = now
+			p.End = now + p.Estimate
+			running = append(running, p)
+			plan.Packages = append(plan.Packages, p)
+		}
+
+		// Advance to the next package finishing
+		sort.SliceStable(running, func(i, j int) bool { return running[i].End < running[j].End })
+		now = running[0].End
+		for len(running) > 0 && running[0].End == now {
+			finished := running[0]
+			running = running[1:]
+			for _, user := range users[finished.Package.Fingerprint] {
+				unfinished[user.Package.Fingerprint]--
+				if unfinished[user.Package.Fingerprint] == 0 {
+					ready = append(ready, user)
+				}
+			}
+		}
+	}
+
+	// The worker pool hands queued packages to the first free worker, which
+	// then waits for the dependencies; predict the times for that
+	workerFree := make([]time.Duration, workerCount)
+	for _, p := range plan.Packages {
+		worker := 0
+		for i := range workerFree {
+			if workerFree[i] < workerFree[worker] {
+				worker = i
+			}
+		}
+
+		p.Worker = worker + 1
+		p.Start = workerFree[worker]
+		for _, dep := range p.Dependencies {
+			if end := planned[dep.Fingerprint].End; end > p.Start {
+				p.Start = end
+			}
+		}
+		p.End = p.Start + p.Estimate
+		workerFree[worker] = p.End
+
+		if p.End > plan.WallClock {
+			plan.WallClock = p.End
+		}
+	}
+
+	// The critical path starts at the package with the longest remaining
+	// path, and follows the users with the longest remaining path
+	var next *PlannedPackage
+	for _, p := range plan.Packages {
+		if next == nil || morePressing(p, next) {
+			next = p
+		}
+	}
+	for next != nil {
+		plan.CriticalPath = append(plan.CriticalPath, next)
+		candidates := users[next.Package.Fingerprint]
+		next = nil
+		for _, user := range candidates {
+			if next == nil || morePressing(user, next) {
+				next = user
+			}
+		}
+	}
+
+	return plan
+}
+
+// Order returns the packages in the order they are queued for compilation
+func (p *CompilePlan) Order() []*model.Package {
+	order := make([]*model.Package, len(p.Packages))
+	for i, planned := range p.Packages {
+		order[i] = planned.Package
+	}
+	return order
+}
@@ -0,0 +1,110 @@
+package compilator
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SUSE/fissile/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func plannedNames(planned []*PlannedPackage) []string {
+	var names []string
+	for _, p := range planned {
+		names = append(names, p.Package.Name)
+	}
+	return names
+}
+
+func TestPlanCompilationCriticalPath(t *testing.T) {
+	assert := assert.New(t)
+
+	// A long chain (ruby -> gems -> app) and some short independent
+	// packages, listed first
+	release := genTestCase("a", "b", "c", "app>gems", "gems>ruby", "ruby")[0]
+	durations := &CompileDurations{
+		Fingerprints: map[string]float64{"ruby": 600, "gems": 300, "app": 120, "a": 60, "b": 60, "c": 60},
+		Packages:     map[string]float64{},
+	}
+
+	plan := planCompilation(release.Packages, 2, durations)
+
+	assert.Equal([]string{"ruby", "a", "b", "c", "gems", "app"}, plannedNames(plan.Packages))
+	assert.Equal([]string{"ruby", "gems", "app"}, plannedNames(plan.CriticalPath))
+	assert.Equal(17*time.Minute, plan.WallClock)
+
+	for _, p := range plan.Packages {
+		assert.True(p.Historical)
+		if p.Package.Name == "gems" {
+			assert.Equal(10*time.Minute, p.Start)
+			assert.Equal(7*time.Minute, p.RemainingPath)
+			if assert.Len(p.Dependencies, 1) {
+				assert.Equal("ruby", p.Dependencies[0].Name)
+			}
+		}
+	}
+
+	// With a single worker everything is sequential
+	plan = planCompilation(release.Packages, 1, durations)
+	assert.Equal(20*time.Minute, plan.WallClock)
+	assert.Equal("ruby", plan.Packages[0].Package.Name)
+}
+
+func TestPlanCompilationWeighsChains(t *testing.T) {
+	assert := assert.New(t)
+
+	// go-1.4 compiles faster than nats, but with consul depending on it,
+	// its chain is longer
+	release := genTestCase("nats", "consul>go-1.4", "go-1.4")[0]
+	durations := &CompileDurations{
+		Fingerprints: map[string]float64{"go-1.4": 10, "nats": 12},
+		Packages:     map[string]float64{"test-release/consul": 5},
+	}
+
+	plan := planCompilation(release.Packages, 1, durations)
+	assert.Equal([]string{"go-1.4", "nats", "consul"}, plannedNames(plan.Packages))
+	assert.Equal(27*time.Second, plan.WallClock)
+}
+
+func TestCompileDurations(t *testing.T) {
+	assert := assert.New(t)
+
+	tempDir, err := ioutil.TempDir("", "fissile-tests")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	path := filepath.Join(tempDir, CompileDurationsFile)
+
+	durations, err := LoadCompileDurations(path)
+	require.NoError(t, err, "A missing file should have no durations")
+
+	release := &model.Release{Name: "tor"}
+	libevent := &model.Package{Release: release, Name: "libevent", Fingerprint: "aaaa"}
+	estimate, historical := durations.Estimate(libevent)
+	assert.False(historical)
+	assert.Equal(defaultCompileEstimate, estimate)
+
+	durations.Record(libevent, 90*time.Second)
+	require.NoError(t, durations.Save(path))
+
+	durations, err = LoadCompileDurations(path)
+	require.NoError(t, err)
+
+	estimate, historical = durations.Estimate(libevent)
+	assert.True(historical)
+	assert.Equal(90*time.Second, estimate)
+
+	// A new version of the package is estimated from the old one
+	estimate, historical = durations.Estimate(&model.Package{Release: release, Name: "libevent", Fingerprint: "bbbb"})
+	assert.True(historical)
+	assert.Equal(90*time.Second, estimate)
+
+	estimate, historical = durations.Estimate(&model.Package{Release: release, Name: "ruby-2.5", Fingerprint: "cccc"})
+	assert.False(historical)
+	assert.Equal(rubyCompileEstimate, estimate)
+}
@@ -0,0 +1,332 @@
+package compilator
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/SUSE/fissile/model"
+	"github.com/SUSE/fissile/util"
+
+	"github.com/pivotal-golang/archiver/extractor"
+)
+
+// PackageCache is a store of compiled packages that can be shared between
+// fissile runs on different machines. Compiled packages are stored as tar.gz
+// archives of their compiled directory.
+type PackageCache interface {
+	// Fetch extracts the compiled package stored under the key into the
+	// target directory. It returns false if the key is not in the cache.
+	Fetch(key, targetDir string) (bool, error)
+	// Store archives the compiled package in the source directory and
+	// stores it under the key.
+	Store(key, sourceDir string) error
+	// String describes the cache for the user
+	String() string
+}
+
+// PackageCacheKey returns the key under which a compiled package is stored in
+// a PackageCache. Compiled packages depend on both the package sources
+// (identified by the fingerprint) and the stemcell they were compiled on.
+func PackageCacheKey(pkg *model.Package, stemcell *model.Stemcell) string {
+	return fmt.Sprintf("%s/%s.tgz", stemcell.CacheKey(), pkg.Fingerprint)
+}
+
+// NewPackageCache creates a PackageCache for the given location. Plain paths
+// and file:// URLs use a FilesystemPackageCache; http:// and https:// URLs an
+// HTTPPackageCache. Credentials are only used for HTTP caches; if an access
+// key is given, requests are signed like S3 requests.
+func NewPackageCache(location string, credentials *S3Credentials) (PackageCache, error) {
+	if location == "" {
+		return nil, nil
+	}
+
+	cacheURL, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid package cache location %s: %s", location, err.Error())
+	}
+
+	switch cacheURL.Scheme {
+	case "":
+		return NewFilesystemPackageCache(location), nil
+	case "file":
+		return NewFilesystemPackageCache(cacheURL.Path), nil
+	case "http", "https":
+		return NewHTTPPackageCache(location, credentials), nil
+	}
+
+	return nil, fmt.Errorf("Unsupported package cache location %s; expected a path, or a file, http or https URL", location)
+}
+
+// FilesystemPackageCache is a PackageCache in a (possibly network mounted)
+// directory
+type FilesystemPackageCache struct {
+	root string
+}
+
+// NewFilesystemPackageCache creates a new FilesystemPackageCache rooted at the
+// given directory
+func NewFilesystemPackageCache(root string) *FilesystemPackageCache {
+	return &FilesystemPackageCache{root: root}
+}
+
+// Fetch implements PackageCache
+func (c *FilesystemPackageCache) Fetch(key, targetDir string) (bool, error) {
+	archivePath := filepath.Join(c.root, filepath.FromSlash(key))
+	if _, err := os.Stat(archivePath); os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if err := extractPackageArchive(archivePath, targetDir); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Store implements PackageCache
+func (c *FilesystemPackageCache) Store(key, sourceDir string) error {
+	archivePath := filepath.Join(c.root, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(archivePath), 0755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so concurrent readers never see a
+	// partial archive
+	archive, err := ioutil.TempFile(filepath.Dir(archivePath), ".package-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(archive.Name())
+
+	if err := util.WriteDirToTargz(archive, sourceDir); err != nil {
+		archive.Close()
+		return err
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(archive.Name(), archivePath)
+}
+
+func (c *FilesystemPackageCache) String() string {
+	return c.root
+}
+
+// S3Credentials are the credentials used to sign requests to S3-compatible
+// package caches
+type S3Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	Region          string
+}
+
+// HTTPPackageCacheTimeout limits the time requests to HTTP package caches
+// may take, including the transfer of the package, so that a stalled server
+// doesn't block the compilation workers
+const HTTPPackageCacheTimeout = 10 * time.Minute
+
+// HTTPPackageCache is a PackageCache that uses GET and PUT requests against a
+// base URL, e.g. a bucket of an S3-compatible object store, or a WebDAV
+// server
+type HTTPPackageCache struct {
+	baseURL     string
+	credentials *S3Credentials
+	client      *http.Client
+	now         func() time.Time
+}
+
+// NewHTTPPackageCache creates a new HTTPPackageCache. The credentials are
+// optional; without them, requests are not signed.
+func NewHTTPPackageCache(baseURL string, credentials *S3Credentials) *HTTPPackageCache {
+	if credentials != nil && credentials.AccessKeyID == "" {
+		credentials = nil
+	}
+
+	return &HTTPPackageCache{
+		baseURL:     strings.TrimSuffix(baseURL, "/"),
+		credentials: credentials,
+		client:      &http.Client{Timeout: HTTPPackageCacheTimeout},
+		now:         time.Now,
+	}
+}
+
+// Fetch implements PackageCache
+func (c *HTTPPackageCache) Fetch(key, targetDir string) (bool, error) {
+	request, err := http.NewRequest(http.MethodGet, c.keyURL(key), nil)
+	if err != nil {
+		return false, err
+	}
+	c.sign(request)
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return false, err
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return false, nil
+	case http.StatusForbidden:
+		// S3 answers 403 for missing keys when listing is not allowed, but
+		// also for wrong credentials; the package is compiled either way
+		return false, fmt.Errorf("Access denied fetching %s: %s; check the package cache credentials, "+
+			"and that they allow listing the bucket", c.keyURL(key), response.Status)
+	default:
+		return false, fmt.Errorf("Unexpected status fetching %s: %s", c.keyURL(key), response.Status)
+	}
+
+	archive, err := ioutil.TempFile("", "fissile-package-cache")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(archive.Name())
+
+	if _, err := io.Copy(archive, response.Body); err != nil {
+		archive.Close()
+		return false, err
+	}
+	if err := archive.Close(); err != nil {
+		return false, err
+	}
+
+	if err := extractPackageArchive(archive.Name(), targetDir); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Store implements PackageCache
+func (c *HTTPPackageCache) Store(key, sourceDir string) error {
+	archive, err := ioutil.TempFile("", "fissile-package-cache")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+
+	if err := util.WriteDirToTargz(archive, sourceDir); err != nil {
+		return err
+	}
+
+	size, err := archive.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := archive.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(http.MethodPut, c.keyURL(key), archive)
+	if err != nil {
+		return err
+	}
+	request.ContentLength = size
+	request.Header.Set("Content-Type", "application/gzip")
+	c.sign(request)
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("Unexpected status storing %s: %s", c.keyURL(key), response.Status)
+	}
+
+	return nil
+}
+
+func (c *HTTPPackageCache) String() string {
+	return c.baseURL
+}
+
+func (c *HTTPPackageCache) keyURL(key string) string {
+	return fmt.Sprintf("%s/%s", c.baseURL, key)
+}
+
+// sign adds an AWS signature version 4 to the request, as used by S3 and
+// compatible stores. The payload is not signed, so it doesn't have to be read
+// twice.
+func (c *HTTPPackageCache) sign(request *http.Request) {
+	if c.credentials == nil {
+		return
+	}
+
+	region := c.credentials.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	now := c.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := "UNSIGNED-PAYLOAD"
+
+	request.Header.Set("X-Amz-Date", amzDate)
+	request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		request.URL.EscapedPath(),
+		request.URL.RawQuery,
+		fmt.Sprintf("host:%s\nx-amz-content-sha256:%s\nx-amz-date:%s\n", request.URL.Host, payloadHash, amzDate),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, region)
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(canonicalHash[:]),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+c.credentials.SecretAccessKey), date)
+	signingKey = hmacSHA256(signingKey, region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.credentials.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// extractPackageArchive extracts a compiled package archive into the target
+// directory, replacing anything that was there
+func extractPackageArchive(archivePath, targetDir string) error {
+	if err := os.RemoveAll(targetDir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		return err
+	}
+
+	return extractor.NewTgz().Extract(archivePath, targetDir)
+}
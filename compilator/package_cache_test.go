@@ -0,0 +1,264 @@
+package compilator
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SUSE/fissile/model"
+	"github.com/SUSE/fissile/util"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createCompiledPackageDir creates a directory that looks like the output of
+// a package compilation
+func createCompiledPackageDir(t *testing.T, dir, name string) {
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "bin"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "bin", name), []byte("#!/bin/sh\necho "+name+"\n"), 0755))
+}
+
+// testPackageCache checks the behaviour shared by all PackageCache
+// implementations
+func testPackageCache(t *testing.T, cache PackageCache, tempDir string) {
+	assert := assert.New(t)
+
+	sourceDir := filepath.Join(tempDir, "source")
+	targetDir := filepath.Join(tempDir, "target")
+	createCompiledPackageDir(t, sourceDir, "foo")
+
+	found, err := cache.Fetch("stemcell/fingerprint.tgz", targetDir)
+	assert.NoError(err)
+	assert.False(found, "Empty cache should not contain the package")
+
+	assert.NoError(cache.Store("stemcell/fingerprint.tgz", sourceDir))
+
+	found, err = cache.Fetch("stemcell/fingerprint.tgz", targetDir)
+	assert.NoError(err)
+	assert.True(found, "Stored package should be found in the cache")
+
+	contents, err := ioutil.ReadFile(filepath.Join(targetDir, "bin", "foo"))
+	assert.NoError(err)
+	assert.Equal("#!/bin/sh\necho foo\n", string(contents))
+
+	info, err := os.Stat(filepath.Join(targetDir, "bin", "foo"))
+	if assert.NoError(err) {
+		assert.Equal(os.FileMode(0755), info.Mode().Perm())
+	}
+}
+
+func TestFilesystemPackageCache(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "fissile-tests")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	cache := NewFilesystemPackageCache(filepath.Join(tempDir, "cache"))
+	testPackageCache(t, cache, tempDir)
+
+	assert.NoError(t, util.ValidatePath(filepath.Join(tempDir, "cache", "stemcell", "fingerprint.tgz"), false, "cached package"))
+}
+
+// objectStore is a minimal stand-in for an S3-compatible object store
+type objectStore struct {
+	sync.Mutex
+	objects     map[string][]byte
+	accessKeyID string
+}
+
+func (s *objectStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.accessKeyID != "" {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential="+s.accessKeyID+"/") ||
+			r.Header.Get("X-Amz-Date") == "" ||
+			r.Header.Get("X-Amz-Content-Sha256") != "UNSIGNED-PAYLOAD" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	switch r.Method {
+	case http.MethodGet:
+		object, ok := s.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(object)
+	case http.MethodPut:
+		object, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		s.objects[r.URL.Path] = object
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestHTTPPackageCache(t *testing.T) {
+	t.Run("unsigned", func(t *testing.T) {
+		tempDir, err := ioutil.TempDir("", "fissile-tests")
+		require.NoError(t, err)
+		defer os.RemoveAll(tempDir)
+
+		store := &objectStore{objects: map[string][]byte{}}
+		server := httptest.NewServer(store)
+		defer server.Close()
+
+		testPackageCache(t, NewHTTPPackageCache(server.URL+"/bucket/", nil), tempDir)
+		assert.Contains(t, store.objects, "/bucket/stemcell/fingerprint.tgz")
+	})
+
+	t.Run("signed", func(t *testing.T) {
+		tempDir, err := ioutil.TempDir("", "fissile-tests")
+		require.NoError(t, err)
+		defer os.RemoveAll(tempDir)
+
+		store := &objectStore{objects: map[string][]byte{}, accessKeyID: "fissile"}
+		server := httptest.NewServer(store)
+		defer server.Close()
+
+		testPackageCache(t, NewHTTPPackageCache(server.URL+"/bucket", &S3Credentials{
+			AccessKeyID:     "fissile",
+			SecretAccessKey: "secret",
+		}), tempDir)
+	})
+
+	t.Run("server error", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		cache := NewHTTPPackageCache(server.URL, nil)
+		_, err := cache.Fetch("stemcell/fingerprint.tgz", filepath.Join(os.TempDir(), "unused"))
+		assert.Error(t, err)
+	})
+
+	t.Run("wrong credentials", func(t *testing.T) {
+		store := &objectStore{objects: map[string][]byte{}, accessKeyID: "fissile"}
+		server := httptest.NewServer(store)
+		defer server.Close()
+
+		cache := NewHTTPPackageCache(server.URL, &S3Credentials{
+			AccessKeyID:     "someone-else",
+			SecretAccessKey: "secret",
+		})
+		found, err := cache.Fetch("stemcell/fingerprint.tgz", filepath.Join(os.TempDir(), "unused"))
+		assert.False(t, found)
+		if assert.Error(t, err, "Denied access should not pass for a missing package") {
+			assert.Contains(t, err.Error(), "403 Forbidden")
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		stalled := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-stalled
+		}))
+		defer server.Close()
+		defer close(stalled)
+
+		cache := NewHTTPPackageCache(server.URL, nil)
+		assert.Equal(t, HTTPPackageCacheTimeout, cache.client.Timeout)
+		cache.client.Timeout = 10 * time.Millisecond
+		_, err := cache.Fetch("stemcell/fingerprint.tgz", filepath.Join(os.TempDir(), "unused"))
+		assert.Error(t, err)
+	})
+}
+
+func TestNewPackageCache(t *testing.T) {
+	assert := assert.New(t)
+
+	cache, err := NewPackageCache("", nil)
+	assert.NoError(err)
+	assert.Nil(cache)
+
+	cache, err = NewPackageCache("/var/cache/fissile", nil)
+	if assert.NoError(err) {
+		assert.IsType(&FilesystemPackageCache{}, cache)
+		assert.Equal("/var/cache/fissile", cache.String())
+	}
+
+	cache, err = NewPackageCache("file:///var/cache/fissile", nil)
+	if assert.NoError(err) {
+		assert.IsType(&FilesystemPackageCache{}, cache)
+		assert.Equal("/var/cache/fissile", cache.String())
+	}
+
+	cache, err = NewPackageCache("https://s3.example.com/bucket", &S3Credentials{})
+	if assert.NoError(err) {
+		assert.IsType(&HTTPPackageCache{}, cache)
+		assert.Nil(cache.(*HTTPPackageCache).credentials, "Empty credentials should not be used to sign requests")
+	}
+
+	_, err = NewPackageCache("ftp://example.com/cache", nil)
+	assert.Error(err)
+}
+
+func TestCompilationUsesPackageCache(t *testing.T) {
+	saveLookupStemcell := lookupStemcellHarness
+	defer func() {
+		lookupStemcellHarness = saveLookupStemcell
+	}()
+
+	lookupStemcellHarness = func(c *Compilator) (*model.Stemcell, error) {
+		return &model.Stemcell{ImageName: c.stemcellImageName, ImageID: "sha256:0123456789abcdef"}, nil
+	}
+
+	tempDir, err := ioutil.TempDir("", "fissile-tests")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	workDir, err := os.Getwd()
+	require.NoError(t, err)
+
+	releasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	release, err := model.NewDevRelease(releasePath, "", "", filepath.Join(releasePath, "bosh-cache"))
+	require.NoError(t, err)
+
+	cache := NewFilesystemPackageCache(filepath.Join(tempDir, "cache"))
+
+	// compile runs a compilation in a fresh work directory, and returns the
+	// names of the packages that were actually compiled
+	compile := func(hostWorkDir string) []string {
+		c, err := NewDockerCompilator(nil, hostWorkDir, "", nil, "stemcell:latest", "", "", "", false, ui, nil, cache, nil)
+		require.NoError(t, err)
+
+		var lock sync.Mutex
+		var compiled []string
+		c.compilePackage = func(c *Compilator, pkg *model.Package) error {
+			lock.Lock()
+			compiled = append(compiled, pkg.Name)
+			lock.Unlock()
+			createCompiledPackageDir(t, pkg.GetPackageCompiledDir(c.hostWorkDir), pkg.Name)
+			return nil
+		}
+
+		require.NoError(t, c.Compile(1, []*model.Release{release}, nil, nil, false, false))
+		return compiled
+	}
+
+	assert.Len(t, compile(filepath.Join(tempDir, "first")), len(release.Packages),
+		"All packages should be compiled with an empty cache")
+	assert.Empty(t, compile(filepath.Join(tempDir, "second")),
+		"No packages should be compiled when all are in the cache")
+
+	assert.NoError(t, util.ValidatePath(filepath.Join(tempDir, "cache", "0123456789abcdef"), true, "package cache stemcell directory"))
+
+	for _, pkg := range release.Packages {
+		compiledDir := pkg.GetPackageCompiledDir(filepath.Join(tempDir, "second"))
+		assert.NoError(t, util.ValidatePath(filepath.Join(compiledDir, "bin", pkg.Name), false, "cached package binary"))
+	}
+}
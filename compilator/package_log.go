@@ -0,0 +1,126 @@
+package compilator
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/SUSE/fissile/docker"
+	"github.com/SUSE/fissile/model"
+	"github.com/SUSE/fissile/util"
+
+	"github.com/fatih/color"
+)
+
+// PackageLogPath returns the path of the compile log of the package, in the
+// log directory
+func PackageLogPath(logDir string, pkg *model.Package) string {
+	return filepath.Join(logDir, pkg.Release.Name, fmt.Sprintf("%s-%s.log", pkg.Name, pkg.Fingerprint))
+}
+
+// packageLog collects the output of compiling a package: colored in memory,
+// to show it if the compilation fails, and as is in the log file of the
+// package, if the compilator has a log directory
+type packageLog struct {
+	buffer *bytes.Buffer
+	file   *os.File
+	path   string
+	stdout io.WriteCloser
+	stderr io.WriteCloser
+}
+
+// newPackageLog creates the log for compiling the package, replacing the log
+// of an earlier compilation
+func (c *Compilator) newPackageLog(pkg *model.Package) (*packageLog, error) {
+	log := &packageLog{buffer: new(bytes.Buffer)}
+	bufferWriter := util.NewSyncedWriter(log.buffer)
+
+	stdoutWriters := []io.Writer{
+		docker.NewFormattingWriter(
+			bufferWriter,
+			func(line string) string {
+				return color.GreenString("compilation-%s > %s", color.MagentaString("%s", pkg.Name), color.WhiteString("%s", line))
+			},
+		),
+	}
+	stderrWriters := []io.Writer{
+		docker.NewFormattingWriter(
+			bufferWriter,
+			func(line string) string {
+				return color.GreenString("compilation-%s > %s", color.MagentaString("%s", pkg.Name), color.RedString("%s", line))
+			},
+		),
+	}
+
+	if c.logDir != "" {
+		log.path = PackageLogPath(c.logDir, pkg)
+		if err := os.MkdirAll(filepath.Dir(log.path), 0755); err != nil {
+			return nil, fmt.Errorf("Error creating log directory for package %s: %s", pkg.Name, err.Error())
+		}
+
+		file, err := os.Create(log.path)
+		if err != nil {
+			return nil, fmt.Errorf("Error creating log for package %s: %s", pkg.Name, err.Error())
+		}
+		log.file = file
+
+		fileWriter := util.NewSyncedWriter(file)
+		plain := func(line string) string { return line }
+		stdoutWriters = append(stdoutWriters, docker.NewFormattingWriter(fileWriter, plain))
+		stderrWriters = append(stderrWriters, docker.NewFormattingWriter(fileWriter, plain))
+	}
+
+	log.stdout = multiWriteCloser(stdoutWriters)
+	log.stderr = multiWriteCloser(stderrWriters)
+
+	return log, nil
+}
+
+// fail shows the collected output, and returns the error pointing to the log
+// file
+func (l *packageLog) fail(ui io.Writer, err error) error {
+	l.stdout.Close()
+	l.stderr.Close()
+	l.buffer.WriteTo(ui)
+	if l.path == "" {
+		return err
+	}
+	return fmt.Errorf("%s (log: %s)", err.Error(), l.path)
+}
+
+// Close flushes the output and closes the log file
+func (l *packageLog) Close() error {
+	l.stdout.Close()
+	l.stderr.Close()
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// multiWriteCloser writes to all its writers, and closes those that are
+// io.Closers, so that formatting writers flush their last line
+type multiWriteCloser []io.Writer
+
+func (m multiWriteCloser) Write(data []byte) (int, error) {
+	for _, writer := range m {
+		if _, err := writer.Write(data); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (m multiWriteCloser) Close() error {
+	var result error
+	for _, writer := range m {
+		if closer, ok := writer.(io.Closer); ok {
+			if err := closer.Close(); err != nil && result == nil {
+				result = err
+			}
+		}
+	}
+	return result
+}
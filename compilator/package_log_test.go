@@ -0,0 +1,68 @@
+package compilator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SUSE/fissile/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageLog(t *testing.T) {
+	assert := assert.New(t)
+
+	tempDir, err := ioutil.TempDir("", "fissile-tests")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	logDir := filepath.Join(tempDir, "logs")
+	c, err := NewDockerCompilator(nil, tempDir, logDir, nil, "", "", "", "", false, ui, nil, nil, nil)
+	require.NoError(t, err)
+
+	pkg := &model.Package{Release: &model.Release{Name: "tor"}, Name: "libevent", Fingerprint: "aaaa"}
+	path := PackageLogPath(logDir, pkg)
+	assert.Equal(filepath.Join(logDir, "tor", "libevent-aaaa.log"), path)
+
+	log, err := c.newPackageLog(pkg)
+	require.NoError(t, err)
+
+	fmt.Fprint(log.stdout, "configure\nmake")
+	fmt.Fprintln(log.stderr, "make: *** [all] Error 1")
+
+	output := &bytes.Buffer{}
+	err = log.fail(output, errors.New("Error - compilation for package libevent exited with code 2"))
+	assert.EqualError(err, fmt.Sprintf("Error - compilation for package libevent exited with code 2 (log: %s)", path))
+	assert.Contains(output.String(), "compilation-libevent > ")
+	assert.Contains(output.String(), "make: *** [all] Error 1")
+	require.NoError(t, log.Close())
+
+	contents, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal("configure\nmake: *** [all] Error 1\nmake\n", string(contents), "The log file should have the plain output")
+}
+
+func TestPackageLogWithoutLogDir(t *testing.T) {
+	assert := assert.New(t)
+
+	c, err := NewDockerCompilator(nil, "", "", nil, "", "", "", "", false, ui, nil, nil, nil)
+	require.NoError(t, err)
+
+	pkg := &model.Package{Release: &model.Release{Name: "tor"}, Name: "libevent", Fingerprint: "aaaa"}
+	log, err := c.newPackageLog(pkg)
+	require.NoError(t, err)
+	defer log.Close()
+
+	fmt.Fprintln(log.stdout, "configure")
+
+	output := &bytes.Buffer{}
+	err = log.fail(output, errors.New("Error compiling package libevent"))
+	assert.EqualError(err, "Error compiling package libevent")
+	assert.Contains(output.String(), "configure")
+}
@@ -0,0 +1,121 @@
+package kube
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/SUSE/fissile/helm"
+)
+
+// ClusterVersion is the version of the Kubernetes cluster configurations are
+// generated for
+type ClusterVersion struct {
+	Major int
+	Minor int
+}
+
+var clusterVersionRegexp = regexp.MustCompile(`^v?(\d+)\.(\d+)(\.\d+)?$`)
+
+// ParseClusterVersion parses a version such as 1.9 or v1.10.3; an empty string
+// has no version
+func ParseClusterVersion(version string) (*ClusterVersion, error) {
+	if version == "" {
+		return nil, nil
+	}
+
+	match := clusterVersionRegexp.FindStringSubmatch(version)
+	if match == nil {
+		return nil, fmt.Errorf("Invalid kubernetes version %s, expected <major>.<minor>", version)
+	}
+
+	// The regular expression only matches digits
+	major, _ := strconv.Atoi(match[1])
+	minor, _ := strconv.Atoi(match[2])
+
+	return &ClusterVersion{Major: major, Minor: minor}, nil
+}
+
+// AtLeast returns true if the version is the given one or newer
+func (v ClusterVersion) AtLeast(major, minor int) bool {
+	return v.Major > major || (v.Major == major && v.Minor >= minor)
+}
+
+// String returns the version as <major>.<minor>
+func (v ClusterVersion) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// apiGroupVersion is the API group version of a kind of resource: the
+// current one, available since the given kubernetes version, and the legacy
+// one older clusters need
+type apiGroupVersion struct {
+	current string
+	major   int
+	minor   int
+	legacy  string
+}
+
+// apiGroupVersions lists the kinds of resources whose API group version
+// depends on the kubernetes version
+var apiGroupVersions = map[string]apiGroupVersion{
+	"Deployment":          {"apps/v1", 1, 9, "extensions/v1beta1"},
+	"StatefulSet":         {"apps/v1", 1, 9, "apps/v1beta1"},
+	"Role":                {"rbac.authorization.k8s.io/v1", 1, 8, "rbac.authorization.k8s.io/v1beta1"},
+	"RoleBinding":         {"rbac.authorization.k8s.io/v1", 1, 8, "rbac.authorization.k8s.io/v1beta1"},
+	"PodDisruptionBudget": {"policy/v1", 1, 21, "policy/v1beta1"},
+	// v2beta2 has the same schema as v2, unlike v2beta1
+	"HorizontalPodAutoscaler": {"autoscaling/v2", 1, 23, "autoscaling/v2beta2"},
+	// The schema of backends differs, see addVersioned
+	"Ingress": {"networking.k8s.io/v1", 1, 19, "extensions/v1beta1"},
+}
+
+// apiVersion returns the apiVersion of the kind of resource.  With a target
+// kubernetes version in the settings, that version decides.  Otherwise helm
+// charts choose by the version of the cluster they are installed into, and
+// plain kube configurations use the current API group version.
+func (settings ExportSettings) apiVersion(kind string) string {
+	version, ok := apiGroupVersions[kind]
+	if !ok {
+		panic(fmt.Sprintf("No API group version known for %s", kind))
+	}
+
+	if settings.KubeVersion != nil {
+		if settings.KubeVersion.AtLeast(version.major, version.minor) {
+			return version.current
+		}
+		return version.legacy
+	}
+
+	if settings.CreateHelmChart {
+		return fmt.Sprintf("{{ if %s -}} %s {{- else -}} %s {{- end }}",
+			minKubeVersion(version.major, version.minor), version.current, version.legacy)
+	}
+
+	return version.current
+}
+
+// addVersioned adds an entry to the mapping which only exists in the schema of
+// the current API group version of the kind of resource, or, if current is
+// false, of the legacy one.  Helm charts choosing by the version of the
+// cluster get the entry conditionally.
+func (settings ExportSettings) addVersioned(mapping *helm.Mapping, kind string, current bool, name string, value interface{}) {
+	version, ok := apiGroupVersions[kind]
+	if !ok {
+		panic(fmt.Sprintf("No API group version known for %s", kind))
+	}
+
+	if settings.KubeVersion == nil && settings.CreateHelmChart {
+		condition := minKubeVersion(version.major, version.minor)
+		if !current {
+			condition = fmt.Sprintf("not (%s)", condition)
+		}
+		mapping.Add(name, value, helm.Block("if "+condition))
+		return
+	}
+
+	isCurrent := settings.KubeVersion == nil || settings.KubeVersion.AtLeast(version.major, version.minor)
+	if isCurrent == current {
+		mapping.Add(name, value)
+	}
+}
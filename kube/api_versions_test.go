@@ -0,0 +1,83 @@
+package kube
+
+import (
+	"testing"
+
+	"github.com/SUSE/fissile/helm"
+	"github.com/SUSE/fissile/testhelpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseClusterVersion(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	version, err := ParseClusterVersion("")
+	assert.NoError(err)
+	assert.Nil(version)
+
+	for input, expected := range map[string]ClusterVersion{
+		"1.9":     {1, 9},
+		"v1.10":   {1, 10},
+		"1.11.3":  {1, 11},
+		"v2.0.12": {2, 0},
+	} {
+		version, err := ParseClusterVersion(input)
+		if assert.NoError(err, input) && assert.NotNil(version, input) {
+			assert.Equal(expected, *version, input)
+		}
+	}
+
+	for _, input := range []string{"1", "latest", "1.9beta", "x1.9"} {
+		_, err := ParseClusterVersion(input)
+		assert.Error(err, input)
+	}
+
+	assert.True(ClusterVersion{1, 9}.AtLeast(1, 9))
+	assert.True(ClusterVersion{2, 0}.AtLeast(1, 21))
+	assert.False(ClusterVersion{1, 8}.AtLeast(1, 9))
+	assert.Equal("1.21", ClusterVersion{1, 21}.String())
+}
+
+func TestAPIVersion(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	settings := ExportSettings{}
+	assert.Equal("apps/v1", settings.apiVersion("Deployment"), "Kube configurations should default to current versions")
+	assert.Equal("rbac.authorization.k8s.io/v1", settings.apiVersion("Role"))
+
+	settings.KubeVersion = &ClusterVersion{1, 8}
+	assert.Equal("extensions/v1beta1", settings.apiVersion("Deployment"))
+	assert.Equal("apps/v1beta1", settings.apiVersion("StatefulSet"))
+	assert.Equal("rbac.authorization.k8s.io/v1", settings.apiVersion("RoleBinding"))
+	assert.Equal("policy/v1beta1", settings.apiVersion("PodDisruptionBudget"))
+
+	settings.CreateHelmChart = true
+	assert.Equal("extensions/v1beta1", settings.apiVersion("Deployment"), "The target version should decide for helm charts too")
+
+	assert.Panics(func() { settings.apiVersion("Gadget") })
+}
+
+func TestAPIVersionHelm(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	settings := ExportSettings{CreateHelmChart: true}
+	node := helm.NewMapping("apiVersion", settings.apiVersion("StatefulSet"))
+
+	for minor, expected := range map[string]string{
+		"8":  "apps/v1beta1",
+		"9":  "apps/v1",
+		"10": "apps/v1",
+	} {
+		config := map[string]interface{}{
+			"Capabilities.KubeVersion.Minor": minor,
+		}
+		actual, err := RoundtripNode(node, config)
+		require.NoError(t, err)
+		testhelpers.IsYAMLEqualString(assert, "apiVersion: "+expected, actual)
+	}
+}
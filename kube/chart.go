@@ -0,0 +1,263 @@
+package kube
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/SUSE/fissile/helm"
+	"github.com/SUSE/fissile/model"
+)
+
+// defaultChartVersion is the version of helm charts when none is given
+const defaultChartVersion = "0.1.0"
+
+// chartHelpers is the _helpers.tpl template of helm charts, with the named
+// templates shared by the templates of all roles
+const chartHelpers = `{{/*
+The name of the chart, as a label value.
+*/}}
+{{- define "fissile.name" -}}
+{{ .Chart.Name | trunc 63 | trimSuffix "-" }}
+{{- end -}}
+
+{{/*
+The name and version of the chart, as a label value.
+*/}}
+{{- define "fissile.chart" -}}
+{{ printf "%s-%s" .Chart.Name .Chart.Version | replace "+" "_" | trunc 63 | trimSuffix "-" }}
+{{- end -}}
+`
+
+var chartMaintainerRegexp = regexp.MustCompile(`^\s*([^<]*?)\s*(?:<([^>]*)>)?\s*$`)
+
+// MakeChart returns the Chart.yaml of the helm chart.  The chart is named
+// after the output directory unless the settings name it, and its app version
+// lists the versions of the releases used by the roles.
+func MakeChart(settings ExportSettings) (helm.Node, error) {
+	name := settings.ChartName
+	if name == "" {
+		outputDir, err := filepath.Abs(settings.OutputDir)
+		if err != nil {
+			return nil, fmt.Errorf("Error determining the chart name: %s", err.Error())
+		}
+		name = filepath.Base(outputDir)
+	}
+	version := settings.ChartVersion
+	if version == "" {
+		version = defaultChartVersion
+	}
+
+	chart := helm.NewMapping()
+	chart.Add("apiVersion", "v1")
+	chart.Add("name", name)
+	chart.Add("version", version)
+	if appVersion := chartAppVersion(settings.RoleManifest); appVersion != "" {
+		chart.Add("appVersion", appVersion)
+	}
+	if settings.ChartDescription != "" {
+		chart.Add("description", settings.ChartDescription)
+	}
+	if settings.KubeVersion != nil {
+		// Charts for a given kubernetes version use its API group versions
+		chart.Add("kubeVersion", fmt.Sprintf(">=%s.0-0", settings.KubeVersion))
+	}
+	if settings.ChartIcon != "" {
+		chart.Add("icon", settings.ChartIcon)
+	}
+	if len(settings.ChartMaintainers) > 0 {
+		maintainers := helm.NewList()
+		for _, maintainer := range settings.ChartMaintainers {
+			match := chartMaintainerRegexp.FindStringSubmatch(maintainer)
+			if match == nil || match[1] == "" {
+				return nil, fmt.Errorf("Invalid chart maintainer %s, expected <name> or <name> <<email>>", maintainer)
+			}
+			entry := helm.NewMapping("name", match[1])
+			if match[2] != "" {
+				entry.Add("email", match[2])
+			}
+			maintainers.Add(entry)
+		}
+		chart.Add("maintainers", maintainers)
+	}
+
+	return chart, nil
+}
+
+// chartAppVersion returns the version of the only release used by the roles,
+// or the names and versions of all of them, in the order they are used
+func chartAppVersion(roleManifest *model.RoleManifest) string {
+	var releases []*model.Release
+	seen := make(map[string]bool)
+	for _, role := range roleManifest.Roles {
+		for _, roleJob := range role.RoleJobs {
+			release := roleJob.Release
+			if release == nil || seen[release.Name] {
+				continue
+			}
+			seen[release.Name] = true
+			releases = append(releases, release)
+		}
+	}
+
+	if len(releases) == 1 {
+		return releases[0].Version
+	}
+	var versions []string
+	for _, release := range releases {
+		versions = append(versions, fmt.Sprintf("%s-%s", release.Name, release.Version))
+	}
+	return strings.Join(versions, ", ")
+}
+
+// MakeHelpers returns the _helpers.tpl template of the helm chart
+func MakeHelpers() string {
+	return chartHelpers
+}
+
+// addChartLabels adds the labels identifying the helm release and chart to the
+// metadata of an object; plain kube configs have none
+func addChartLabels(meta *helm.Mapping, settings ExportSettings) {
+	if !settings.CreateHelmChart {
+		return
+	}
+	labels, ok := meta.Get("labels").(*helm.Mapping)
+	if !ok {
+		labels = helm.NewMapping()
+		meta.Add("labels", labels)
+	}
+	labels.Add("app.kubernetes.io/instance", "{{ .Release.Name | quote }}")
+	labels.Add("app.kubernetes.io/managed-by", "{{ .Release.Service | quote }}")
+	labels.Add("app.kubernetes.io/name", `{{ include "fissile.name" . }}`)
+	labels.Add("helm.sh/chart", `{{ include "fissile.chart" . }}`)
+}
+
+// MakeNotes returns the NOTES.txt template of the helm chart, listing the
+// public endpoints of the roles: their public services, and their ingresses
+// when enabled
+func MakeNotes(settings ExportSettings) string {
+	var services, ingresses bytes.Buffer
+	// Whether all public ports have an ingress
+	allIngress := true
+	for _, role := range settings.RoleManifest.Roles {
+		if role.Type != model.RoleTypeBosh || role.Run.FlightStage == model.FlightStageManual {
+			continue
+		}
+		var ports []notesServicePort
+		plain := false
+		for _, port := range role.Run.ExposedPorts {
+			if !port.Public {
+				continue
+			}
+			ports = append(ports, notesServicePort{
+				description: notesPortDescription(role, port),
+				ingress:     port.Ingress != nil,
+			})
+			if port.Ingress == nil {
+				plain = true
+				continue
+			}
+			host, _, ok := ingressHostName(port.Ingress.Host, settings)
+			if !ok {
+				host = "<any host>"
+			}
+			scheme := "http"
+			if port.Ingress.TLS {
+				scheme = "https"
+			}
+			fmt.Fprintf(&ingresses, "  %s://%s%s (%s %s)\n", scheme, host, port.Ingress.Path, role.Name, port.Name)
+		}
+		if plain {
+			allIngress = false
+			fmt.Fprintf(&services, "  %s-public: %s\n", role.Name, notesServicePorts(ports))
+		} else if len(ports) > 0 {
+			// The public service only exists along with the ingress ports,
+			// as in newService
+			var descriptions []string
+			for _, port := range ports {
+				descriptions = append(descriptions, port.description)
+			}
+			fmt.Fprintf(&services, "{{- if %s }}\n  %s-public: %s\n{{- end }}\n",
+				publicIngressPortCondition, role.Name, strings.Join(descriptions, ", "))
+		}
+	}
+
+	var notes bytes.Buffer
+	notes.WriteString("{{ .Chart.Name }} has been installed as release {{ .Release.Name }} in namespace {{ .Release.Namespace }}.\n")
+	if services.Len() > 0 {
+		if allIngress {
+			fmt.Fprintf(&notes, "{{- if %s }}\n", publicIngressPortCondition)
+		}
+		notes.WriteString(`
+Public endpoints:
+{{- if .Values.services.loadbalanced }}
+The following load balanced services expose them; their external addresses are
+listed by: kubectl get services --namespace {{ .Release.Namespace }}
+{{- else }}
+The following services expose them on the external IPs {{ join ", " .Values.kube.external_ips }}:
+{{- end }}
+`)
+		notes.Write(services.Bytes())
+		if allIngress {
+			notes.WriteString("{{- end }}\n")
+		}
+	}
+	if ingresses.Len() > 0 {
+		notes.WriteString(`{{- if .Values.services.ingress.enabled }}
+
+The following ingresses route HTTP(S) traffic to public ports:
+`)
+		notes.Write(ingresses.Bytes())
+		notes.WriteString("{{- end }}\n")
+	}
+	return notes.String()
+}
+
+// notesServicePort is a port of a public service, which only exposes the
+// ports with an ingress if the ingress is disabled or public services are kept
+type notesServicePort struct {
+	description string
+	ingress     bool
+}
+
+// notesServicePorts lists the ports of a public service, in order; the ports
+// with an ingress are conditional, so they bring their own separators
+func notesServicePorts(ports []notesServicePort) string {
+	var result bytes.Buffer
+	plain := false
+	for _, port := range ports {
+		switch {
+		case !port.ingress:
+			if plain {
+				result.WriteString(", ")
+			}
+			result.WriteString(port.description)
+			plain = true
+		case plain:
+			fmt.Fprintf(&result, "{{ if %s }}, %s{{ end }}", publicIngressPortCondition, port.description)
+		default:
+			fmt.Fprintf(&result, "{{ if %s }}%s, {{ end }}", publicIngressPortCondition, port.description)
+		}
+	}
+	return result.String()
+}
+
+// notesPortDescription describes a public port: its name, protocol and
+// external port number, or the first of its port numbers for port ranges
+func notesPortDescription(role *model.Role, port *model.RoleRunExposedPort) string {
+	sizing := fmt.Sprintf(".Values.sizing.%s.ports.%s", makeVarName(role.Name), makeVarName(port.Name))
+	first := fmt.Sprintf("%d", port.ExternalPort)
+	if port.PortIsConfigurable {
+		first = fmt.Sprintf("{{ %s.port }}", sizing)
+	}
+	if port.Max <= 1 {
+		return fmt.Sprintf("%s %s/%s", port.Name, first, port.Protocol)
+	}
+	count := fmt.Sprintf("%d", port.Count)
+	if port.CountIsConfigurable {
+		count = fmt.Sprintf("{{ %s.count }}", sizing)
+	}
+	return fmt.Sprintf("%s %s ports from %s/%s", port.Name, count, first, port.Protocol)
+}
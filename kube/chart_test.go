@@ -0,0 +1,228 @@
+package kube
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"text/template"
+
+	"github.com/SUSE/fissile/model"
+	"github.com/SUSE/fissile/testhelpers"
+
+	"github.com/Masterminds/sprig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMakeChart(t *testing.T) {
+	t.Parallel()
+	manifest := testLoadManifest(t, "ingress.yml")
+
+	t.Run("Defaults", func(t *testing.T) {
+		t.Parallel()
+		chart, err := MakeChart(ExportSettings{
+			OutputDir:    "/charts/my-chart",
+			RoleManifest: manifest,
+		})
+		require.NoError(t, err)
+		actual, err := RoundtripKube(chart)
+		require.NoError(t, err)
+		testhelpers.IsYAMLEqualString(assert.New(t), `---
+			apiVersion: "v1"
+			name: "my-chart"
+			version: "0.1.0"
+		`, actual)
+	})
+
+	t.Run("Metadata", func(t *testing.T) {
+		t.Parallel()
+		chart, err := MakeChart(ExportSettings{
+			OutputDir:        "/charts/my-chart",
+			RoleManifest:     manifest,
+			KubeVersion:      &ClusterVersion{Major: 1, Minor: 19},
+			ChartName:        "web",
+			ChartVersion:     "2.1.0",
+			ChartDescription: "A web application",
+			ChartIcon:        "https://example.com/icon.png",
+			ChartMaintainers: []string{"Jane Doe <jane@example.com>", " Ops Team "},
+		})
+		require.NoError(t, err)
+		actual, err := RoundtripKube(chart)
+		require.NoError(t, err)
+		testhelpers.IsYAMLEqualString(assert.New(t), `---
+			apiVersion: "v1"
+			name: "web"
+			version: "2.1.0"
+			description: "A web application"
+			kubeVersion: ">=1.19.0-0"
+			icon: "https://example.com/icon.png"
+			maintainers:
+			-	name: "Jane Doe"
+				email: "jane@example.com"
+			-	name: "Ops Team"
+		`, actual)
+	})
+
+	t.Run("InvalidMaintainer", func(t *testing.T) {
+		t.Parallel()
+		_, err := MakeChart(ExportSettings{
+			OutputDir:        "/charts/my-chart",
+			RoleManifest:     manifest,
+			ChartMaintainers: []string{"<jane@example.com>"},
+		})
+		assert.EqualError(t, err, "Invalid chart maintainer <jane@example.com>, expected <name> or <name> <<email>>")
+	})
+}
+
+func TestMakeChartAppVersion(t *testing.T) {
+	t.Parallel()
+	manifest := testLoadManifest(t, "network-policies.yml")
+
+	var versions []interface{}
+	for _, name := range []string{"ntp", "client"} {
+		role := manifest.LookupRole(name)
+		require.NotNil(t, role)
+		release := role.RoleJobs[0].Release
+		versions = append(versions, release.Name, release.Version)
+	}
+
+	chart, err := MakeChart(ExportSettings{OutputDir: "my-chart", RoleManifest: manifest})
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("%s-%s, %s-%s", versions...), chart.Get("appVersion").String())
+}
+
+func TestMakeHelpers(t *testing.T) {
+	t.Parallel()
+
+	helpers, err := template.New("_helpers.tpl").Funcs(sprig.TxtFuncMap()).Parse(MakeHelpers())
+	require.NoError(t, err)
+
+	data := map[string]interface{}{
+		"Chart": map[string]interface{}{
+			"Name":    "my-chart",
+			"Version": "1.0.0+dev.3",
+		},
+	}
+	for name, expected := range map[string]string{
+		"fissile.name":  "my-chart",
+		"fissile.chart": "my-chart-1.0.0_dev.3",
+	} {
+		var actual bytes.Buffer
+		if assert.NoError(t, helpers.ExecuteTemplate(&actual, name, data)) {
+			assert.Equal(t, expected, actual.String(), name)
+		}
+	}
+}
+
+func TestMakeNotes(t *testing.T) {
+	t.Parallel()
+	manifest := testLoadManifest(t, "ingress.yml")
+
+	notes, err := template.New("NOTES.txt").Funcs(sprig.TxtFuncMap()).Parse(MakeNotes(ExportSettings{
+		CreateHelmChart: true,
+		RoleManifest:    manifest,
+	}))
+	require.NoError(t, err)
+
+	render := func(notes *template.Template, loadbalanced, ingress, keepPublic bool) string {
+		var actual bytes.Buffer
+		require.NoError(t, notes.Execute(&actual, map[string]interface{}{
+			"Chart":   map[string]interface{}{"Name": "my-chart"},
+			"Release": map[string]interface{}{"Name": "demo", "Namespace": "apps"},
+			"Values": map[string]interface{}{
+				"kube": map[string]interface{}{"external_ips": []string{"192.0.2.42"}},
+				"services": map[string]interface{}{
+					"loadbalanced": loadbalanced,
+					"ingress": map[string]interface{}{
+						"enabled":              ingress,
+						"domain":               "example.com",
+						"keep_public_services": keepPublic,
+					},
+				},
+			},
+		}))
+		return actual.String()
+	}
+
+	assert.Equal(t, `my-chart has been installed as release demo in namespace apps.
+
+Public endpoints:
+The following services expose them on the external IPs 192.0.2.42:
+  web-public: http 8080/TCP, api 9000/TCP, admin 9443/TCP
+  mixed-public: http 8080/TCP, ssh 2222/TCP
+`, render(notes, false, false, false))
+
+	t.Run("Ingress replaces public services", func(t *testing.T) {
+		assert.Equal(t, `my-chart has been installed as release demo in namespace apps.
+
+Public endpoints:
+The following load balanced services expose them; their external addresses are
+listed by: kubectl get services --namespace apps
+  mixed-public: ssh 2222/TCP
+
+The following ingresses route HTTP(S) traffic to public ports:
+  http://www.example.com/ (web http)
+  https://www.example.com/api (web api)
+  https://admin.example.com/ (web admin)
+  http://example.com/ (mixed http)
+`, render(notes, true, true, false))
+	})
+
+	t.Run("Ingress keeps public services", func(t *testing.T) {
+		assert.Equal(t, `my-chart has been installed as release demo in namespace apps.
+
+Public endpoints:
+The following services expose them on the external IPs 192.0.2.42:
+  web-public: http 8080/TCP, api 9000/TCP, admin 9443/TCP
+  mixed-public: http 8080/TCP, ssh 2222/TCP
+
+The following ingresses route HTTP(S) traffic to public ports:
+  http://www.example.com/ (web http)
+  https://www.example.com/api (web api)
+  https://admin.example.com/ (web admin)
+  http://example.com/ (mixed http)
+`, render(notes, false, true, true))
+	})
+
+	t.Run("All ports have an ingress", func(t *testing.T) {
+		webManifest := *manifest
+		webManifest.Roles = model.Roles{manifest.LookupRole("web")}
+		webNotes, err := template.New("NOTES.txt").Funcs(sprig.TxtFuncMap()).Parse(MakeNotes(ExportSettings{
+			CreateHelmChart: true,
+			RoleManifest:    &webManifest,
+		}))
+		require.NoError(t, err)
+
+		assert.Equal(t, `my-chart has been installed as release demo in namespace apps.
+
+The following ingresses route HTTP(S) traffic to public ports:
+  http://www.example.com/ (web http)
+  https://www.example.com/api (web api)
+  https://admin.example.com/ (web admin)
+`, render(webNotes, false, true, false))
+
+		assert.Equal(t, `my-chart has been installed as release demo in namespace apps.
+
+Public endpoints:
+The following services expose them on the external IPs 192.0.2.42:
+  web-public: http 8080/TCP, api 9000/TCP, admin 9443/TCP
+`, render(webNotes, false, false, false))
+	})
+}
+
+func TestMakeNotesPortRanges(t *testing.T) {
+	t.Parallel()
+
+	role := &model.Role{Name: "my-role"}
+	assert.Equal(t, "dns 53/UDP", notesPortDescription(role, &model.RoleRunExposedPort{
+		Name: "dns", Protocol: "UDP", ExternalPort: 53, Count: 1, Max: 1,
+	}))
+	assert.Equal(t, "rtp 4 ports from 3000/UDP", notesPortDescription(role, &model.RoleRunExposedPort{
+		Name: "rtp", Protocol: "UDP", ExternalPort: 3000, Count: 4, Max: 4,
+	}))
+	assert.Equal(t, "tcp-route {{ .Values.sizing.my_role.ports.tcp_route.count }} ports from {{ .Values.sizing.my_role.ports.tcp_route.port }}/TCP",
+		notesPortDescription(role, &model.RoleRunExposedPort{
+			Name: "tcp-route", Protocol: "TCP", ExternalPort: 20000, Count: 2, Max: 10,
+			PortIsConfigurable: true, CountIsConfigurable: true,
+		}))
+}
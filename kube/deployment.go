@@ -24,7 +24,7 @@ func NewDeployment(role *model.Role, settings ExportSettings, grapher util.Model
 	spec.Add("selector", newSelector(role.Name))
 	spec.Add("template", podTemplate)
 
-	deployment := newKubeConfig("extensions/v1beta1", "Deployment", role.Name, helm.Comment(role.GetLongDescription()))
+	deployment := newKubeConfig(settings, settings.apiVersion("Deployment"), "Deployment", role.Name, helm.Comment(role.GetLongDescription()))
 	deployment.Add("spec", spec)
 	err = replicaCheck(role, deployment, svc, settings)
 	if err != nil {
@@ -157,7 +157,12 @@ func replicaCheck(role *model.Role, controller *helm.Mapping, service helm.Node,
 	} else {
 		count = "{{ " + count + " }}"
 	}
-	spec.Add("replicas", count)
+	if role.Run.Autoscaling != nil {
+		// The autoscaler owns the replica count when enabled
+		spec.Add("replicas", count, helm.Block(fmt.Sprintf("if not .Values.sizing.%s.autoscaling.enabled", roleName)))
+	} else {
+		spec.Add("replicas", count)
+	}
 	spec.Sort()
 
 	if role.Run.Scaling.Min == 0 {
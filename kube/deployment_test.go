@@ -212,6 +212,10 @@ func TestNewDeploymentHelm(t *testing.T) {
 			metadata:
 				name: "role"
 				labels:
+					app.kubernetes.io/instance: "fissile"
+					app.kubernetes.io/managed-by: "Tiller"
+					app.kubernetes.io/name: "fissile.name"
+					helm.sh/chart: "fissile.chart"
 					skiff-role-name: "role"
 			spec:
 				replicas: 1
@@ -268,6 +272,7 @@ func TestNewDeploymentHelm(t *testing.T) {
 						imagePullSecrets:
 						- name: "registry-credentials"
 						restartPolicy: "Always"
+						securityContext: ~
 						terminationGracePeriodSeconds: 600
 						volumes: ~
 		`, actual)
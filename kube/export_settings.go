@@ -21,4 +21,18 @@ type ExportSettings struct {
 	Opinions        *model.Opinions
 	CreateHelmChart bool
 	AuthType        string
+	// KubeVersion is the kubernetes version to generate configurations
+	// for, selecting the API group versions of resources; nil for the
+	// current ones, or, for helm charts, by the version of the cluster
+	KubeVersion *ClusterVersion
+	// NetworkPolicies generates a NetworkPolicy for each role, restricting
+	// the traffic to its pods to what its exposed ports and links need
+	NetworkPolicies bool
+	// ChartName, ChartVersion, ChartDescription, ChartIcon and
+	// ChartMaintainers ("<name> <<email>>") are the metadata of helm charts
+	ChartName        string
+	ChartVersion     string
+	ChartDescription string
+	ChartIcon        string
+	ChartMaintainers []string
 }
@@ -0,0 +1,112 @@
+package kube
+
+import (
+	"fmt"
+
+	"github.com/SUSE/fissile/helm"
+	"github.com/SUSE/fissile/model"
+)
+
+// NewHorizontalPodAutoscaler creates a HorizontalPodAutoscaler scaling the
+// controller of an autoscaled role between its minimum and maximum instance
+// counts.  It returns nil for roles without autoscaling, and for roles
+// without instances in plain kube configurations, which have no controller.
+func NewHorizontalPodAutoscaler(role *model.Role, settings ExportSettings) (helm.Node, error) {
+	if role.Run == nil || role.Run.Autoscaling == nil {
+		return nil, nil
+	}
+	if role.Run.Scaling == nil {
+		return nil, fmt.Errorf("Role %s has no scaling information", role.Name)
+	}
+	if !settings.CreateHelmChart && role.Run.Scaling.Min == 0 {
+		// The autoscaler would start the instances the role has not
+		return nil, nil
+	}
+
+	kind := "StatefulSet"
+	if role.Run.Controller == model.RoleControllerDeployment {
+		kind = "Deployment"
+	}
+	target := helm.NewMapping()
+	target.Add("apiVersion", settings.apiVersion(kind))
+	target.Add("kind", kind)
+	target.Add("name", role.Name)
+
+	spec := helm.NewMapping()
+	spec.Add("scaleTargetRef", target)
+
+	autoscaling := role.Run.Autoscaling
+	metrics := helm.NewList()
+	roleName := makeVarName(role.Name)
+	values := fmt.Sprintf(".Values.sizing.%s.autoscaling", roleName)
+
+	if settings.CreateHelmChart {
+		spec.Add("minReplicas", fmt.Sprintf("{{ %s.min_replicas }}", values))
+		spec.Add("maxReplicas", fmt.Sprintf("{{ %s.max_replicas }}", values))
+		for _, resource := range []string{"cpu", "memory"} {
+			utilization := fmt.Sprintf("%s.target_%s_utilization", values, resource)
+			metric := newResourceMetric(resource, fmt.Sprintf("{{ %s }}", utilization))
+			metric.Set(helm.Block("if " + utilization))
+			metrics.Add(metric)
+		}
+	} else {
+		spec.Add("minReplicas", minReplicas(role.Run.Scaling))
+		spec.Add("maxReplicas", role.Run.Scaling.Max)
+		if autoscaling.TargetCPUUtilization != nil {
+			metrics.Add(newResourceMetric("cpu", *autoscaling.TargetCPUUtilization))
+		}
+		if autoscaling.TargetMemoryUtilization != nil {
+			metrics.Add(newResourceMetric("memory", *autoscaling.TargetMemoryUtilization))
+		}
+	}
+	spec.Add("metrics", metrics)
+
+	hpa := newKubeConfig(settings, settings.apiVersion("HorizontalPodAutoscaler"), "HorizontalPodAutoscaler", role.Name)
+	hpa.Add("spec", spec.Sort())
+
+	if settings.CreateHelmChart {
+		condition := fmt.Sprintf("%s.enabled", values)
+		if role.Run.Scaling.Min == 0 {
+			// Matches the guard of the controller; no instances, no autoscaler
+			condition = fmt.Sprintf("and %s (gt (int .Values.sizing.%s.count) 0)", condition, roleName)
+		}
+		hpa.Set(helm.Block("if " + condition))
+
+		// Keep the autoscaler within the bounds of the role
+		fail := fmt.Sprintf(`{{ fail "%s must autoscale from at least %d instances" }}`, roleName, minReplicas(role.Run.Scaling))
+		block := fmt.Sprintf("if lt (int %s.min_replicas) %d", values, minReplicas(role.Run.Scaling))
+		hpa.Add("_minReplicas", fail, helm.Block(block))
+
+		fail = fmt.Sprintf(`{{ fail "%s cannot autoscale to more than %d instances" }}`, roleName, role.Run.Scaling.Max)
+		block = fmt.Sprintf("if gt (int %s.max_replicas) %d", values, role.Run.Scaling.Max)
+		hpa.Add("_maxReplicas", fail, helm.Block(block))
+
+		fail = fmt.Sprintf(`{{ fail "%s must autoscale to more instances than it starts from" }}`, roleName)
+		block = fmt.Sprintf("if le (int %s.max_replicas) (int %s.min_replicas)", values, values)
+		hpa.Add("_rangeReplicas", fail, helm.Block(block))
+
+		hpa.Sort()
+	}
+
+	return hpa, nil
+}
+
+// newResourceMetric creates a metric for a target average utilization of a
+// resource, in percent of the requested resource
+func newResourceMetric(resource string, utilization interface{}) *helm.Mapping {
+	target := helm.NewMapping("type", "Utilization")
+	target.Add("averageUtilization", utilization)
+
+	metric := helm.NewMapping("type", "Resource")
+	metric.Add("resource", helm.NewMapping("name", resource, "target", target))
+	return metric
+}
+
+// minReplicas returns the minimum instance count of an autoscaled role;
+// autoscalers cannot scale down to zero
+func minReplicas(scaling *model.RoleRunScaling) int {
+	if scaling.Min < 1 {
+		return 1
+	}
+	return scaling.Min
+}
@@ -0,0 +1,184 @@
+package kube
+
+import (
+	"testing"
+
+	"github.com/SUSE/fissile/testhelpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHorizontalPodAutoscalerKube(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	manifest := testLoadManifest(t, "autoscaling.yml")
+
+	hpa, err := NewHorizontalPodAutoscaler(manifest.LookupRole("web"), ExportSettings{})
+	require.NoError(t, err)
+	require.NotNil(t, hpa)
+	actual, err := RoundtripKube(hpa)
+	require.NoError(t, err)
+	testhelpers.IsYAMLEqualString(assert, `---
+		apiVersion: "autoscaling/v2"
+		kind: "HorizontalPodAutoscaler"
+		metadata:
+			name: "web"
+			labels:
+				skiff-role-name: "web"
+		spec:
+			scaleTargetRef:
+				apiVersion: "apps/v1"
+				kind: "Deployment"
+				name: "web"
+			minReplicas: 2
+			maxReplicas: 10
+			metrics:
+			-	type: "Resource"
+				resource:
+					name: "cpu"
+					target:
+						type: "Utilization"
+						averageUtilization: 75
+	`, actual)
+
+	cache := manifest.LookupRole("cache")
+	hpa, err = NewHorizontalPodAutoscaler(cache, ExportSettings{})
+	assert.NoError(err)
+	assert.Nil(hpa, "Roles without instances should have no autoscaler")
+
+	cache.Run.Scaling.Min = 1
+	hpa, err = NewHorizontalPodAutoscaler(cache, ExportSettings{KubeVersion: &ClusterVersion{1, 12}})
+	require.NoError(t, err)
+	require.NotNil(t, hpa)
+	actual, err = RoundtripKube(hpa)
+	require.NoError(t, err)
+	testhelpers.IsYAMLSubsetString(assert, `---
+		apiVersion: "autoscaling/v2beta2"
+		spec:
+			scaleTargetRef:
+				apiVersion: "apps/v1"
+				kind: "StatefulSet"
+			minReplicas: 1
+			maxReplicas: 4
+	`, actual)
+	metrics := hpa.Get("spec", "metrics").Values()
+	if assert.Len(metrics, 2) {
+		assert.Equal("memory", metrics[1].Get("resource", "name").String())
+	}
+
+	role := manifest.LookupRole("web")
+	role.Run.Autoscaling = nil
+	hpa, err = NewHorizontalPodAutoscaler(role, ExportSettings{})
+	assert.NoError(err)
+	assert.Nil(hpa, "Roles without autoscaling should have no autoscaler")
+}
+
+func TestNewHorizontalPodAutoscalerHelm(t *testing.T) {
+	t.Parallel()
+	manifest := testLoadManifest(t, "autoscaling.yml")
+	settings := ExportSettings{CreateHelmChart: true}
+
+	hpa, err := NewHorizontalPodAutoscaler(manifest.LookupRole("web"), settings)
+	require.NoError(t, err)
+	require.NotNil(t, hpa)
+
+	t.Run("Configured", func(t *testing.T) {
+		t.Parallel()
+		actual, err := RoundtripNode(hpa, map[string]interface{}{
+			"Values.sizing.web.autoscaling.enabled":                   true,
+			"Values.sizing.web.autoscaling.min_replicas":              "3",
+			"Values.sizing.web.autoscaling.max_replicas":              "8",
+			"Values.sizing.web.autoscaling.target_memory_utilization": "90",
+		})
+		require.NoError(t, err)
+		testhelpers.IsYAMLEqualString(assert.New(t), `---
+			apiVersion: "autoscaling/v2beta2"
+			kind: "HorizontalPodAutoscaler"
+			metadata:
+				name: "web"
+				labels:
+					app.kubernetes.io/instance: "fissile"
+					app.kubernetes.io/managed-by: "Tiller"
+					app.kubernetes.io/name: "fissile.name"
+					helm.sh/chart: "fissile.chart"
+					skiff-role-name: "web"
+			spec:
+				scaleTargetRef:
+					apiVersion: "extensions/v1beta1"
+					kind: "Deployment"
+					name: "web"
+				minReplicas: 3
+				maxReplicas: 8
+				metrics:
+				-	type: "Resource"
+					resource:
+						name: "memory"
+						target:
+							type: "Utilization"
+							averageUtilization: 90
+		`, actual)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		t.Parallel()
+		actual, err := RenderNode(hpa, map[string]interface{}{
+			"Values.sizing.web.autoscaling.enabled": false,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "---\n", string(actual), "The autoscaler should not be rendered")
+	})
+
+	for _, testcase := range []struct {
+		name     string
+		min, max string
+		message  string
+	}{
+		{"too few", "1", "8", "web must autoscale from at least 2 instances"},
+		{"too many", "2", "11", "web cannot autoscale to more than 10 instances"},
+		{"no range", "5", "5", "web must autoscale to more instances than it starts from"},
+	} {
+		testcase := testcase
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := RenderNode(hpa, map[string]interface{}{
+				"Values.sizing.web.autoscaling.enabled":      true,
+				"Values.sizing.web.autoscaling.min_replicas": testcase.min,
+				"Values.sizing.web.autoscaling.max_replicas": testcase.max,
+			})
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), testcase.message)
+			}
+		})
+	}
+}
+
+func TestNewHorizontalPodAutoscalerHelmWithoutInstances(t *testing.T) {
+	t.Parallel()
+	manifest := testLoadManifest(t, "autoscaling.yml")
+
+	hpa, err := NewHorizontalPodAutoscaler(manifest.LookupRole("cache"), ExportSettings{CreateHelmChart: true})
+	require.NoError(t, err)
+	require.NotNil(t, hpa)
+
+	config := map[string]interface{}{
+		"Values.sizing.cache.count":                              "0",
+		"Values.sizing.cache.autoscaling.enabled":                true,
+		"Values.sizing.cache.autoscaling.min_replicas":           "1",
+		"Values.sizing.cache.autoscaling.max_replicas":           "4",
+		"Values.sizing.cache.autoscaling.target_cpu_utilization": "60",
+	}
+	actual, err := RenderNode(hpa, config)
+	require.NoError(t, err)
+	assert.Equal(t, "---\n", string(actual), "The autoscaler of a role without instances should not be rendered")
+
+	config["Values.sizing.cache.count"] = "2"
+	roundtrip, err := RoundtripNode(hpa, config)
+	require.NoError(t, err)
+	testhelpers.IsYAMLSubsetString(assert.New(t), `---
+		kind: "HorizontalPodAutoscaler"
+		spec:
+			minReplicas: 1
+			maxReplicas: 4
+	`, roundtrip)
+}
@@ -0,0 +1,121 @@
+package kube
+
+import (
+	"fmt"
+
+	"github.com/SUSE/fissile/helm"
+	"github.com/SUSE/fissile/model"
+)
+
+// NewIngress creates an Ingress routing HTTP(S) traffic to the exposed ports
+// of a role which declare an ingress, through the private service of the
+// role.  It returns nil for roles without any.
+func NewIngress(role *model.Role, settings ExportSettings) (helm.Node, error) {
+	if role.Run == nil {
+		return nil, fmt.Errorf("Role %s has no run information", role.Name)
+	}
+
+	annotations := helm.NewMapping()
+	if settings.CreateHelmChart {
+		annotations.Add("kubernetes.io/ingress.class", "{{ .Values.services.ingress.class }}",
+			helm.Block("if .Values.services.ingress.class"))
+	}
+
+	// Group the paths by host, in the order of the ports
+	var hosts, tlsHosts []string
+	paths := make(map[string][]helm.Node)
+	tls := make(map[string]bool)
+	for _, port := range role.Run.ExposedPorts {
+		ingress := port.Ingress
+		if ingress == nil {
+			continue
+		}
+		for name, value := range ingress.Annotations {
+			annotations.Add(name, value)
+		}
+		if _, ok := paths[ingress.Host]; !ok {
+			hosts = append(hosts, ingress.Host)
+		}
+		paths[ingress.Host] = append(paths[ingress.Host], newIngressPath(role, port, settings))
+		if ingress.TLS && !tls[ingress.Host] {
+			tls[ingress.Host] = true
+			tlsHosts = append(tlsHosts, ingress.Host)
+		}
+	}
+	if len(hosts) == 0 {
+		return nil, nil
+	}
+
+	spec := helm.NewMapping()
+
+	if len(tlsHosts) > 0 {
+		var hostNames []helm.Node
+		for _, host := range tlsHosts {
+			if name, modifiers, ok := ingressHostName(host, settings); ok {
+				hostNames = append(hostNames, helm.NewNode(name, modifiers...))
+			}
+		}
+		secretName := fmt.Sprintf("%s-ingress-tls", role.Name)
+		if settings.CreateHelmChart {
+			secretName = fmt.Sprintf(`{{ default "%s" .Values.services.ingress.tls_secret }}`, secretName)
+		}
+		entry := helm.NewMapping()
+		if len(hostNames) > 0 {
+			entry.Add("hosts", helm.NewNode(hostNames))
+		}
+		entry.Add("secretName", secretName)
+		spec.Add("tls", helm.NewList(entry))
+	}
+
+	var rules []helm.Node
+	for _, host := range hosts {
+		rule := helm.NewMapping()
+		if name, modifiers, ok := ingressHostName(host, settings); ok {
+			rule.Add("host", name, modifiers...)
+		}
+		rule.Add("http", helm.NewMapping("paths", helm.NewNode(paths[host])))
+		rules = append(rules, rule)
+	}
+	spec.Add("rules", helm.NewNode(rules))
+
+	ingress := newKubeConfig(settings, settings.apiVersion("Ingress"), "Ingress", role.Name)
+	if len(annotations.Names()) > 0 {
+		ingress.Get("metadata").(*helm.Mapping).Add("annotations", annotations.Sort())
+	}
+	ingress.Add("spec", spec.Sort())
+
+	if settings.CreateHelmChart {
+		ingress.Set(helm.Block("if .Values.services.ingress.enabled"))
+	}
+
+	return ingress, nil
+}
+
+// newIngressPath creates the path of an ingress rule, with the service port
+// of the exposed port as its backend
+func newIngressPath(role *model.Role, port *model.RoleRunExposedPort, settings ExportSettings) helm.Node {
+	backend := helm.NewMapping()
+	settings.addVersioned(backend, "Ingress", true, "service",
+		helm.NewMapping("name", role.Name, "port", helm.NewMapping("name", port.Name)))
+	settings.addVersioned(backend, "Ingress", false, "serviceName", role.Name)
+	settings.addVersioned(backend, "Ingress", false, "servicePort", port.Name)
+
+	path := helm.NewMapping("path", port.Ingress.Path)
+	settings.addVersioned(path, "Ingress", true, "pathType", "Prefix")
+	path.Add("backend", backend)
+	return path
+}
+
+// ingressHostName returns the host name of an ingress host, completed by the
+// ingress domain for helm charts.  It returns false for ingresses to any host.
+func ingressHostName(host string, settings ExportSettings) (string, []helm.NodeModifier, bool) {
+	if !settings.CreateHelmChart {
+		return host, nil, host != ""
+	}
+
+	domain := ".Values.services.ingress.domain"
+	if host == "" {
+		return fmt.Sprintf("{{ %s }}", domain), []helm.NodeModifier{helm.Block("if " + domain)}, true
+	}
+	return fmt.Sprintf("%s{{ if %s }}.{{ %s }}{{ end }}", host, domain, domain), nil, true
+}
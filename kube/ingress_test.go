@@ -0,0 +1,195 @@
+package kube
+
+import (
+	"testing"
+
+	"github.com/SUSE/fissile/testhelpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewIngressKube(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	manifest := testLoadManifest(t, "ingress.yml")
+
+	ingress, err := NewIngress(manifest.LookupRole("web"), ExportSettings{})
+	require.NoError(t, err)
+	require.NotNil(t, ingress)
+	actual, err := RoundtripKube(ingress)
+	require.NoError(t, err)
+	testhelpers.IsYAMLEqualString(assert, `---
+		apiVersion: "networking.k8s.io/v1"
+		kind: "Ingress"
+		metadata:
+			name: "web"
+			labels:
+				skiff-role-name: "web"
+			annotations:
+				nginx.ingress.kubernetes.io/proxy-body-size: "10m"
+		spec:
+			tls:
+			-	hosts: ["www", "admin"]
+				secretName: "web-ingress-tls"
+			rules:
+			-	host: "www"
+				http:
+					paths:
+					-	path: "/"
+						pathType: "Prefix"
+						backend:
+							service:
+								name: "web"
+								port:
+									name: "http"
+					-	path: "/api"
+						pathType: "Prefix"
+						backend:
+							service:
+								name: "web"
+								port:
+									name: "api"
+			-	host: "admin"
+				http:
+					paths:
+					-	path: "/"
+						pathType: "Prefix"
+						backend:
+							service:
+								name: "web"
+								port:
+									name: "admin"
+	`, actual)
+
+	ingress, err = NewIngress(manifest.LookupRole("mixed"), ExportSettings{KubeVersion: &ClusterVersion{1, 14}})
+	require.NoError(t, err)
+	require.NotNil(t, ingress)
+	actual, err = RoundtripKube(ingress)
+	require.NoError(t, err)
+	testhelpers.IsYAMLEqualString(assert, `---
+		apiVersion: "extensions/v1beta1"
+		kind: "Ingress"
+		metadata:
+			name: "mixed"
+			labels:
+				skiff-role-name: "mixed"
+		spec:
+			rules:
+			-	http:
+					paths:
+					-	path: "/"
+						backend:
+							serviceName: "mixed"
+							servicePort: "http"
+	`, actual)
+}
+
+func TestNewIngressHelm(t *testing.T) {
+	t.Parallel()
+	manifest := testLoadManifest(t, "ingress.yml")
+
+	ingress, err := NewIngress(manifest.LookupRole("web"), ExportSettings{CreateHelmChart: true})
+	require.NoError(t, err)
+	require.NotNil(t, ingress)
+
+	t.Run("Disabled", func(t *testing.T) {
+		t.Parallel()
+		actual, err := RenderNode(ingress, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "---\n", string(actual), "The ingress should not be rendered")
+	})
+
+	t.Run("Enabled", func(t *testing.T) {
+		t.Parallel()
+		actual, err := RoundtripNode(ingress, map[string]interface{}{
+			"Values.services.ingress.enabled":    true,
+			"Values.services.ingress.domain":     "example.com",
+			"Values.services.ingress.class":      "nginx",
+			"Values.services.ingress.tls_secret": "wildcard-tls",
+		})
+		require.NoError(t, err)
+		testhelpers.IsYAMLSubsetString(assert.New(t), `---
+			apiVersion: "extensions/v1beta1"
+			metadata:
+				annotations:
+					kubernetes.io/ingress.class: "nginx"
+					nginx.ingress.kubernetes.io/proxy-body-size: "10m"
+			spec:
+				tls:
+				-	hosts: ["www.example.com", "admin.example.com"]
+					secretName: "wildcard-tls"
+				rules:
+				-	host: "www.example.com"
+					http:
+						paths:
+						-	path: "/"
+							backend:
+								serviceName: "web"
+								servicePort: "http"
+						-	path: "/api"
+							backend:
+								serviceName: "web"
+								servicePort: "api"
+				-	host: "admin.example.com"
+					http:
+						paths:
+						-	path: "/"
+							backend:
+								serviceName: "web"
+								servicePort: "admin"
+		`, actual)
+	})
+
+	t.Run("Defaults", func(t *testing.T) {
+		t.Parallel()
+		actual, err := RoundtripNode(ingress, map[string]interface{}{
+			"Values.services.ingress.enabled": true,
+		})
+		require.NoError(t, err)
+		testhelpers.IsYAMLSubsetString(assert.New(t), `---
+			spec:
+				tls:
+				-	hosts: ["www", "admin"]
+					secretName: "web-ingress-tls"
+		`, actual)
+		assert.Nil(t, actual.(map[interface{}]interface{})["metadata"].(map[interface{}]interface{})["annotations"].(map[interface{}]interface{})["kubernetes.io/ingress.class"])
+	})
+}
+
+func TestPublicServiceIngress(t *testing.T) {
+	t.Parallel()
+	manifest := testLoadManifest(t, "ingress.yml")
+	settings := ExportSettings{CreateHelmChart: true}
+
+	service, err := newService(manifest.LookupRole("web"), newServiceTypePublic, settings)
+	require.NoError(t, err)
+	require.NotNil(t, service)
+
+	actual, err := RenderNode(service, map[string]interface{}{
+		"Values.services.ingress.enabled": true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "---\n", string(actual), "The ingress should replace the public service")
+
+	actual, err = RenderNode(service, map[string]interface{}{
+		"Values.services.ingress.enabled":              true,
+		"Values.services.ingress.keep_public_services": true,
+	})
+	require.NoError(t, err)
+	assert.Contains(t, string(actual), "web-public", "The public service should be kept")
+
+	service, err = newService(manifest.LookupRole("mixed"), newServiceTypePublic, settings)
+	require.NoError(t, err)
+	require.NotNil(t, service)
+	rendered, err := RoundtripNode(service, map[string]interface{}{
+		"Values.services.ingress.enabled": true,
+	})
+	require.NoError(t, err)
+	testhelpers.IsYAMLEqualString(assert.New(t), `---
+		-	name: "ssh"
+			port: 2222
+			protocol: "TCP"
+			targetPort: "ssh"
+	`, rendered.(map[interface{}]interface{})["spec"].(map[interface{}]interface{})["ports"])
+}
@@ -36,6 +36,7 @@ func NewJob(role *model.Role, settings ExportSettings, grapher util.ModelGrapher
 	if role.Run.ObjectAnnotations != nil {
 		metadata.Add("annotations", *role.Run.ObjectAnnotations)
 	}
+	addChartLabels(metadata, settings)
 	metadata.Sort()
 
 	job := newTypeMeta(apiVersion, "Job", helm.Comment(role.GetLongDescription()))
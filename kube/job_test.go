@@ -194,6 +194,11 @@ func TestJobHelm(t *testing.T) {
 		apiVersion: batch/v1
 		kind: "Job"
 		metadata:
+			labels:
+				app.kubernetes.io/instance: "fissile"
+				app.kubernetes.io/managed-by: "Tiller"
+				app.kubernetes.io/name: "fissile.name"
+				helm.sh/chart: "fissile.chart"
 			name: "pre-role-42"
 		spec:
 			template:
@@ -231,6 +236,7 @@ func TestJobHelm(t *testing.T) {
 					imagePullSecrets:
 					-	name: "registry-credentials"
 					restartPolicy: "OnFailure"
+					securityContext: ~
 					terminationGracePeriodSeconds: 600
 					volumes: ~
 	`, actual)
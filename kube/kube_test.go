@@ -4,16 +4,42 @@ import (
 	"bytes"
 	"encoding/base64"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
+	"testing"
 	"text/template"
 
 	"github.com/SUSE/fissile/helm"
+	"github.com/SUSE/fissile/model"
 
 	"github.com/Masterminds/sprig"
+	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v2"
 )
 
+// testLoadManifest loads the named role manifest from the kube test assets,
+// together with the ntp and tor releases it may refer to.
+func testLoadManifest(t *testing.T, manifestName string) *model.RoleManifest {
+	workDir, err := os.Getwd()
+	require.NoError(t, err)
+
+	manifestPath := filepath.Join(workDir, "../test-assets/role-manifests/kube", manifestName)
+
+	var releases []*model.Release
+	for _, dirName := range []string{"ntp-release", "tor-boshrelease"} {
+		releasePath := filepath.Join(workDir, "../test-assets", dirName)
+		releasePathBoshCache := filepath.Join(releasePath, "bosh-cache")
+		release, err := model.NewDevRelease(releasePath, "", "", releasePathBoshCache)
+		require.NoError(t, err)
+		releases = append(releases, release)
+	}
+
+	manifest, err := model.LoadRoleManifest(manifestPath, releases, nil)
+	require.NoError(t, err)
+	return manifest
+}
+
 // RenderNode renders a helm node given the configuration.
 // The configuration may be nil, or map[string]interface{}
 // If it is nil, default values are used.
@@ -38,6 +64,11 @@ func RenderNode(node helm.Node, config interface{}) ([]byte, error) {
 		"Template": map[string]interface{}{
 			"BasePath": "",
 		},
+		"Release": map[string]interface{}{
+			"Name":     "fissile",
+			"Service":  "Tiller",
+			"Revision": 1,
+		},
 	}
 	if overrides, ok := config.(map[string]interface{}); ok {
 		for k, v := range overrides {
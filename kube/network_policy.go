@@ -0,0 +1,106 @@
+package kube
+
+import (
+	"fmt"
+
+	"github.com/SUSE/fissile/helm"
+	"github.com/SUSE/fissile/model"
+)
+
+// NewNetworkPolicy creates a NetworkPolicy for the pods of a role, allowing
+// ingress only on its exposed ports: from the roles consuming its BOSH links,
+// and from anywhere for public ports.  All other traffic to the pods is
+// denied.
+func NewNetworkPolicy(role *model.Role, settings ExportSettings) (helm.Node, error) {
+	if role.Run == nil {
+		return nil, fmt.Errorf("Role %s has no run information", role.Name)
+	}
+
+	// The pods of the role also run its colocated containers
+	podRoles := append(model.Roles{role}, role.GetColocatedRoles()...)
+
+	var sources []helm.Node
+	seen := make(map[string]bool)
+	for _, podRole := range podRoles {
+		for _, consumer := range settings.RoleManifest.LinkConsumers(podRole) {
+			for _, name := range podRoleNames(settings.RoleManifest, consumer) {
+				if !seen[name] {
+					seen[name] = true
+					sources = append(sources, helm.NewMapping("podSelector", newSelector(name)))
+				}
+			}
+		}
+	}
+
+	var ports, publicPorts []helm.Node
+	for _, podRole := range podRoles {
+		if podRole.Run == nil {
+			continue
+		}
+		for _, port := range podRole.Run.ExposedPorts {
+			ports = append(ports, newNetworkPolicyPorts(podRole, port, settings)...)
+			if port.Public {
+				publicPorts = append(publicPorts, newNetworkPolicyPorts(podRole, port, settings)...)
+			}
+		}
+	}
+
+	// Without any ingress rules, all incoming traffic is denied
+	var ingress []helm.Node
+	if len(sources) > 0 && len(ports) > 0 {
+		ingress = append(ingress, helm.NewMapping("from", helm.NewNode(sources), "ports", helm.NewNode(ports)))
+	}
+	if len(publicPorts) > 0 {
+		ingress = append(ingress, helm.NewMapping("ports", helm.NewNode(publicPorts)))
+	}
+
+	spec := helm.NewMapping()
+	spec.Add("podSelector", newSelector(role.Name))
+	spec.Add("policyTypes", helm.NewList("Ingress"))
+	if len(ingress) > 0 {
+		spec.Add("ingress", helm.NewNode(ingress))
+	}
+
+	policy := newKubeConfig(settings, "networking.k8s.io/v1", "NetworkPolicy", role.Name)
+	policy.Add("spec", spec)
+
+	return policy, nil
+}
+
+// newNetworkPolicyPorts returns the container ports of an exposed port, in
+// the form of network policy ports
+func newNetworkPolicyPorts(role *model.Role, port *model.RoleRunExposedPort, settings ExportSettings) []helm.Node {
+	if settings.CreateHelmChart && port.CountIsConfigurable {
+		sizing := fmt.Sprintf(".Values.sizing.%s.ports.%s", makeVarName(role.Name), makeVarName(port.Name))
+		newPort := helm.NewMapping(
+			"port", fmt.Sprintf("{{ add %d $port }}", port.InternalPort),
+			"protocol", port.Protocol,
+		)
+		newPort.Set(helm.Block(fmt.Sprintf("range $port := until (int %s.count)", sizing)))
+		return []helm.Node{newPort}
+	}
+
+	var ports []helm.Node
+	for portNumber := port.InternalPort; portNumber < port.InternalPort+port.Count; portNumber++ {
+		ports = append(ports, helm.NewMapping("port", portNumber, "protocol", port.Protocol))
+	}
+	return ports
+}
+
+// podRoleNames returns the names of the roles whose pods run the given role;
+// colocated containers run in the pods of the roles they are colocated with
+func podRoleNames(manifest *model.RoleManifest, role *model.Role) []string {
+	if !role.IsColocatedContainerRole() {
+		return []string{role.Name}
+	}
+
+	var names []string
+	for _, podRole := range manifest.Roles {
+		for _, name := range podRole.ColocatedContainers {
+			if name == role.Name {
+				names = append(names, podRole.Name)
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,96 @@
+package kube
+
+import (
+	"testing"
+
+	"github.com/SUSE/fissile/testhelpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewNetworkPolicyKube(t *testing.T) {
+	t.Parallel()
+	manifest := testLoadManifest(t, "network-policies.yml")
+	settings := ExportSettings{RoleManifest: manifest}
+
+	t.Run("Link consumers", func(t *testing.T) {
+		t.Parallel()
+		policy, err := NewNetworkPolicy(manifest.LookupRole("ntp"), settings)
+		require.NoError(t, err)
+		actual, err := RoundtripKube(policy)
+		require.NoError(t, err)
+		// The ntpd job consumes its own ntp-server link
+		testhelpers.IsYAMLEqualString(assert.New(t), `---
+			apiVersion: "networking.k8s.io/v1"
+			kind: "NetworkPolicy"
+			metadata:
+				name: "ntp"
+				labels:
+					skiff-role-name: "ntp"
+			spec:
+				podSelector:
+					matchLabels:
+						skiff-role-name: "ntp"
+				policyTypes: ["Ingress"]
+				ingress:
+				-	from:
+					-	podSelector:
+							matchLabels:
+								skiff-role-name: "ntp"
+					-	podSelector:
+							matchLabels:
+								skiff-role-name: "client"
+					ports:
+					-	port: 123
+						protocol: "UDP"
+		`, actual)
+	})
+
+	t.Run("Public ports", func(t *testing.T) {
+		t.Parallel()
+		policy, err := NewNetworkPolicy(manifest.LookupRole("client"), settings)
+		require.NoError(t, err)
+		actual, err := RoundtripKube(policy)
+		require.NoError(t, err)
+		testhelpers.IsYAMLSubsetString(assert.New(t), `---
+			spec:
+				ingress:
+				-	ports:
+					-	port: 8080
+						protocol: "TCP"
+		`, actual)
+		assert.Len(t, policy.Get("spec", "ingress").Values(), 1, "Nothing consumes the links of the client")
+	})
+
+	t.Run("Deny all", func(t *testing.T) {
+		t.Parallel()
+		policy, err := NewNetworkPolicy(manifest.LookupRole("isolated"), settings)
+		require.NoError(t, err)
+		assert.Nil(t, policy.Get("spec", "ingress"), "Roles without ports should not allow any traffic")
+	})
+}
+
+func TestNewNetworkPolicyHelm(t *testing.T) {
+	t.Parallel()
+	manifest := testLoadManifest(t, "network-policies.yml")
+	settings := ExportSettings{RoleManifest: manifest, CreateHelmChart: true}
+
+	policy, err := NewNetworkPolicy(manifest.LookupRole("routes"), settings)
+	require.NoError(t, err)
+	actual, err := RoundtripNode(policy, map[string]interface{}{
+		"Values.sizing.routes.ports.route.count": "3",
+	})
+	require.NoError(t, err)
+	testhelpers.IsYAMLSubsetString(assert.New(t), `---
+		spec:
+			ingress:
+			-	ports:
+				-	port: 20000
+					protocol: "TCP"
+				-	port: 20001
+					protocol: "TCP"
+				-	port: 20002
+					protocol: "TCP"
+		`, actual)
+}
@@ -26,24 +26,38 @@ func NewPodTemplate(role *model.Role, settings ExportSettings, grapher util.Mode
 		return nil, fmt.Errorf("Role %s has no run information", role.Name)
 	}
 
+	// Init containers run to completion one after the other, in the order
+	// of the colocated containers, before the main role and its sidecars start
+	podRoles := append([]*model.Role{role}, role.GetColocatedRoles()...)
 	containers := helm.NewList()
-	for _, candidate := range append([]*model.Role{role}, role.GetColocatedRoles()...) {
+	initContainers := helm.NewList()
+	for _, candidate := range podRoles {
 		containerMapping, err := getContainerMapping(candidate, settings, grapher)
 		if err != nil {
 			return nil, err
 		}
 
-		containers.Add(containerMapping)
+		if candidate.IsInitContainerRole() {
+			initContainers.Add(containerMapping)
+		} else {
+			containers.Add(containerMapping)
+		}
 	}
 
 	imagePullSecrets := helm.NewMapping("name", "registry-credentials")
 
 	spec := helm.NewMapping()
 	spec.Add("containers", containers)
+	if len(initContainers.Values()) > 0 {
+		spec.Add("initContainers", initContainers)
+	}
 	spec.Add("imagePullSecrets", helm.NewList(imagePullSecrets))
 	spec.Add("dnsPolicy", "ClusterFirst")
 	spec.Add("volumes", getNonClaimVolumes(role, settings.CreateHelmChart))
 	spec.Add("restartPolicy", "Always")
+	if securityContext := getPodSecurityContext(role, settings.CreateHelmChart); securityContext != nil {
+		spec.Add("securityContext", securityContext)
+	}
 	if role.Run.ServiceAccount != "" {
 		// This role requires a custom service account
 		block := helm.Block("")
@@ -55,12 +69,18 @@ func NewPodTemplate(role *model.Role, settings ExportSettings, grapher util.Mode
 	// BOSH can potentially have an infinite termination grace period; we don't
 	// really trust that, so we'll just go with ten minutes and hope it's enough
 	spec.Add("terminationGracePeriodSeconds", 600)
+	addSchedulingRules(role, spec, settings.CreateHelmChart)
 	spec.Sort()
 
 	podTemplate := helm.NewMapping()
 	meta := newObjectMeta(role.Name)
+	annotations := helm.NewMapping()
 	if settings.CreateHelmChart {
-		meta.Add("annotations", helm.NewMapping("checksum/config", `{{ include (print $.Template.BasePath "/secrets.yaml") . | sha256sum }}`))
+		annotations.Add("checksum/config", `{{ include (print $.Template.BasePath "/secrets.yaml") . | sha256sum }}`)
+	}
+	addAppArmorAnnotations(annotations, podRoles, settings.CreateHelmChart)
+	if len(annotations.Names()) > 0 {
+		meta.Add("annotations", annotations)
 	}
 	podTemplate.Add("metadata", meta)
 	podTemplate.Add("spec", spec)
@@ -85,7 +105,7 @@ func NewPod(role *model.Role, settings ExportSettings, grapher util.ModelGrapher
 		return nil, fmt.Errorf("Role %s has unexpected flight stage %s", role.Name, role.Run.FlightStage)
 	}
 
-	pod := newKubeConfig("v1", "Pod", role.Name, helm.Comment(role.GetLongDescription()))
+	pod := newKubeConfig(settings, "v1", "Pod", role.Name, helm.Comment(role.GetLongDescription()))
 	pod.Add("spec", podTemplate.Get("spec"))
 
 	return pod.Sort(), nil
@@ -159,14 +179,6 @@ func getContainerMapping(role *model.Role, settings ExportSettings, grapher util
 	if err != nil {
 		return nil, err
 	}
-	livenessProbe, err := getContainerLivenessProbe(role)
-	if err != nil {
-		return nil, err
-	}
-	readinessProbe, err := getContainerReadinessProbe(role)
-	if err != nil {
-		return nil, err
-	}
 
 	container := helm.NewMapping()
 	container.Add("name", role.Name)
@@ -176,13 +188,24 @@ func getContainerMapping(role *model.Role, settings ExportSettings, grapher util
 	container.Add("env", vars)
 	container.Add("resources", resources)
 	container.Add("securityContext", securityContext)
-	container.Add("livenessProbe", livenessProbe)
-	container.Add("readinessProbe", readinessProbe)
-	container.Add("lifecycle",
-		helm.NewMapping("preStop",
-			helm.NewMapping("exec",
-				helm.NewMapping("command",
-					[]string{"/opt/fissile/pre-stop.sh"}))))
+	// Init containers have exited by the time the pod is ready or stopping
+	if !role.IsInitContainerRole() {
+		livenessProbe, err := getContainerLivenessProbe(role)
+		if err != nil {
+			return nil, err
+		}
+		readinessProbe, err := getContainerReadinessProbe(role)
+		if err != nil {
+			return nil, err
+		}
+		container.Add("livenessProbe", livenessProbe)
+		container.Add("readinessProbe", readinessProbe)
+		container.Add("lifecycle",
+			helm.NewMapping("preStop",
+				helm.NewMapping("exec",
+					helm.NewMapping("command",
+						[]string{"/opt/fissile/pre-stop.sh"}))))
+	}
 	container.Sort()
 
 	return container, nil
@@ -490,6 +513,17 @@ func getEnvVarsFromConfigs(configs model.ConfigurationVariableSlice, settings Ex
 }
 
 func getSecurityContext(role *model.Role, createHelmChart bool) helm.Node {
+	sc := getPrivilegesSecurityContext(role, createHelmChart)
+	addSecurityContextSettings(sc, role, createHelmChart)
+	if len(sc.Names()) == 0 {
+		return nil
+	}
+	return sc
+}
+
+// getPrivilegesSecurityContext returns the security context granting the
+// capabilities of a role, or full privileges
+func getPrivilegesSecurityContext(role *model.Role, createHelmChart bool) *helm.Mapping {
 	var hasAll string
 	var notAll string
 	var config string
@@ -527,7 +561,7 @@ func getSecurityContext(role *model.Role, createHelmChart bool) helm.Node {
 		return sc
 	}
 	if len(capabilities) == 0 {
-		return nil
+		return helm.NewMapping()
 	}
 	return helm.NewMapping("capabilities", helm.NewMapping("add", helm.NewNode(capabilities)))
 }
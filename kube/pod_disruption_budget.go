@@ -0,0 +1,73 @@
+package kube
+
+import (
+	"fmt"
+
+	"github.com/SUSE/fissile/helm"
+	"github.com/SUSE/fissile/model"
+)
+
+// NewPodDisruptionBudget creates a PodDisruptionBudget for a role that can
+// have more than one instance, so that voluntary disruptions (such as
+// draining a node) leave enough instances running.  Roles keeping a quorum
+// (must_be_odd) keep a majority of their instances, all other roles may lose
+// one instance at a time.  It returns nil for roles without a budget.
+func NewPodDisruptionBudget(role *model.Role, settings ExportSettings) (helm.Node, error) {
+	if role.Run == nil || role.Run.Scaling == nil {
+		return nil, fmt.Errorf("Role %s has no scaling information", role.Name)
+	}
+	scaling := role.Run.Scaling
+	if scaling.Max < 2 {
+		return nil, nil
+	}
+
+	spec := helm.NewMapping()
+	spec.Add("selector", newSelector(role.Name))
+
+	if !settings.CreateHelmChart {
+		// Plain kube configurations run the minimum number of instances
+		if scaling.Min < 2 {
+			return nil, nil
+		}
+		spec.Add("minAvailable", minAvailable(scaling, scaling.Min))
+	} else {
+		roleName := makeVarName(role.Name)
+		budget := fmt.Sprintf(".Values.sizing.%s.disruption_budget", roleName)
+		count := fmt.Sprintf("(int .Values.sizing.%s.count)", roleName)
+
+		derived := fmt.Sprintf("{{ sub %s 1 }}", count)
+		if scaling.MustBeOdd {
+			derived = fmt.Sprintf("{{ add (div %s 2) 1 }}", count)
+		}
+		if scaling.HA != scaling.Min {
+			// Same condition as for the replica count under HA
+			derived = fmt.Sprintf("{{ if and .Values.config.HA (eq %s %d) -}} %d {{- else -}} %s {{- end }}",
+				count, scaling.Min, minAvailable(scaling, scaling.HA), derived)
+		}
+		spec.Add("minAvailable", fmt.Sprintf("{{ if %s.min_available -}} {{ %s.min_available }} {{- else -}} %s {{- end }}",
+			budget, budget, derived))
+	}
+
+	pdb := newKubeConfig(settings, settings.apiVersion("PodDisruptionBudget"), "PodDisruptionBudget", role.Name)
+	pdb.Add("spec", spec.Sort())
+
+	if settings.CreateHelmChart {
+		condition := fmt.Sprintf(".Values.sizing.%s.disruption_budget.enabled", makeVarName(role.Name))
+		if scaling.Min == 0 {
+			// Matches the guard of the controller; no instances, no budget
+			condition = fmt.Sprintf("and %s (gt (int .Values.sizing.%s.count) 0)", condition, makeVarName(role.Name))
+		}
+		pdb.Set(helm.Block("if " + condition))
+	}
+
+	return pdb, nil
+}
+
+// minAvailable returns the number of instances out of count that must stay
+// available
+func minAvailable(scaling *model.RoleRunScaling, count int) int {
+	if scaling.MustBeOdd {
+		return count/2 + 1
+	}
+	return count - 1
+}
@@ -0,0 +1,124 @@
+package kube
+
+import (
+	"testing"
+
+	"github.com/SUSE/fissile/testhelpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPodDisruptionBudgetKube(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	manifest := testLoadManifest(t, "pod-disruption-budgets.yml")
+
+	for _, roleName := range []string{"single", "optional"} {
+		pdb, err := NewPodDisruptionBudget(manifest.LookupRole(roleName), ExportSettings{})
+		assert.NoError(err)
+		assert.Nil(pdb, "Role %s should have no disruption budget", roleName)
+	}
+
+	pdb, err := NewPodDisruptionBudget(manifest.LookupRole("scalable"), ExportSettings{})
+	require.NoError(t, err)
+	require.NotNil(t, pdb)
+	actual, err := RoundtripKube(pdb)
+	require.NoError(t, err)
+	testhelpers.IsYAMLEqualString(assert, `---
+		apiVersion: "policy/v1"
+		kind: "PodDisruptionBudget"
+		metadata:
+			name: "scalable"
+			labels:
+				skiff-role-name: "scalable"
+		spec:
+			minAvailable: 1
+			selector:
+				matchLabels:
+					skiff-role-name: "scalable"
+	`, actual)
+
+	pdb, err = NewPodDisruptionBudget(manifest.LookupRole("quorum"), ExportSettings{KubeVersion: &ClusterVersion{1, 10}})
+	require.NoError(t, err)
+	require.NotNil(t, pdb)
+	actual, err = RoundtripKube(pdb)
+	require.NoError(t, err)
+	testhelpers.IsYAMLSubsetString(assert, `---
+		apiVersion: "policy/v1beta1"
+		spec:
+			minAvailable: 2
+	`, actual)
+}
+
+func TestNewPodDisruptionBudgetHelm(t *testing.T) {
+	t.Parallel()
+	manifest := testLoadManifest(t, "pod-disruption-budgets.yml")
+	settings := ExportSettings{CreateHelmChart: true}
+
+	pdb, err := NewPodDisruptionBudget(manifest.LookupRole("single"), settings)
+	assert.NoError(t, err)
+	assert.Nil(t, pdb, "Roles that cannot scale should have no disruption budget")
+
+	quorum, err := NewPodDisruptionBudget(manifest.LookupRole("quorum"), settings)
+	require.NoError(t, err)
+	require.NotNil(t, quorum)
+
+	for _, testcase := range []struct {
+		name     string
+		config   map[string]interface{}
+		expected string
+	}{
+		{"majority of count", map[string]interface{}{
+			"Values.sizing.quorum.count":                     "7",
+			"Values.sizing.quorum.disruption_budget.enabled": true,
+		}, "minAvailable: 4"},
+		{"majority of HA count", map[string]interface{}{
+			"Values.config.HA":                               true,
+			"Values.sizing.quorum.count":                     "3",
+			"Values.sizing.quorum.disruption_budget.enabled": true,
+		}, "minAvailable: 3"},
+		{"overridden", map[string]interface{}{
+			"Values.sizing.quorum.count":                           "3",
+			"Values.sizing.quorum.disruption_budget.enabled":       true,
+			"Values.sizing.quorum.disruption_budget.min_available": "50%",
+		}, "minAvailable: 50%"},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			actual, err := RoundtripNode(quorum, testcase.config)
+			require.NoError(t, err)
+			testhelpers.IsYAMLSubsetString(assert.New(t), "spec:\n\t"+testcase.expected, actual)
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		actual, err := RenderNode(quorum, map[string]interface{}{
+			"Values.sizing.quorum.count":                     "3",
+			"Values.sizing.quorum.disruption_budget.enabled": false,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "---\n", string(actual), "The budget should not be rendered")
+	})
+
+	optional, err := NewPodDisruptionBudget(manifest.LookupRole("optional"), settings)
+	require.NoError(t, err)
+	require.NotNil(t, optional)
+
+	t.Run("no instances", func(t *testing.T) {
+		actual, err := RenderNode(optional, map[string]interface{}{
+			"Values.sizing.optional.count":                     "0",
+			"Values.sizing.optional.disruption_budget.enabled": true,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "---\n", string(actual), "The budget should not be rendered")
+	})
+
+	t.Run("all instances but one", func(t *testing.T) {
+		actual, err := RoundtripNode(optional, map[string]interface{}{
+			"Values.sizing.optional.count":                     "3",
+			"Values.sizing.optional.disruption_budget.enabled": true,
+		})
+		require.NoError(t, err)
+		testhelpers.IsYAMLSubsetString(assert.New(t), "spec:\n\tminAvailable: 2", actual)
+	})
+}
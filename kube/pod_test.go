@@ -1785,6 +1785,10 @@ func TestPodPreFlightHelm(t *testing.T) {
 		metadata:
 			name: "pre-role"
 			labels:
+				app.kubernetes.io/instance: "fissile"
+				app.kubernetes.io/managed-by: "Tiller"
+				app.kubernetes.io/name: "fissile.name"
+				helm.sh/chart: "fissile.chart"
 				skiff-role-name: "pre-role"
 		spec:
 			containers:
@@ -1814,6 +1818,7 @@ func TestPodPreFlightHelm(t *testing.T) {
 			imagePullSecrets:
 			-	name: "registry-credentials"
 			restartPolicy: "OnFailure"
+			securityContext: ~
 			terminationGracePeriodSeconds: 600
 			volumes: ~
 	`, actual)
@@ -1887,6 +1892,10 @@ func TestPodPostFlightHelm(t *testing.T) {
 		metadata:
 			name: "post-role"
 			labels:
+				app.kubernetes.io/instance: "fissile"
+				app.kubernetes.io/managed-by: "Tiller"
+				app.kubernetes.io/name: "fissile.name"
+				helm.sh/chart: "fissile.chart"
 				skiff-role-name: "post-role"
 		spec:
 			containers:
@@ -1916,6 +1925,7 @@ func TestPodPostFlightHelm(t *testing.T) {
 			imagePullSecrets:
 			-	name: "registry-credentials"
 			restartPolicy: "OnFailure"
+			securityContext: ~
 			terminationGracePeriodSeconds: 600
 			volumes: ~
 	`, actual)
@@ -1999,6 +2009,10 @@ func TestPodMemoryHelmDisabled(t *testing.T) {
 		metadata:
 			name: "pre-role"
 			labels:
+				app.kubernetes.io/instance: "fissile"
+				app.kubernetes.io/managed-by: "Tiller"
+				app.kubernetes.io/name: "fissile.name"
+				helm.sh/chart: "fissile.chart"
 				skiff-role-name: "pre-role"
 		spec:
 			containers:
@@ -2030,6 +2044,7 @@ func TestPodMemoryHelmDisabled(t *testing.T) {
 			imagePullSecrets:
 			-	name: "registry-credentials"
 			restartPolicy: "OnFailure"
+			securityContext: ~
 			terminationGracePeriodSeconds: 600
 			volumes: ~
 	`, actual)
@@ -2074,6 +2089,10 @@ func TestPodMemoryHelmActive(t *testing.T) {
 		metadata:
 			name: "pre-role"
 			labels:
+				app.kubernetes.io/instance: "fissile"
+				app.kubernetes.io/managed-by: "Tiller"
+				app.kubernetes.io/name: "fissile.name"
+				helm.sh/chart: "fissile.chart"
 				skiff-role-name: "pre-role"
 		spec:
 			containers:
@@ -2107,6 +2126,7 @@ func TestPodMemoryHelmActive(t *testing.T) {
 			imagePullSecrets:
 			-	name: "registry-credentials"
 			restartPolicy: "OnFailure"
+			securityContext: ~
 			terminationGracePeriodSeconds: 600
 			volumes: ~
 	`, actual)
@@ -2188,6 +2208,10 @@ func TestPodCPUHelmDisabled(t *testing.T) {
 		metadata:
 			name: "pre-role"
 			labels:
+				app.kubernetes.io/instance: "fissile"
+				app.kubernetes.io/managed-by: "Tiller"
+				app.kubernetes.io/name: "fissile.name"
+				helm.sh/chart: "fissile.chart"
 				skiff-role-name: "pre-role"
 		spec:
 			containers:
@@ -2219,6 +2243,7 @@ func TestPodCPUHelmDisabled(t *testing.T) {
 			imagePullSecrets:
 			-	name: "registry-credentials"
 			restartPolicy: "OnFailure"
+			securityContext: ~
 			terminationGracePeriodSeconds: 600
 			volumes: ~
 	`, actual)
@@ -2263,6 +2288,10 @@ func TestPodCPUHelmActive(t *testing.T) {
 		metadata:
 			name: "pre-role"
 			labels:
+				app.kubernetes.io/instance: "fissile"
+				app.kubernetes.io/managed-by: "Tiller"
+				app.kubernetes.io/name: "fissile.name"
+				helm.sh/chart: "fissile.chart"
 				skiff-role-name: "pre-role"
 		spec:
 			containers:
@@ -2296,6 +2325,7 @@ func TestPodCPUHelmActive(t *testing.T) {
 			imagePullSecrets:
 			-	name: "registry-credentials"
 			restartPolicy: "OnFailure"
+			securityContext: ~
 			terminationGracePeriodSeconds: 600
 			volumes: ~
 	`, actual)
@@ -2496,7 +2526,10 @@ func TestGetSecurityContextPrivileged(t *testing.T) {
 			return
 		}
 
-		actual, err := RoundtripKube(sc)
+		config := map[string]interface{}{
+			"Values.sizing.myrole.capabilities": []interface{}{},
+		}
+		actual, err := RoundtripNode(sc, config)
 		if !assert.NoError(err) {
 			return
 		}
@@ -2760,3 +2793,53 @@ func TestPodVolumeTypeEmptyDir(t *testing.T) {
 `, actual)
 	}
 }
+
+func TestPodColocatedInitContainers(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	require.NoError(t, err)
+
+	var releases []*model.Release
+	for _, dirName := range []string{"ntp-release", "tor-boshrelease"} {
+		releasePath := filepath.Join(workDir, "../test-assets", dirName)
+		release, err := model.NewDevRelease(releasePath, "", "", filepath.Join(releasePath, "bosh-cache"))
+		require.NoError(t, err)
+		releases = append(releases, release)
+	}
+
+	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/kube/colocated-init-containers.yml")
+	roleManifest, err := model.LoadRoleManifest(roleManifestPath, releases, nil)
+	require.NoError(t, err)
+
+	assert.True(roleManifest.LookupRole("migrate").RunsToCompletion())
+	assert.False(roleManifest.LookupRole("to-be-colocated").RunsToCompletion())
+
+	for _, createHelmChart := range []bool{false, true} {
+		podTemplate, err := NewPodTemplate(roleManifest.LookupRole("main-role"), ExportSettings{
+			CreateHelmChart: createHelmChart,
+			Opinions:        model.NewEmptyOpinions(),
+		}, nil)
+		require.NoError(t, err)
+
+		containerNames := func(key string) []string {
+			var names []string
+			for _, container := range podTemplate.Get("spec", key).(*helm.List).Values() {
+				names = append(names, container.Get("name").String())
+			}
+			return names
+		}
+		assert.Equal([]string{"main-role", "to-be-colocated"}, containerNames("containers"))
+		assert.Equal([]string{"migrate"}, containerNames("initContainers"))
+
+		initContainer := podTemplate.Get("spec", "initContainers").(*helm.List).Values()[0]
+		for _, key := range []string{"livenessProbe", "readinessProbe", "lifecycle"} {
+			assert.Nil(initContainer.Get(key), "Init containers have no %s", key)
+		}
+		assert.NotNil(initContainer.Get("volumeMounts"))
+
+		sidecar := podTemplate.Get("spec", "containers").(*helm.List).Values()[1]
+		assert.NotNil(sidecar.Get("lifecycle"))
+	}
+}
@@ -23,13 +23,17 @@ func NewRBACAccount(name string, account model.AuthAccount, settings ExportSetti
 	// first -- it already exists
 	if name != "default" {
 		accountYAML := newTypeMeta("v1", "ServiceAccount", block)
-		accountYAML.Add("metadata", helm.NewMapping("name", name))
+		meta := helm.NewMapping("name", name)
+		addChartLabels(meta, settings)
+		accountYAML.Add("metadata", meta)
 		resources = append(resources, accountYAML)
 	}
 
 	for _, role := range account.Roles {
-		binding := newTypeMeta("rbac.authorization.k8s.io/v1beta1", "RoleBinding", block)
-		binding.Add("metadata", helm.NewMapping("name", fmt.Sprintf("%s-%s-binding", name, role)))
+		binding := newTypeMeta(settings.apiVersion("RoleBinding"), "RoleBinding", block)
+		meta := helm.NewMapping("name", fmt.Sprintf("%s-%s-binding", name, role))
+		addChartLabels(meta, settings)
+		binding.Add("metadata", meta)
 		subjects := helm.NewList(helm.NewMapping(
 			"kind", "ServiceAccount",
 			"name", name))
@@ -67,11 +71,13 @@ func NewRBACRole(name string, role model.AuthRole, settings ExportSettings) (hel
 		rules.Add(rule.Sort())
 	}
 
-	container := newTypeMeta("rbac.authorization.k8s.io/v1beta1", "Role")
+	container := newTypeMeta(settings.apiVersion("Role"), "Role")
 	if settings.CreateHelmChart {
 		container.Set(helm.Block(authModeRBAC))
 	}
-	container.Add("metadata", helm.NewMapping("name", name))
+	meta := helm.NewMapping("name", name)
+	addChartLabels(meta, settings)
+	container.Add("metadata", meta)
 	container.Add("rules", rules)
 
 	return container.Sort(), nil
@@ -43,7 +43,7 @@ func TestNewRBACAccountKube(t *testing.T) {
 		return
 	}
 	testhelpers.IsYAMLEqualString(assert, `---
-		apiVersion: "rbac.authorization.k8s.io/v1beta1"
+		apiVersion: "rbac.authorization.k8s.io/v1"
 		kind: "RoleBinding"
 		metadata:
 			name: "the-name-a-role-binding"
@@ -113,6 +113,11 @@ func TestNewRBACAccountHelmNoAuth(t *testing.T) {
 			apiVersion: "v1"
 			kind: "ServiceAccount"
 			metadata:
+				labels:
+					app.kubernetes.io/instance: "fissile"
+					app.kubernetes.io/managed-by: "Tiller"
+					app.kubernetes.io/name: "fissile.name"
+					helm.sh/chart: "fissile.chart"
 				name: "the-name"
 		`, actualAccount)
 
@@ -122,9 +127,14 @@ func TestNewRBACAccountHelmNoAuth(t *testing.T) {
 		}
 
 		testhelpers.IsYAMLEqualString(assert, `---
-			apiVersion: "rbac.authorization.k8s.io/v1beta1"
+			apiVersion: "rbac.authorization.k8s.io/v1"
 			kind: "RoleBinding"
 			metadata:
+				labels:
+					app.kubernetes.io/instance: "fissile"
+					app.kubernetes.io/managed-by: "Tiller"
+					app.kubernetes.io/name: "fissile.name"
+					helm.sh/chart: "fissile.chart"
 				name: "the-name-a-role-binding"
 			subjects:
 			-	kind: "ServiceAccount"
@@ -160,7 +170,7 @@ func TestNewRBACRoleKube(t *testing.T) {
 		return
 	}
 	testhelpers.IsYAMLEqualString(assert, `---
-		apiVersion: "rbac.authorization.k8s.io/v1beta1"
+		apiVersion: "rbac.authorization.k8s.io/v1"
 		kind: "Role"
 		metadata:
 			name: "the-name"
@@ -221,9 +231,14 @@ func TestNewRBACRoleHelm(t *testing.T) {
 		}
 
 		testhelpers.IsYAMLEqualString(assert, `---
-			apiVersion: "rbac.authorization.k8s.io/v1beta1"
+			apiVersion: "rbac.authorization.k8s.io/v1"
 			kind: "Role"
 			metadata:
+				labels:
+					app.kubernetes.io/instance: "fissile"
+					app.kubernetes.io/managed-by: "Tiller"
+					app.kubernetes.io/name: "fissile.name"
+					helm.sh/chart: "fissile.chart"
 				name: "the-name"
 			rules:
 			-	apiGroups:
@@ -75,6 +75,10 @@ func TestMakeRegistryCredentialsHelm(t *testing.T) {
 		metadata:
 			name: "registry-credentials"
 			labels:
+				app.kubernetes.io/instance: "fissile"
+				app.kubernetes.io/managed-by: "Tiller"
+				app.kubernetes.io/name: "fissile.name"
+				helm.sh/chart: "fissile.chart"
 				skiff-role-name: "registry-credentials"
 		type: "kubernetes.io/dockercfg"
 	`, dcfg), actual)
@@ -0,0 +1,82 @@
+package kube
+
+import (
+	"fmt"
+
+	"github.com/SUSE/fissile/helm"
+	"github.com/SUSE/fissile/model"
+)
+
+// addSchedulingRules adds the tolerations, node selector, priority class and
+// topology spread constraints of a role to its pod spec.  Helm charts take
+// them from the values, which default to the role manifest.
+func addSchedulingRules(role *model.Role, spec *helm.Mapping, createHelmChart bool) {
+	if createHelmChart {
+		for _, setting := range []struct {
+			name string
+			key  string
+		}{
+			{"tolerations", "tolerations"},
+			{"nodeSelector", "node_selector"},
+			{"priorityClassName", "priority_class_name"},
+			{"topologySpreadConstraints", "topology_spread_constraints"},
+		} {
+			value := fmt.Sprintf(".Values.sizing.%s.%s", makeVarName(role.Name), setting.key)
+			spec.Add(setting.name, fmt.Sprintf("{{ toJson %s }}", value), helm.Block("if "+value))
+		}
+		return
+	}
+
+	if len(role.Run.Tolerations) > 0 {
+		spec.Add("tolerations", getTolerations(role))
+	}
+	if len(role.Run.NodeSelector) > 0 {
+		spec.Add("nodeSelector", role.Run.NodeSelector)
+	}
+	if role.Run.PriorityClassName != "" {
+		spec.Add("priorityClassName", role.Run.PriorityClassName)
+	}
+	if len(role.Run.TopologySpread) > 0 {
+		spec.Add("topologySpreadConstraints", getTopologySpreadConstraints(role))
+	}
+}
+
+// getTolerations returns the tolerations of the pods of a role
+func getTolerations(role *model.Role) *helm.List {
+	tolerations := helm.NewList()
+	for _, toleration := range role.Run.Tolerations {
+		entry := helm.NewMapping()
+		for _, field := range []struct {
+			name  string
+			value string
+		}{
+			{"key", toleration.Key},
+			{"operator", toleration.Operator},
+			{"value", toleration.Value},
+			{"effect", toleration.Effect},
+		} {
+			if field.value != "" {
+				entry.Add(field.name, field.value)
+			}
+		}
+		if toleration.TolerationSeconds != nil {
+			entry.Add("tolerationSeconds", int(*toleration.TolerationSeconds))
+		}
+		tolerations.Add(entry)
+	}
+	return tolerations
+}
+
+// getTopologySpreadConstraints returns the constraints spreading the pods of
+// a role across topology domains
+func getTopologySpreadConstraints(role *model.Role) *helm.List {
+	constraints := helm.NewList()
+	for _, constraint := range role.Run.TopologySpread {
+		constraints.Add(helm.NewMapping(
+			"maxSkew", constraint.MaxSkew,
+			"topologyKey", constraint.TopologyKey,
+			"whenUnsatisfiable", constraint.WhenUnsatisfiable,
+			"labelSelector", newSelector(role.Name)))
+	}
+	return constraints
+}
@@ -0,0 +1,103 @@
+package kube
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SUSE/fissile/helm"
+	"github.com/SUSE/fissile/model"
+	"github.com/SUSE/fissile/testhelpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func schedulingTestLoadRole(t *testing.T) *model.Role {
+	workDir, err := os.Getwd()
+	require.NoError(t, err)
+
+	manifestPath := filepath.Join(workDir, "../test-assets/role-manifests/kube/scheduling.yml")
+	releasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	releasePathBoshCache := filepath.Join(releasePath, "bosh-cache")
+
+	release, err := model.NewDevRelease(releasePath, "", "", releasePathBoshCache)
+	require.NoError(t, err)
+
+	manifest, err := model.LoadRoleManifest(manifestPath, []*model.Release{release}, nil)
+	require.NoError(t, err)
+	role := manifest.LookupRole("scheduled")
+	require.NotNil(t, role)
+	return role
+}
+
+func TestSchedulingRulesKube(t *testing.T) {
+	t.Parallel()
+	role := schedulingTestLoadRole(t)
+
+	spec := helm.NewMapping()
+	addSchedulingRules(role, spec, false)
+	actual, err := RoundtripKube(spec)
+	require.NoError(t, err)
+	testhelpers.IsYAMLEqualString(assert.New(t), `---
+		tolerations:
+		-	key: "dedicated"
+			value: "storage"
+			effect: "NoSchedule"
+		-	key: "node.kubernetes.io/unreachable"
+			operator: "Exists"
+			effect: "NoExecute"
+			tolerationSeconds: 30
+		nodeSelector:
+			disktype: "ssd"
+		priorityClassName: "high-priority"
+		topologySpreadConstraints:
+		-	maxSkew: 1
+			topologyKey: "topology.kubernetes.io/zone"
+			whenUnsatisfiable: "DoNotSchedule"
+			labelSelector:
+				matchLabels:
+					skiff-role-name: "scheduled"
+	`, actual)
+}
+
+func TestSchedulingRulesHelm(t *testing.T) {
+	t.Parallel()
+	role := schedulingTestLoadRole(t)
+
+	spec := helm.NewMapping()
+	addSchedulingRules(role, spec, true)
+
+	t.Run("Defaults", func(t *testing.T) {
+		t.Parallel()
+		actual, err := RoundtripNode(spec, map[string]interface{}{
+			"Values.sizing.scheduled.tolerations":                 []interface{}{},
+			"Values.sizing.scheduled.node_selector":               map[string]interface{}{},
+			"Values.sizing.scheduled.priority_class_name":         nil,
+			"Values.sizing.scheduled.topology_spread_constraints": []interface{}{},
+		})
+		require.NoError(t, err)
+		assert.Nil(t, actual, "Empty values should not add any scheduling rules")
+	})
+
+	t.Run("Configured", func(t *testing.T) {
+		t.Parallel()
+		actual, err := RoundtripNode(spec, map[string]interface{}{
+			"Values.sizing.scheduled.tolerations": []interface{}{
+				map[string]interface{}{"operator": "Exists"},
+			},
+			"Values.sizing.scheduled.node_selector": map[string]interface{}{
+				"disktype": "hdd",
+			},
+			"Values.sizing.scheduled.priority_class_name": "low-priority",
+		})
+		require.NoError(t, err)
+		testhelpers.IsYAMLEqualString(assert.New(t), `---
+			tolerations:
+			-	operator: "Exists"
+			nodeSelector:
+				disktype: "hdd"
+			priorityClassName: "low-priority"
+		`, actual)
+	})
+}
@@ -50,7 +50,19 @@ func TestMakeSecretsEmpty(t *testing.T) {
 		if !assert.NoError(err) {
 			return
 		}
-		testhelpers.IsYAMLEqualString(assert, expected, actual)
+		testhelpers.IsYAMLEqualString(assert, `---
+			apiVersion: "v1"
+			data: {}
+			kind: "Secret"
+			metadata:
+				name: "secrets"
+				labels:
+					app.kubernetes.io/instance: "fissile"
+					app.kubernetes.io/managed-by: "Tiller"
+					app.kubernetes.io/name: "fissile.name"
+					helm.sh/chart: "fissile.chart"
+					skiff-role-name: "secrets"
+		`, actual)
 	})
 }
 
@@ -283,6 +295,10 @@ func TestMakeSecretsHelm(t *testing.T) {
 			metadata:
 				name: "secrets"
 				labels:
+					app.kubernetes.io/instance: "fissile"
+					app.kubernetes.io/managed-by: "Tiller"
+					app.kubernetes.io/name: "fissile.name"
+					helm.sh/chart: "fissile.chart"
 					skiff-role-name: "secrets"
 		`, varConstB64, varDescB64, varMinB64, varValuedB64, varStructuredB64, varGenieB64), actual)
 	})
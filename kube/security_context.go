@@ -0,0 +1,172 @@
+package kube
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/SUSE/fissile/helm"
+	"github.com/SUSE/fissile/model"
+)
+
+// securityContextSetting is a plain setting of a security context, with its
+// key in the helm values and its value in the role manifest (nil if unset)
+type securityContextSetting struct {
+	name  string
+	key   string
+	value interface{}
+}
+
+// containerSecurityContextSettings returns the plain settings of the
+// container security context of a role
+func containerSecurityContextSettings(sc *model.RoleRunSecurityContext) []securityContextSetting {
+	return []securityContextSetting{
+		{"runAsUser", "run_as_user", optionalInt64(sc.RunAsUser)},
+		{"runAsGroup", "run_as_group", optionalInt64(sc.RunAsGroup)},
+		{"runAsNonRoot", "run_as_non_root", optionalBool(sc.RunAsNonRoot)},
+		{"readOnlyRootFilesystem", "read_only_root_filesystem", optionalBool(sc.ReadOnlyRootFilesystem)},
+		{"allowPrivilegeEscalation", "allow_privilege_escalation", optionalBool(sc.AllowPrivilegeEscalation)},
+	}
+}
+
+// podSecurityContextSettings returns the plain settings of the pod security
+// context of a role
+func podSecurityContextSettings(sc *model.RoleRunSecurityContext) []securityContextSetting {
+	return []securityContextSetting{
+		{"fsGroup", "fs_group", optionalInt64(sc.FSGroup)},
+	}
+}
+
+// roleSecurityContext returns the security context of a role, or nil if it
+// has none.  In helm charts all roles have one, which operators can set in
+// the values even when the role manifest does not.
+func roleSecurityContext(role *model.Role, createHelmChart bool) *model.RoleRunSecurityContext {
+	if role.Run.SecurityContext == nil && createHelmChart {
+		return &model.RoleRunSecurityContext{}
+	}
+	return role.Run.SecurityContext
+}
+
+// makeSecurityContextValues returns the helm values overriding the security
+// context of a role, defaulting to the role manifest
+func makeSecurityContextValues(role *model.Role) *helm.Mapping {
+	sc := roleSecurityContext(role, true)
+	values := helm.NewMapping()
+	for _, setting := range containerSecurityContextSettings(sc) {
+		values.Add(setting.key, setting.value)
+	}
+	if !role.IsColocatedContainerRole() {
+		for _, setting := range podSecurityContextSettings(sc) {
+			values.Add(setting.key, setting.value)
+		}
+	}
+	values.Add("seccomp_profile", optionalString(sc.SeccompProfile))
+	values.Add("apparmor_profile", optionalString(sc.AppArmorProfile))
+	return values.Sort()
+}
+
+// securityContextValue returns the helm value of a security context setting;
+// values without security contexts, as kept from older charts, leave all
+// settings unset
+func securityContextValue(role *model.Role, key string) string {
+	return fmt.Sprintf("(default (dict) .Values.sizing.%s.security_context).%s", makeVarName(role.Name), key)
+}
+
+// addSecurityContextSetting adds a plain setting to a security context; helm
+// charts take it from the values, where unset settings are null
+func addSecurityContextSetting(sc *helm.Mapping, role *model.Role, setting securityContextSetting, createHelmChart bool) {
+	if createHelmChart {
+		value := securityContextValue(role, setting.key)
+		sc.Add(setting.name, fmt.Sprintf("{{ %s }}", value),
+			helm.Block(fmt.Sprintf(`if not (kindIs "invalid" %s)`, value)))
+	} else if setting.value != nil {
+		sc.Add(setting.name, setting.value)
+	}
+}
+
+// addSecurityContextSettings adds the settings of the security context of a
+// role to the security context of its container
+func addSecurityContextSettings(sc *helm.Mapping, role *model.Role, createHelmChart bool) {
+	context := roleSecurityContext(role, createHelmChart)
+	if context == nil {
+		return
+	}
+
+	for _, setting := range containerSecurityContextSettings(context) {
+		addSecurityContextSetting(sc, role, setting, createHelmChart)
+	}
+
+	if createHelmChart {
+		value := securityContextValue(role, "seccomp_profile")
+		localhost := fmt.Sprintf(`hasPrefix "localhost/" %s`, value)
+		profile := helm.NewMapping()
+		profile.Add("type", fmt.Sprintf("{{ if %s }}Localhost{{ else }}{{ %s }}{{ end }}", localhost, value))
+		profile.Add("localhostProfile", fmt.Sprintf(`{{ trimPrefix "localhost/" %s }}`, value), helm.Block("if "+localhost))
+		sc.Add("seccompProfile", profile, helm.Block("if "+value))
+	} else if context.SeccompProfile != "" {
+		profile := helm.NewMapping("type", context.SeccompProfile)
+		if strings.HasPrefix(context.SeccompProfile, "localhost/") {
+			profile = helm.NewMapping(
+				"type", "Localhost",
+				"localhostProfile", strings.TrimPrefix(context.SeccompProfile, "localhost/"))
+		}
+		sc.Add("seccompProfile", profile)
+	}
+}
+
+// getPodSecurityContext returns the pod security context of a role, or nil
+// if it has none
+func getPodSecurityContext(role *model.Role, createHelmChart bool) helm.Node {
+	context := roleSecurityContext(role, createHelmChart)
+	if context == nil {
+		return nil
+	}
+
+	sc := helm.NewMapping()
+	for _, setting := range podSecurityContextSettings(context) {
+		addSecurityContextSetting(sc, role, setting, createHelmChart)
+	}
+	if len(sc.Names()) == 0 {
+		return nil
+	}
+	return sc
+}
+
+// addAppArmorAnnotations adds the annotations selecting the AppArmor profiles
+// of the containers of a pod
+func addAppArmorAnnotations(annotations *helm.Mapping, roles []*model.Role, createHelmChart bool) {
+	for _, role := range roles {
+		context := roleSecurityContext(role, createHelmChart)
+		if context == nil {
+			continue
+		}
+		name := fmt.Sprintf("container.apparmor.security.beta.kubernetes.io/%s", role.Name)
+		if createHelmChart {
+			value := securityContextValue(role, "apparmor_profile")
+			annotations.Add(name, fmt.Sprintf("{{ %s }}", value), helm.Block("if "+value))
+		} else if context.AppArmorProfile != "" {
+			annotations.Add(name, context.AppArmorProfile)
+		}
+	}
+}
+
+func optionalInt64(value *int64) interface{} {
+	if value == nil {
+		return nil
+	}
+	// Nodes have no int64 values
+	return int(*value)
+}
+
+func optionalBool(value *bool) interface{} {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
+
+func optionalString(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,181 @@
+package kube
+
+import (
+	"testing"
+
+	"github.com/SUSE/fissile/model"
+	"github.com/SUSE/fissile/testhelpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func securityContextTestLoadRole(t *testing.T, roleName string) *model.Role {
+	role := testLoadManifest(t, "security-context.yml").LookupRole(roleName)
+	require.NotNil(t, role)
+	return role
+}
+
+func TestSecurityContextKube(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	role := securityContextTestLoadRole(t, "hardened")
+
+	podTemplate, err := NewPodTemplate(role, ExportSettings{Opinions: model.NewEmptyOpinions()}, nil)
+	require.NoError(t, err)
+	actual, err := RoundtripKube(podTemplate)
+	require.NoError(t, err)
+	testhelpers.IsYAMLSubsetString(assert, `---
+		metadata:
+			annotations:
+				container.apparmor.security.beta.kubernetes.io/hardened: "runtime/default"
+		spec:
+			securityContext:
+				fsGroup: 2000
+			containers:
+			-	name: "hardened"
+				securityContext:
+					runAsUser: 1000
+					runAsGroup: 1000
+					runAsNonRoot: true
+					readOnlyRootFilesystem: true
+					allowPrivilegeEscalation: false
+					seccompProfile:
+						type: "RuntimeDefault"
+			-	name: "sidecar"
+				securityContext:
+					runAsUser: 0
+					seccompProfile:
+						type: "Localhost"
+						localhostProfile: "profiles/ntpd.json"
+	`, actual)
+
+	annotations := podTemplate.Get("metadata", "annotations").(interface {
+		Names() []string
+	})
+	assert.Equal([]string{"container.apparmor.security.beta.kubernetes.io/hardened"}, annotations.Names(),
+		"The sidecar has no AppArmor profile")
+}
+
+func TestSecurityContextHelm(t *testing.T) {
+	t.Parallel()
+	role := securityContextTestLoadRole(t, "hardened")
+
+	sc := getSecurityContext(role, true)
+	require.NotNil(t, sc)
+
+	t.Run("Overridden", func(t *testing.T) {
+		t.Parallel()
+		actual, err := RoundtripNode(sc, map[string]interface{}{
+			"Values.sizing.hardened.capabilities":                                []interface{}{},
+			"Values.sizing.hardened.security_context.run_as_user":                0,
+			"Values.sizing.hardened.security_context.run_as_non_root":            false,
+			"Values.sizing.hardened.security_context.allow_privilege_escalation": nil,
+			"Values.sizing.hardened.security_context.seccomp_profile":            "localhost/custom.json",
+		})
+		require.NoError(t, err)
+		testhelpers.IsYAMLEqualString(assert.New(t), `---
+			capabilities:
+				add: ~
+			runAsUser: 0
+			runAsNonRoot: false
+			seccompProfile:
+				type: "Localhost"
+				localhostProfile: "custom.json"
+		`, actual)
+	})
+
+	t.Run("Unset", func(t *testing.T) {
+		t.Parallel()
+		actual, err := RoundtripNode(sc, map[string]interface{}{
+			"Values.sizing.hardened.capabilities":                     []interface{}{},
+			"Values.sizing.hardened.security_context.run_as_user":     1000,
+			"Values.sizing.hardened.security_context.seccomp_profile": "RuntimeDefault",
+		})
+		require.NoError(t, err)
+		testhelpers.IsYAMLEqualString(assert.New(t), `---
+			capabilities:
+				add: ~
+			runAsUser: 1000
+			seccompProfile:
+				type: "RuntimeDefault"
+		`, actual)
+	})
+
+	podSC := getPodSecurityContext(role, true)
+	require.NotNil(t, podSC)
+	actual, err := RoundtripNode(podSC, map[string]interface{}{
+		"Values.sizing.hardened.security_context.fs_group": 3000,
+	})
+	require.NoError(t, err)
+	testhelpers.IsYAMLEqualString(assert.New(t), `---
+		fsGroup: 3000
+	`, actual)
+}
+
+func TestSecurityContextHelmWithoutManifestSettings(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	role := securityContextTestLoadRole(t, "plain")
+
+	assert.Nil(getSecurityContext(role, false))
+	assert.Nil(getPodSecurityContext(role, false))
+
+	sc := getSecurityContext(role, true)
+	require.NotNil(t, sc)
+	actual, err := RoundtripNode(sc, map[string]interface{}{
+		"Values.sizing.plain.capabilities":                     []interface{}{},
+		"Values.sizing.plain.security_context.run_as_user":     1000,
+		"Values.sizing.plain.security_context.seccomp_profile": "RuntimeDefault",
+	})
+	require.NoError(t, err)
+	testhelpers.IsYAMLEqualString(assert, `---
+		capabilities:
+			add: ~
+		runAsUser: 1000
+		seccompProfile:
+			type: "RuntimeDefault"
+	`, actual)
+
+	podSC := getPodSecurityContext(role, true)
+	require.NotNil(t, podSC)
+	actual, err = RoundtripNode(podSC, map[string]interface{}{
+		"Values.sizing.plain.security_context.fs_group": 3000,
+	})
+	require.NoError(t, err)
+	testhelpers.IsYAMLEqualString(assert, `---
+		fsGroup: 3000
+	`, actual)
+
+	values := makeSecurityContextValues(role)
+	assert.Equal([]string{
+		"allow_privilege_escalation",
+		"apparmor_profile",
+		"fs_group",
+		"read_only_root_filesystem",
+		"run_as_group",
+		"run_as_non_root",
+		"run_as_user",
+		"seccomp_profile",
+	}, values.Names())
+	for _, name := range values.Names() {
+		assert.Equal("~", values.Get(name).String(), "%s should default to null", name)
+	}
+}
+
+func TestSecurityContextValues(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	role := securityContextTestLoadRole(t, "hardened")
+
+	values := makeSecurityContextValues(role)
+	assert.Equal("1000", values.Get("run_as_user").String())
+	assert.Equal("2000", values.Get("fs_group").String())
+	assert.Equal("false", values.Get("allow_privilege_escalation").String())
+	assert.Equal("runtime/default", values.Get("apparmor_profile").String())
+
+	values = makeSecurityContextValues(role.GetColocatedRoles()[0])
+	assert.Equal("0", values.Get("run_as_user").String())
+	assert.Equal("~", values.Get("run_as_group").String())
+	assert.Nil(values.Get("fs_group"), "Colocated containers have no file system group")
+}
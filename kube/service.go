@@ -64,9 +64,15 @@ const (
 	newServiceTypePublic   // Create a public endpoint service (externally visible traffic)
 )
 
+// publicIngressPortCondition is the helm condition for public services to
+// expose ports which have an ingress
+const publicIngressPortCondition = "or (not .Values.services.ingress.enabled) .Values.services.ingress.keep_public_services"
+
 // newService creates a new k8s service (ClusterIP or LoadBalanced)
 func newService(role *model.Role, serviceType newServiceType, settings ExportSettings) (helm.Node, error) {
 	var ports []helm.Node
+	// Whether all public ports have an ingress
+	allIngress := true
 	for _, port := range role.Run.ExposedPorts {
 		if serviceType == newServiceTypePublic && !port.Public {
 			// Skip non-public ports when creating public services
@@ -128,9 +134,15 @@ func newService(role *model.Role, serviceType newServiceType, settings ExportSet
 				} else {
 					newPort.Add("targetPort", portName)
 				}
+				if serviceType == newServiceTypePublic && settings.CreateHelmChart && port.Ingress != nil {
+					newPort.Set(helm.Block("if " + publicIngressPortCondition))
+				}
 				ports = append(ports, newPort)
 			}
 		}
+		if serviceType == newServiceTypePublic && port.Ingress == nil {
+			allIngress = false
+		}
 	}
 	if len(ports) == 0 {
 		// Kubernetes refuses to create services with no ports, so we should
@@ -171,8 +183,15 @@ func newService(role *model.Role, serviceType newServiceType, settings ExportSet
 		panic(fmt.Sprintf("Unexpected service type %d", serviceType))
 	}
 	service := newTypeMeta("v1", "Service")
-	service.Add("metadata", helm.NewMapping("name", serviceName))
+	meta := helm.NewMapping("name", serviceName)
+	addChartLabels(meta, settings)
+	service.Add("metadata", meta)
 	service.Add("spec", spec.Sort())
 
+	if serviceType == newServiceTypePublic && settings.CreateHelmChart && allIngress {
+		// An ingress may replace the whole service
+		service.Set(helm.Block("if " + publicIngressPortCondition))
+	}
+
 	return service, nil
 }
@@ -1,6 +1,7 @@
 package kube
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -102,6 +103,11 @@ func TestServiceHelm(t *testing.T) {
 			apiVersion: "v1"
 			kind: "Service"
 			metadata:
+				labels:
+					app.kubernetes.io/instance: "fissile"
+					app.kubernetes.io/managed-by: "Tiller"
+					app.kubernetes.io/name: "fissile.name"
+					helm.sh/chart: "fissile.chart"
 				name: "myrole"
 			spec:
 				ports:
@@ -130,6 +136,11 @@ func TestServiceHelm(t *testing.T) {
 			apiVersion: "v1"
 			kind: "Service"
 			metadata:
+				labels:
+					app.kubernetes.io/instance: "fissile"
+					app.kubernetes.io/managed-by: "Tiller"
+					app.kubernetes.io/name: "fissile.name"
+					helm.sh/chart: "fissile.chart"
 				name: "myrole"
 			spec:
 				ports:
@@ -215,6 +226,11 @@ func TestHeadlessServiceHelm(t *testing.T) {
 			apiVersion: "v1"
 			kind: "Service"
 			metadata:
+				labels:
+					app.kubernetes.io/instance: "fissile"
+					app.kubernetes.io/managed-by: "Tiller"
+					app.kubernetes.io/name: "fissile.name"
+					helm.sh/chart: "fissile.chart"
 				name: "myrole-set"
 			spec:
 				clusterIP: "None"
@@ -244,6 +260,11 @@ func TestHeadlessServiceHelm(t *testing.T) {
 			apiVersion: "v1"
 			kind: "Service"
 			metadata:
+				labels:
+					app.kubernetes.io/instance: "fissile"
+					app.kubernetes.io/managed-by: "Tiller"
+					app.kubernetes.io/name: "fissile.name"
+					helm.sh/chart: "fissile.chart"
 				name: "myrole-set"
 			spec:
 				clusterIP: "None"
@@ -326,6 +347,11 @@ func TestPublicServiceHelm(t *testing.T) {
 			apiVersion: "v1"
 			kind: "Service"
 			metadata:
+				labels:
+					app.kubernetes.io/instance: "fissile"
+					app.kubernetes.io/managed-by: "Tiller"
+					app.kubernetes.io/name: "fissile.name"
+					helm.sh/chart: "fissile.chart"
 				name: "myrole-public"
 			spec:
 				externalIPs: "[127.0.0.1,127.0.0.2]"
@@ -352,6 +378,11 @@ func TestPublicServiceHelm(t *testing.T) {
 			apiVersion: "v1"
 			kind: "Service"
 			metadata:
+				labels:
+					app.kubernetes.io/instance: "fissile"
+					app.kubernetes.io/managed-by: "Tiller"
+					app.kubernetes.io/name: "fissile.name"
+					helm.sh/chart: "fissile.chart"
 				name: "myrole-public"
 			spec:
 				ports:
@@ -410,6 +441,12 @@ func TestActivePassiveService(t *testing.T) {
 					panic("Unexpected variant " + variant)
 				}
 
+				// Helm charts label services with the release and chart
+				chartLabels := ""
+				if variant != withKube {
+					chartLabels = `labels: { app.kubernetes.io/instance: fissile, app.kubernetes.io/managed-by: Tiller, app.kubernetes.io/name: fissile.name, helm.sh/chart: fissile.chart }`
+				}
+
 				for _, clustering := range []string{withClustering, withOutClustering} {
 					func(clustering string) {
 						t.Run(clustering, func(t *testing.T) {
@@ -439,11 +476,12 @@ func TestActivePassiveService(t *testing.T) {
 								if assert.NotNil(t, headlessService, "headless service not found") {
 									actual, err := roundTrip(headlessService)
 									if assert.NoError(t, err) {
-										expected := `---
+										expected := fmt.Sprintf(`---
 											apiVersion: v1
 											kind: Service
 											metadata:
 												name: myrole-set
+												%s
 											spec:
 												clusterIP: None
 												ports:
@@ -460,7 +498,7 @@ func TestActivePassiveService(t *testing.T) {
 												selector:
 													skiff-role-name: myrole
 													skiff-role-active: "true"
-										`
+										`, chartLabels)
 										testhelpers.IsYAMLEqualString(assert.New(t), expected, actual)
 									}
 								}
@@ -472,11 +510,12 @@ func TestActivePassiveService(t *testing.T) {
 								actual, err := roundTrip(privateService)
 								if assert.NoError(t, err) {
 
-									expected := `---
+									expected := fmt.Sprintf(`---
 										apiVersion: v1
 										kind: Service
 										metadata:
 											name: myrole
+											%s
 										spec:
 											ports:
 											-
@@ -492,7 +531,7 @@ func TestActivePassiveService(t *testing.T) {
 											selector:
 												skiff-role-name: myrole
 												skiff-role-active: "true"
-									`
+									`, chartLabels)
 									testhelpers.IsYAMLEqualString(assert.New(t), expected, actual)
 								}
 							}
@@ -500,11 +539,12 @@ func TestActivePassiveService(t *testing.T) {
 							if assert.NotNil(t, publicService, "public service not found") {
 								actual, err := roundTrip(publicService)
 								if assert.NoError(t, err) {
-									expected := `---
+									expected := fmt.Sprintf(`---
 										apiVersion: v1
 										kind: Service
 										metadata:
 											name: myrole-public
+											%s
 										spec:
 											externalIPs: [ 192.0.2.42 ]
 											ports:
@@ -516,7 +556,7 @@ func TestActivePassiveService(t *testing.T) {
 											selector:
 												skiff-role-name: myrole
 												skiff-role-active: "true"
-									`
+									`, chartLabels)
 									switch variant {
 									case withHelmLoadBalancer:
 										expected = strings.Replace(expected, "externalIPs: [ 192.0.2.42 ]", "type: LoadBalancer", 1)
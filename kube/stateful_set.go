@@ -29,6 +29,8 @@ func NewStatefulSet(role *model.Role, settings ExportSettings, grapher util.Mode
 	claims := getVolumeClaims(role, settings.CreateHelmChart)
 
 	spec := helm.NewMapping()
+	// The selector is optional before apps/v1, and must match the pod labels
+	spec.Add("selector", newSelector(role.Name))
 	spec.Add("serviceName", fmt.Sprintf("%s-set", role.Name))
 	spec.Add("template", podTemplate)
 	// "updateStrategy" is new in kube 1.7, so we don't add anything to non-helm configs
@@ -46,7 +48,7 @@ func NewStatefulSet(role *model.Role, settings ExportSettings, grapher util.Mode
 	}
 	spec.Add("podManagementPolicy", podManagementPolicy)
 
-	statefulSet := newKubeConfig("apps/v1beta1", "StatefulSet", role.Name, helm.Comment(role.GetLongDescription()))
+	statefulSet := newKubeConfig(settings, settings.apiVersion("StatefulSet"), "StatefulSet", role.Name, helm.Comment(role.GetLongDescription()))
 	statefulSet.Add("spec", spec)
 	err = replicaCheck(role, statefulSet, svcList, settings)
 	if err != nil {
@@ -157,6 +157,31 @@ func TestStatefulSetPorts(t *testing.T) {
 
 // TestStatefulSetServices checks that the services associated with a service
 // are created correctly.
+func TestStatefulSetAPIVersion(t *testing.T) {
+	manifest, role := statefulSetTestLoadManifest(assert.New(t), "exposed-ports.yml")
+	if manifest == nil || role == nil {
+		return
+	}
+
+	statefulset, _, err := NewStatefulSet(role, ExportSettings{KubeVersion: &ClusterVersion{1, 9}}, nil)
+	require.NoError(t, err)
+
+	actual, err := RoundtripKube(statefulset)
+	require.NoError(t, err)
+	testhelpers.IsYAMLSubsetString(assert.New(t), `---
+		apiVersion: "apps/v1"
+		kind: "StatefulSet"
+		spec:
+			selector:
+				matchLabels:
+					skiff-role-name: "myrole"
+			template:
+				metadata:
+					labels:
+						skiff-role-name: "myrole"
+	`, actual)
+}
+
 func TestStatefulSetServices(t *testing.T) {
 	t.Parallel()
 	for _, variant := range []string{"headless", "headed"} {
@@ -33,9 +33,11 @@ func newSelector(name string) *helm.Mapping {
 }
 
 // newKubeConfig sets up generic a Kube config structure with minimal metadata
-func newKubeConfig(apiVersion, kind string, name string, modifiers ...helm.NodeModifier) *helm.Mapping {
+func newKubeConfig(settings ExportSettings, apiVersion, kind string, name string, modifiers ...helm.NodeModifier) *helm.Mapping {
 	mapping := newTypeMeta(apiVersion, kind, modifiers...)
-	mapping.Add("metadata", newObjectMeta(name))
+	meta := newObjectMeta(name)
+	addChartLabels(meta, settings)
+	mapping.Add("metadata", meta)
 	return mapping
 }
 
@@ -82,5 +84,11 @@ func MakeBasicValues() *helm.Mapping {
 		"sizing", helm.NewMapping(),
 		"secrets", helm.NewMapping(),
 		"services", helm.NewMapping(
-			"loadbalanced", false))
+			"loadbalanced", false,
+			"ingress", helm.NewMapping(
+				"enabled", helm.NewNode(false, helm.Comment("Route HTTP(S) traffic to ports declaring an ingress through Ingress resources")),
+				"keep_public_services", helm.NewNode(false, helm.Comment("Also expose ports with an ingress through the public services")),
+				"domain", helm.NewNode("", helm.Comment("Domain completing the ingress host names")),
+				"class", helm.NewNode("", helm.Comment("Ingress class of the ingress controller to use")),
+				"tls_secret", helm.NewNode(nil, helm.Comment("Secret with the TLS certificate for ingresses; defaults to <role>-ingress-tls")))))
 }
@@ -171,8 +171,55 @@ func MakeValues(settings ExportSettings) (helm.Node, error) {
 			entry.Add("ports", ports.Sort())
 		}
 
+		entry.Add("security_context", makeSecurityContextValues(role),
+			helm.Comment("Security context settings of the role; unset settings are null"))
+
 		entry.Add("affinity", helm.NewMapping(), helm.Comment("Node affinity rules can be specified here"))
 
+		if !role.IsColocatedContainerRole() {
+			nodeSelector := helm.NewMapping()
+			for name, value := range role.Run.NodeSelector {
+				nodeSelector.Add(name, value)
+			}
+			entry.Add("tolerations", getTolerations(role),
+				helm.Comment("Node taints the pods tolerate"))
+			entry.Add("node_selector", nodeSelector.Sort(),
+				helm.Comment("Node labels the pods must be scheduled on"))
+			entry.Add("priority_class_name", optionalString(role.Run.PriorityClassName),
+				helm.Comment("Priority class of the pods"))
+			entry.Add("topology_spread_constraints", getTopologySpreadConstraints(role),
+				helm.Comment("Constraints spreading the pods across zones or nodes"))
+		}
+
+		if autoscaling := role.Run.Autoscaling; autoscaling != nil {
+			var cpu, memory interface{}
+			if autoscaling.TargetCPUUtilization != nil {
+				cpu = *autoscaling.TargetCPUUtilization
+			}
+			if autoscaling.TargetMemoryUtilization != nil {
+				memory = *autoscaling.TargetMemoryUtilization
+			}
+			minCount := minReplicas(role.Run.Scaling)
+			entry.Add("autoscaling", helm.NewMapping(
+				"enabled", helm.NewNode(true, helm.Comment("Scale the role automatically, instead of to the count above")),
+				"min_replicas", helm.NewNode(minCount, helm.Comment(fmt.Sprintf("At least %d", minCount))),
+				"max_replicas", helm.NewNode(role.Run.Scaling.Max, helm.Comment(fmt.Sprintf("At most %d", role.Run.Scaling.Max))),
+				"target_cpu_utilization", helm.NewNode(cpu, helm.Comment("Target average CPU utilization, in percent of the CPU request")),
+				"target_memory_utilization", helm.NewNode(memory, helm.Comment("Target average memory utilization, in percent of the memory request"))))
+		}
+
+		if role.Type == model.RoleTypeBosh && role.Run.Scaling.Max > 1 {
+			comment := "Instances that must stay available when pods are evicted, e.g. when draining nodes."
+			if role.Run.Scaling.MustBeOdd {
+				comment += "\nDefaults to a majority of the instances, to keep a quorum."
+			} else {
+				comment += "\nDefaults to all instances but one."
+			}
+			entry.Add("disruption_budget", helm.NewMapping(
+				"enabled", helm.NewNode(true, helm.Comment("Create a PodDisruptionBudget for the role")),
+				"min_available", helm.NewNode(nil, helm.Comment(comment+"\nA count, or a percentage like \"50%\"."))))
+		}
+
 		sizing.Add(makeVarName(role.Name), entry.Sort(), helm.Comment(role.GetLongDescription()))
 	}
 	values.Add("sizing", sizing.Sort())
@@ -78,6 +78,127 @@ func TestMakeValues(t *testing.T) {
 		assert.Contains(t, sizing.Comment(), "underscore")
 	})
 
+	t.Run("Disruption Budget", func(t *testing.T) {
+		t.Parallel()
+		settings := ExportSettings{
+			OutputDir: outDir,
+			RoleManifest: &model.RoleManifest{
+				Roles: model.Roles{
+					&model.Role{
+						Name: "single",
+						Type: model.RoleTypeBosh,
+						Run: &model.RoleRun{
+							Scaling: &model.RoleRunScaling{Min: 1, Max: 1},
+						},
+					},
+					&model.Role{
+						Name: "quorum",
+						Type: model.RoleTypeBosh,
+						Run: &model.RoleRun{
+							Scaling: &model.RoleRunScaling{Min: 1, Max: 5, MustBeOdd: true},
+						},
+					},
+				},
+				Configuration: &model.Configuration{},
+			},
+		}
+
+		node, err := MakeValues(settings)
+		assert.NoError(t, err)
+		require.NotNil(t, node)
+
+		assert.Nil(t, node.Get("sizing", "single", "disruption_budget"), "Roles that cannot scale should have no budget")
+
+		budget := node.Get("sizing", "quorum", "disruption_budget")
+		if assert.NotNil(t, budget) {
+			assert.Equal(t, "true", budget.Get("enabled").String())
+			assert.Equal(t, "~", budget.Get("min_available").String())
+			assert.Contains(t, budget.Get("min_available").Comment(), "quorum")
+		}
+	})
+
+	t.Run("Autoscaling", func(t *testing.T) {
+		t.Parallel()
+		cpu := 75
+		settings := ExportSettings{
+			OutputDir: outDir,
+			RoleManifest: &model.RoleManifest{
+				Roles: model.Roles{
+					&model.Role{
+						Name: "fixed",
+						Type: model.RoleTypeBosh,
+						Run: &model.RoleRun{
+							Scaling: &model.RoleRunScaling{Min: 1, Max: 3},
+						},
+					},
+					&model.Role{
+						Name: "web",
+						Type: model.RoleTypeBosh,
+						Run: &model.RoleRun{
+							Scaling:     &model.RoleRunScaling{Min: 0, Max: 10},
+							Autoscaling: &model.RoleRunAutoscaling{TargetCPUUtilization: &cpu},
+						},
+					},
+				},
+				Configuration: &model.Configuration{},
+			},
+		}
+
+		node, err := MakeValues(settings)
+		assert.NoError(t, err)
+		require.NotNil(t, node)
+
+		assert.Nil(t, node.Get("sizing", "fixed", "autoscaling"), "Roles without autoscaling should have no autoscaling values")
+
+		autoscaling := node.Get("sizing", "web", "autoscaling")
+		if assert.NotNil(t, autoscaling) {
+			assert.Equal(t, "true", autoscaling.Get("enabled").String())
+			assert.Equal(t, "1", autoscaling.Get("min_replicas").String())
+			assert.Equal(t, "10", autoscaling.Get("max_replicas").String())
+			assert.Equal(t, "75", autoscaling.Get("target_cpu_utilization").String())
+			assert.Equal(t, "~", autoscaling.Get("target_memory_utilization").String())
+		}
+	})
+
+	t.Run("Scheduling", func(t *testing.T) {
+		t.Parallel()
+		settings := ExportSettings{
+			OutputDir: outDir,
+			RoleManifest: &model.RoleManifest{
+				Roles: model.Roles{
+					&model.Role{
+						Name: "scheduled",
+						Type: model.RoleTypeBosh,
+						Run: &model.RoleRun{
+							Scaling:           &model.RoleRunScaling{Min: 1, Max: 1},
+							Tolerations:       []*model.RoleRunToleration{{Operator: "Exists"}},
+							NodeSelector:      map[string]string{"disktype": "ssd"},
+							PriorityClassName: "high-priority",
+						},
+					},
+					&model.Role{
+						Name: "sidecar",
+						Type: model.RoleTypeColocatedContainer,
+						Run: &model.RoleRun{
+							Scaling: &model.RoleRunScaling{Min: 1, Max: 1},
+						},
+					},
+				},
+				Configuration: &model.Configuration{},
+			},
+		}
+
+		node, err := MakeValues(settings)
+		assert.NoError(t, err)
+		require.NotNil(t, node)
+
+		assert.Equal(t, "Exists", node.Get("sizing", "scheduled", "tolerations").Values()[0].Get("operator").String())
+		assert.Equal(t, "ssd", node.Get("sizing", "scheduled", "node_selector", "disktype").String())
+		assert.Equal(t, "high-priority", node.Get("sizing", "scheduled", "priority_class_name").String())
+		assert.Empty(t, node.Get("sizing", "scheduled", "topology_spread_constraints").Values())
+		assert.Nil(t, node.Get("sizing", "sidecar", "tolerations"), "Colocated containers are not scheduled on their own")
+	})
+
 	t.Run("Check Default Registry", func(t *testing.T) {
 		t.Parallel()
 		settings := ExportSettings{
@@ -43,7 +43,7 @@ func main() {
 	f := app.NewFissileApplication(version, ui)
 
 	if err := cmd.Execute(f, version); err != nil {
-		ui.Println(color.RedString("%v", err))
+		f.UI.Println(color.RedString("%v", err))
 		sigint.DefaultHandler.Exit(1)
 	}
 }
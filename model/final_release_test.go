@@ -169,3 +169,55 @@ func TestFinalReleaseJobExtractOk(t *testing.T) {
 
 	assert.Nil(util.ValidatePath(extractedPath, true, "extracted job dir"))
 }
+
+func TestCompiledReleaseOk(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	compiledReleasePath := filepath.Join(workDir, "../test-assets/test-compiled-release")
+
+	release, err := NewFinalRelease(compiledReleasePath)
+	if !assert.NoError(err) {
+		return
+	}
+
+	assert.Equal("test-compiled", release.Name)
+	assert.True(release.IsCompiled())
+	assert.Equal("Compiled", release.ReleaseType())
+	assert.Len(release.Packages, 2)
+	assert.Len(release.Jobs, 2)
+
+	barPkg, err := release.LookupPackage("bar")
+	if assert.NoError(err) {
+		assert.True(barPkg.IsCompiled())
+		assert.Equal("ubuntu-trusty/3586.40", barPkg.Stemcell)
+		assert.Equal(filepath.Join(compiledReleasePath, "compiled_packages", "bar.tgz"), barPkg.Path)
+		assert.NoError(barPkg.ValidateSHA1())
+		if assert.Len(barPkg.Dependencies, 1) {
+			assert.Equal("foo", barPkg.Dependencies[0].Name)
+		}
+
+		stemcellOS, stemcellVersion, err := barPkg.StemcellOSAndVersion()
+		assert.NoError(err)
+		assert.Equal("ubuntu-trusty", stemcellOS)
+		assert.Equal("3586.40", stemcellVersion)
+	}
+}
+
+func TestFinalReleaseIsNotCompiled(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	release, err := NewFinalRelease(filepath.Join(workDir, "../test-assets/test-final-release"))
+	if assert.NoError(err) {
+		assert.False(release.IsCompiled())
+		assert.Equal("Final", release.ReleaseType())
+
+		_, _, err = release.Packages[0].StemcellOSAndVersion()
+		assert.Error(err)
+	}
+}
@@ -0,0 +1,221 @@
+package model
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/SUSE/fissile/validation"
+)
+
+// loadIncludes merges the roles, variables, templates and authorization of
+// the files included by the role manifest into it.  Includes are files or
+// glob patterns relative to the file including them, and included files may
+// include further files.  Each file must keep its variables sorted, and the
+// merged variables are sorted again.
+func (m *RoleManifest) loadIncludes() validation.ErrorList {
+	allErrs := validation.ErrorList{}
+	if len(m.Includes) == 0 {
+		return allErrs
+	}
+
+	allErrs = append(allErrs, validateIncludeSorting(m.Configuration.Variables, "configuration.variables")...)
+
+	included := make(map[string]bool)
+	if absPath, err := filepath.Abs(m.manifestFilePath); err == nil {
+		included[absPath] = true
+	}
+	allErrs = append(allErrs, m.mergeIncludes(m.files[0], m.Includes, included)...)
+
+	sort.Sort(m.Configuration.Variables)
+	// The merged role manifest stands on its own
+	m.Includes = nil
+
+	return allErrs
+}
+
+// mergeIncludes merges the files included by the given file, and the files
+// they include in turn, into the role manifest.  Files already included are
+// reported, which also catches include cycles.
+func (m *RoleManifest) mergeIncludes(including *yamlFile, includes []string, included map[string]bool) validation.ErrorList {
+	allErrs := validation.ErrorList{}
+
+	// The errors of a pattern are located at it in the including file
+	locate := func(err *validation.Error, pattern string) *validation.Error {
+		err.Position, _ = including.locate(fmt.Sprintf("includes[%s]", pattern))
+		return err
+	}
+
+	for _, pattern := range includes {
+		paths, err := filepath.Glob(filepath.Join(filepath.Dir(including.path), pattern))
+		if err != nil {
+			allErrs = append(allErrs, locate(validation.Invalid("includes", pattern, err.Error()), pattern))
+			continue
+		}
+		if len(paths) == 0 {
+			allErrs = append(allErrs, locate(validation.NotFound("includes", pattern), pattern))
+			continue
+		}
+
+		for _, path := range paths {
+			name := m.includeName(path)
+			absPath, err := filepath.Abs(path)
+			if err != nil {
+				allErrs = append(allErrs, locate(validation.Invalid("includes", name, err.Error()), pattern))
+				continue
+			}
+			if included[absPath] {
+				allErrs = append(allErrs, locate(validation.Duplicate("includes", name), pattern))
+				continue
+			}
+			included[absPath] = true
+
+			field := fmt.Sprintf("includes[%s]", name)
+			include, keyErrs, err := loadRoleManifestFile(path, field)
+			if err != nil {
+				allErrs = append(allErrs, locate(validation.Invalid("includes", name, err.Error()), pattern))
+				continue
+			}
+			allErrs = append(allErrs, keyErrs...)
+			allErrs = append(allErrs, m.mergeInclude(include, field)...)
+			m.files = append(m.files, include.files...)
+			allErrs = append(allErrs, m.mergeIncludes(include.files[0], include.Includes, included)...)
+		}
+	}
+
+	return allErrs
+}
+
+// mergeInclude merges the roles, variables, templates and authorization of an
+// included file into the role manifest, reporting the ones it already has
+func (m *RoleManifest) mergeInclude(include *RoleManifest, field string) validation.ErrorList {
+	allErrs := validation.ErrorList{}
+
+	for _, role := range include.Roles {
+		if m.LookupRole(role.Name) != nil {
+			allErrs = append(allErrs, validation.Duplicate(field+".roles", role.Name))
+			continue
+		}
+		// Scripts of the role are relative to the included file
+		role.manifestFilePath = include.manifestFilePath
+		m.Roles = append(m.Roles, role)
+	}
+
+	allErrs = append(allErrs, validateIncludeSorting(include.Configuration.Variables,
+		field+".configuration.variables")...)
+	variables := make(map[string]bool)
+	for _, cv := range m.Configuration.Variables {
+		variables[cv.Name] = true
+	}
+	for _, cv := range include.Configuration.Variables {
+		if variables[cv.Name] {
+			allErrs = append(allErrs, validation.Duplicate(field+".configuration.variables", cv.Name))
+			continue
+		}
+		variables[cv.Name] = true
+		m.Configuration.Variables = append(m.Configuration.Variables, cv)
+	}
+
+	allErrs = append(allErrs, mergeIncludeMap(m.Configuration.Templates, include.Configuration.Templates,
+		field+".configuration.templates")...)
+
+	auth := &m.Configuration.Authorization
+	if auth.Roles == nil {
+		auth.Roles = make(map[string]AuthRole)
+	}
+	if auth.Accounts == nil {
+		auth.Accounts = make(map[string]AuthAccount)
+	}
+	allErrs = append(allErrs, mergeIncludeMap(auth.Roles, include.Configuration.Authorization.Roles,
+		field+".configuration.auth.roles")...)
+	allErrs = append(allErrs, mergeIncludeMap(auth.Accounts, include.Configuration.Authorization.Accounts,
+		field+".configuration.auth.accounts")...)
+
+	// The errors are located in the included file, at the duplicated entries
+	for _, err := range allErrs {
+		entry := strings.TrimPrefix(err.Field, field+".")
+		if err.Type == validation.ErrorTypeDuplicate {
+			entry = fmt.Sprintf("%s[%v]", entry, err.BadValue)
+		}
+		err.Position = include.files.position(entry)
+	}
+
+	return allErrs
+}
+
+// validateIncludeSorting reports the variables of a file which are out of
+// order; duplicates are reported once the files are merged
+func validateIncludeSorting(variables ConfigurationVariableSlice, field string) validation.ErrorList {
+	allErrs := validation.ErrorList{}
+	for i := 1; i < len(variables); i++ {
+		if variables[i].Name < variables[i-1].Name {
+			allErrs = append(allErrs, validation.Invalid(field, variables[i-1].Name,
+				fmt.Sprintf("Does not sort before '%s'", variables[i].Name)))
+		}
+	}
+	return allErrs
+}
+
+// mergeIncludeMap adds the entries of an included map to a map of the role
+// manifest, in the order of their keys, reporting the keys it already has.
+// The maps are map[string]string, map[string]AuthRole or
+// map[string]AuthAccount.
+func mergeIncludeMap(target, include interface{}, field string) validation.ErrorList {
+	allErrs := validation.ErrorList{}
+
+	var keys []string
+	switch include := include.(type) {
+	case map[string]string:
+		for key := range include {
+			keys = append(keys, key)
+		}
+	case map[string]AuthRole:
+		for key := range include {
+			keys = append(keys, key)
+		}
+	case map[string]AuthAccount:
+		for key := range include {
+			keys = append(keys, key)
+		}
+	default:
+		panic(fmt.Sprintf("Unexpected included map %T", include))
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		var duplicate bool
+		switch target := target.(type) {
+		case map[string]string:
+			_, duplicate = target[key]
+			if !duplicate {
+				target[key] = include.(map[string]string)[key]
+			}
+		case map[string]AuthRole:
+			_, duplicate = target[key]
+			if !duplicate {
+				target[key] = include.(map[string]AuthRole)[key]
+			}
+		case map[string]AuthAccount:
+			_, duplicate = target[key]
+			if !duplicate {
+				target[key] = include.(map[string]AuthAccount)[key]
+			}
+		}
+		if duplicate {
+			allErrs = append(allErrs, validation.Duplicate(field, key))
+		}
+	}
+
+	return allErrs
+}
+
+// includeName returns the path of an included file relative to the role
+// manifest, to report it
+func (m *RoleManifest) includeName(path string) string {
+	name, err := filepath.Rel(filepath.Dir(m.manifestFilePath), path)
+	if err != nil {
+		return path
+	}
+	return name
+}
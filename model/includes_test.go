@@ -0,0 +1,76 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadRoleManifestIncludes(t *testing.T) {
+	workDir, err := os.Getwd()
+	assert.NoError(t, err)
+
+	torReleasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	torReleasePathBoshCache := filepath.Join(torReleasePath, "bosh-cache")
+	release, err := NewDevRelease(torReleasePath, "", "", torReleasePathBoshCache)
+	assert.NoError(t, err)
+
+	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/model/includes-good.yml")
+	roleManifest, err := LoadRoleManifest(roleManifestPath, []*Release{release}, nil)
+	require.NoError(t, err)
+	require.NotNil(t, roleManifest)
+
+	assert.Empty(t, roleManifest.Includes, "Included files should be merged")
+	require.Len(t, roleManifest.Roles, 2)
+	assert.Equal(t, "myrole", roleManifest.Roles[0].Name)
+	assert.Equal(t, "foorole", roleManifest.Roles[1].Name)
+
+	var variables []string
+	for _, cv := range roleManifest.Configuration.Variables {
+		variables = append(variables, cv.Name)
+	}
+	assert.Equal(t, []string{"BAR", "BAZ", "FOO"}, variables)
+
+	assert.Equal(t, map[string]string{
+		"properties.tor.hashed_control_password": "((BAZ))",
+		"properties.tor.hostname":                "((FOO))",
+		"properties.tor.private_key":             "((BAR))",
+	}, roleManifest.Configuration.Templates)
+
+	assert.Contains(t, roleManifest.Configuration.Authorization.Accounts, "tor-account")
+	assert.Contains(t, roleManifest.Configuration.Authorization.Roles, "tor-role")
+
+	// Scripts are relative to the file declaring the role
+	modelDir := filepath.Dir(roleManifestPath)
+	assert.Equal(t, map[string]string{
+		"myrole.sh": filepath.Join(modelDir, "myrole.sh"),
+	}, roleManifest.Roles[0].GetScriptPaths())
+	assert.Equal(t, map[string]string{
+		"foorole.sh": filepath.Join(modelDir, "includes", "foorole.sh"),
+	}, roleManifest.Roles[1].GetScriptPaths())
+}
+
+func TestLoadRoleManifestIncludesConflicts(t *testing.T) {
+	workDir, err := os.Getwd()
+	assert.NoError(t, err)
+
+	torReleasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	torReleasePathBoshCache := filepath.Join(torReleasePath, "bosh-cache")
+	release, err := NewDevRelease(torReleasePath, "", "", torReleasePathBoshCache)
+	assert.NoError(t, err)
+
+	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/model/includes-bad.yml")
+	roleManifest, err := LoadRoleManifest(roleManifestPath, []*Release{release}, nil)
+	conflictsPath := filepath.Join(filepath.Dir(roleManifestPath), "includes-bad/conflicts.yml")
+	assert.EqualError(t, err, conflictsPath+`:5:1: includes[includes-bad/conflicts.yml].roles: Duplicate value: "myrole"
+`+conflictsPath+`:11:3: includes[includes-bad/conflicts.yml].configuration.variables: Invalid value: "QUX": Does not sort before 'FOO'
+`+conflictsPath+`:13:3: includes[includes-bad/conflicts.yml].configuration.variables: Duplicate value: "FOO"
+`+conflictsPath+`:15:5: includes[includes-bad/conflicts.yml].configuration.templates: Duplicate value: "properties.tor.hostname"
+`+conflictsPath+`:18:7: includes[includes-bad/conflicts.yml].configuration.auth.roles: Duplicate value: "tor-role"
+`+conflictsPath+`:3:1: includes: Duplicate value: "includes/roles.yml"
+`+roleManifestPath+`:6:1: includes: Not found: "missing.yml"`)
+	assert.Nil(t, roleManifest)
+}
@@ -5,6 +5,8 @@ import (
 	"io/ioutil"
 	"reflect"
 
+	"github.com/SUSE/fissile/validation"
+
 	"gopkg.in/yaml.v2"
 )
 
@@ -12,6 +14,9 @@ import (
 type Opinions struct {
 	Light map[string]interface{}
 	Dark  map[string]interface{}
+
+	lightFile *yamlFile // The files of the opinions, to locate validation errors
+	darkFile  *yamlFile
 }
 
 // NewEmptyOpinions returns an empty opinions object, used for testing and
@@ -43,6 +48,7 @@ func NewOpinions(lightFile, darkFile string) (*Opinions, error) {
 	if err != nil {
 		return nil, err
 	}
+	result.lightFile = newYAMLFile(lightFile, manifestContents)
 
 	manifestContents, err = ioutil.ReadFile(darkFile)
 	if err != nil {
@@ -53,10 +59,32 @@ func NewOpinions(lightFile, darkFile string) (*Opinions, error) {
 	if err != nil {
 		return nil, err
 	}
+	result.darkFile = newYAMLFile(darkFile, manifestContents)
 
 	return result, nil
 }
 
+// LightPosition returns the position of a light opinion, named as flattened
+// by FlattenOpinions, such as "properties.tor.hostname"
+func (o *Opinions) LightPosition(property string) validation.Position {
+	return opinionPosition(o.lightFile, property)
+}
+
+// DarkPosition returns the position of a dark opinion, named as flattened by
+// FlattenOpinions
+func (o *Opinions) DarkPosition(property string) validation.Position {
+	return opinionPosition(o.darkFile, property)
+}
+
+// opinionPosition returns the position of an opinion in its file, if any
+func opinionPosition(file *yamlFile, property string) validation.Position {
+	if file == nil {
+		return validation.Position{}
+	}
+	position, _ := file.locate(property)
+	return position
+}
+
 // FlattenOpinions converts the incoming nested map of opinions into a
 // flat map of properties to values (strings). When 'total' is set (to
 // true) array values are recursed into and flattened as well.
@@ -5,6 +5,8 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/SUSE/fissile/validation"
+
 	"github.com/stretchr/testify/assert"
 )
 
@@ -22,6 +24,27 @@ func TestOpinionsLoad(t *testing.T) {
 	assert.NotNil(confOpinions)
 }
 
+func TestOpinionsPosition(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.Nil(err)
+
+	opinionsFile := filepath.Join(workDir, "../test-assets/test-opinions/opinions.yml")
+	opinionsFileDark := filepath.Join(workDir, "../test-assets/test-opinions/dark-opinions.yml")
+
+	confOpinions, err := NewOpinions(opinionsFile, opinionsFileDark)
+	assert.Nil(err)
+
+	assert.Equal(validation.Position{File: opinionsFile, Line: 6, Column: 5},
+		confOpinions.LightPosition("properties.tor.hostname"))
+	assert.Equal(validation.Position{File: opinionsFileDark, Line: 4, Column: 5},
+		confOpinions.DarkPosition("properties.tor.dark-opinion"))
+	assert.Equal(validation.Position{}, confOpinions.LightPosition("unknown"))
+
+	assert.Equal(validation.Position{}, NewEmptyOpinions().LightPosition("properties.tor.hostname"))
+}
+
 func TestGetOpinionForKey(t *testing.T) {
 
 	assert := assert.New(t)
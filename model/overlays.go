@@ -0,0 +1,457 @@
+package model
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// The types of role manifest overlay operations
+const (
+	overlayOperationReplace = "replace"
+	overlayOperationRemove  = "remove"
+)
+
+// roleManifestOverlay is an overlay merged into the role manifest: roles and
+// variables are merged into the ones of the same name, or added, and the
+// templates and authorization are merged into the ones of the role manifest
+type roleManifestOverlay struct {
+	Roles         []map[string]interface{} `yaml:"roles"`
+	Configuration map[string]interface{}   `yaml:"configuration"`
+}
+
+// roleManifestOperation is a role manifest overlay operation, in the style of
+// go-patch (BOSH ops files)
+type roleManifestOperation struct {
+	Type  string      `yaml:"type"`
+	Path  string      `yaml:"path"`
+	Value interface{} `yaml:"value"`
+}
+
+// applyOverlay applies an overlay file to the role manifest.  The overlay is
+// either a mapping, merged into the role manifest, or a list of operations.
+func (m *RoleManifest) applyOverlay(overlayPath string) error {
+	overlayContents, err := ioutil.ReadFile(overlayPath)
+	if err != nil {
+		return err
+	}
+
+	var overlay interface{}
+	if err := yaml.Unmarshal(overlayContents, &overlay); err != nil {
+		return fmt.Errorf("Error loading role manifest overlay %s: %s", overlayPath, err.Error())
+	}
+	switch overlay.(type) {
+	case nil:
+		return nil
+	case map[interface{}]interface{}:
+		file := newYAMLFile(overlayPath, overlayContents)
+		if allErrs := validateRoleManifestKeys(file, ""); len(allErrs) != 0 {
+			return fmt.Errorf("Error loading role manifest overlay %s: %s", overlayPath, allErrs.Errors())
+		}
+		// The settings of the overlay are located in it
+		m.files = append(m.files, file)
+		var merge roleManifestOverlay
+		if err := yaml.Unmarshal(overlayContents, &merge); err != nil {
+			return fmt.Errorf("Error loading role manifest overlay %s: %s", overlayPath, err.Error())
+		}
+		if err := m.mergeOverlay(overlayPath, &merge); err != nil {
+			return fmt.Errorf("Error applying role manifest overlay %s: %s", overlayPath, err.Error())
+		}
+	case []interface{}:
+		var operations []roleManifestOperation
+		if err := yaml.Unmarshal(overlayContents, &operations); err != nil {
+			return fmt.Errorf("Error loading role manifest overlay %s: %s", overlayPath, err.Error())
+		}
+		if err := m.applyOverlayOperations(overlayPath, overlayContents, operations); err != nil {
+			return fmt.Errorf("Error applying role manifest overlay %s: %s", overlayPath, err.Error())
+		}
+	default:
+		return fmt.Errorf("Error loading role manifest overlay %s: expected a mapping or a list of operations", overlayPath)
+	}
+	return nil
+}
+
+// mergeOverlay merges an overlay into the role manifest.  The settings of the
+// overlay replace the ones of the role manifest; lists are replaced as a
+// whole, while mappings are merged.
+func (m *RoleManifest) mergeOverlay(overlayPath string, overlay *roleManifestOverlay) error {
+	for i, roleOverlay := range overlay.Roles {
+		name, ok := roleOverlay["name"].(string)
+		if !ok {
+			return fmt.Errorf("roles[%d] has no name", i)
+		}
+		role := m.LookupRole(name)
+		if role == nil {
+			// Scripts of the role are relative to the overlay
+			role = &Role{manifestFilePath: overlayPath}
+			m.Roles = append(m.Roles, role)
+		}
+		if err := mergeOverlayNode(roleOverlay, role); err != nil {
+			return fmt.Errorf("roles[%s]: %s", name, err.Error())
+		}
+	}
+
+	if overlay.Configuration == nil {
+		return nil
+	}
+
+	if variables, ok := overlay.Configuration["variables"]; ok {
+		variableOverlays, ok := variables.([]interface{})
+		if !ok {
+			return fmt.Errorf("configuration.variables is not a list")
+		}
+		for i, variableOverlay := range variableOverlays {
+			entry, ok := variableOverlay.(map[interface{}]interface{})
+			if !ok {
+				return fmt.Errorf("configuration.variables[%d] is not a mapping", i)
+			}
+			name, ok := entry["name"].(string)
+			if !ok {
+				return fmt.Errorf("configuration.variables[%d] has no name", i)
+			}
+			var cv *ConfigurationVariable
+			for _, variable := range m.Configuration.Variables {
+				if variable.Name == name {
+					cv = variable
+				}
+			}
+			if cv == nil {
+				cv = &ConfigurationVariable{}
+				m.Configuration.Variables = append(m.Configuration.Variables, cv)
+			}
+			if err := mergeOverlayNode(entry, cv); err != nil {
+				return fmt.Errorf("configuration.variables[%s]: %s", name, err.Error())
+			}
+		}
+		sort.Sort(m.Configuration.Variables)
+		delete(overlay.Configuration, "variables")
+	}
+
+	if err := mergeOverlayNode(overlay.Configuration, m.Configuration); err != nil {
+		return fmt.Errorf("configuration: %s", err.Error())
+	}
+	return nil
+}
+
+// mergeOverlayNode merges a node of an overlay into the matching structure of
+// the role manifest
+func mergeOverlayNode(node interface{}, target interface{}) error {
+	contents, err := yaml.Marshal(node)
+	if err != nil {
+		return err
+	}
+	// Unmarshalling keeps the settings missing from the overlay
+	return yaml.Unmarshal(contents, target)
+}
+
+// applyOverlayOperations applies overlay operations to the role manifest, as
+// a document; roles keep the file they were declared in, and the nodes the
+// operations replace are located at their values in the overlay
+func (m *RoleManifest) applyOverlayOperations(overlayPath string, overlayContents []byte, operations []roleManifestOperation) error {
+	contents, err := yaml.Marshal(m)
+	if err != nil {
+		return err
+	}
+	var document interface{}
+	if err := yaml.Unmarshal(contents, &document); err != nil {
+		return err
+	}
+
+	overlayFile := newYAMLFile(overlayPath, overlayContents)
+	file := &yamlFile{path: overlayPath, positions: yamlPositions{}}
+	for index, operation := range operations {
+		document, err = operation.apply(document)
+		if err != nil {
+			return err
+		}
+		if operation.Type == overlayOperationReplace {
+			file.addOperationTarget(overlayFile, index, operation.target(document))
+		}
+	}
+
+	files := append(m.files, file)
+	if allErrs := validateRoleManifestKeys(&yamlFile{path: overlayPath, document: document}, ""); len(allErrs) != 0 {
+		// The keys are located where the operations added them
+		for _, err := range allErrs {
+			err.Position = files.position(err.Field)
+		}
+		return fmt.Errorf("%s", allErrs.Errors())
+	}
+
+	contents, err = yaml.Marshal(document)
+	if err != nil {
+		return err
+	}
+	roleManifest := RoleManifest{manifestFilePath: m.manifestFilePath}
+	if err := yaml.Unmarshal(contents, &roleManifest); err != nil {
+		return err
+	}
+	if roleManifest.Configuration == nil {
+		roleManifest.Configuration = &Configuration{}
+	}
+	if roleManifest.Configuration.Templates == nil {
+		roleManifest.Configuration.Templates = map[string]string{}
+	}
+	for _, role := range roleManifest.Roles {
+		if previous := m.LookupRole(role.Name); previous != nil {
+			role.manifestFilePath = previous.manifestFilePath
+		} else {
+			role.manifestFilePath = overlayPath
+		}
+	}
+
+	// Variables appended by operations are kept in order, as when merged
+	sort.Sort(roleManifest.Configuration.Variables)
+	roleManifest.files = files
+
+	*m = roleManifest
+	return nil
+}
+
+// roleManifestOperationEntry is a list entry on the path of an operation,
+// found by its name if it has one, or else by its index
+type roleManifestOperationEntry struct {
+	index int
+	name  interface{}
+}
+
+// target returns the keys and list entries leading to the node an operation
+// replaced in the document, as applied
+func (o *roleManifestOperation) target(document interface{}) []interface{} {
+	var target []interface{}
+	node := document
+	for _, token := range o.tokens() {
+		token = strings.TrimSuffix(token, "?")
+		switch value := node.(type) {
+		case map[interface{}]interface{}:
+			target = append(target, token)
+			node = value[token]
+		case []interface{}:
+			index := len(value) - 1
+			if token != "-" {
+				var err error
+				if index, err = o.listIndex(value, token, false); err != nil {
+					return target
+				}
+			}
+			entry := roleManifestOperationEntry{index: index}
+			if mapping, ok := value[index].(map[interface{}]interface{}); ok {
+				entry.name = mapping["name"]
+			}
+			target = append(target, entry)
+			node = value[index]
+		default:
+			return target
+		}
+	}
+	return target
+}
+
+// addOperationTarget adds the node an operation of an overlay replaced to the
+// file, at the position of the value of the operation.  The nodes leading to
+// it are at the position of the path of the operation.
+func (f *yamlFile) addOperationTarget(overlayFile *yamlFile, index int, target []interface{}) {
+	operationPath := yamlPositionPath("", index)
+	valuePath := yamlPositionPath(operationPath, "value")
+
+	var value interface{}
+	if operations, ok := overlayFile.document.([]interface{}); ok && index < len(operations) {
+		if operation, ok := operations[index].(map[interface{}]interface{}); ok {
+			value = operation["value"]
+		}
+	}
+
+	var add func(node interface{}, target []interface{}, path string) interface{}
+	add = func(node interface{}, target []interface{}, path string) interface{} {
+		if len(target) == 0 {
+			for overlayPath, position := range overlayFile.positions {
+				if overlayPath == valuePath || strings.HasPrefix(overlayPath, valuePath+"/") {
+					f.positions[path+strings.TrimPrefix(overlayPath, valuePath)] = position
+				}
+			}
+			return value
+		}
+
+		if _, ok := f.positions[path]; !ok && path != "" {
+			if position, ok := overlayFile.positions[yamlPositionPath(operationPath, "path")]; ok {
+				f.positions[path] = position
+			}
+		}
+
+		switch step := target[0].(type) {
+		case string:
+			mapping, ok := node.(map[interface{}]interface{})
+			if !ok {
+				mapping = map[interface{}]interface{}{}
+			}
+			mapping[step] = add(mapping[step], target[1:], yamlPositionPath(path, step))
+			return mapping
+		case roleManifestOperationEntry:
+			list, _ := node.([]interface{})
+			entryIndex := -1
+			if step.name != nil {
+				for i, item := range list {
+					if entry, ok := item.(map[interface{}]interface{}); ok && entry["name"] == step.name {
+						entryIndex = i
+					}
+				}
+				if entryIndex < 0 {
+					list = append(list, map[interface{}]interface{}{"name": step.name})
+					entryIndex = len(list) - 1
+				}
+			} else {
+				// Entries without names keep their index
+				for len(list) <= step.index {
+					list = append(list, nil)
+				}
+				entryIndex = step.index
+			}
+			list[entryIndex] = add(list[entryIndex], target[1:], yamlPositionPath(path, entryIndex))
+			return list
+		}
+		return node
+	}
+	f.document = add(f.document, target, "")
+}
+
+// apply applies the operation to a document, returning the updated document.
+// Paths are made of slash separated keys, list indices, "-" for the end of a
+// list, or name=value to select the list entry of the given name; missing
+// parts of the path after a key ending in "?" are created.
+func (o *roleManifestOperation) apply(document interface{}) (interface{}, error) {
+	if !strings.HasPrefix(o.Path, "/") {
+		return nil, fmt.Errorf("Invalid path %s of %s operation, expected a leading /", o.Path, o.Type)
+	}
+	tokens := o.tokens()
+
+	switch o.Type {
+	case overlayOperationReplace:
+	case overlayOperationRemove:
+		if len(tokens) == 0 {
+			return nil, fmt.Errorf("Cannot remove the whole role manifest")
+		}
+	default:
+		return nil, fmt.Errorf("Invalid operation type %s, expected one of %s or %s",
+			o.Type, overlayOperationReplace, overlayOperationRemove)
+	}
+
+	result, err := o.applyNode(document, tokens, false)
+	if err != nil {
+		return nil, fmt.Errorf("Error applying %s operation to %s: %s", o.Type, o.Path, err.Error())
+	}
+	return result, nil
+}
+
+// tokens returns the unescaped keys and list entries of the path of the
+// operation
+func (o *roleManifestOperation) tokens() []string {
+	var tokens []string
+	if o.Path != "/" && strings.HasPrefix(o.Path, "/") {
+		for _, token := range strings.Split(o.Path[1:], "/") {
+			tokens = append(tokens, strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1))
+		}
+	}
+	return tokens
+}
+
+// applyNode applies the operation to a node of the document, at the path of
+// the remaining tokens, returning the updated node
+func (o *roleManifestOperation) applyNode(node interface{}, tokens []string, create bool) (interface{}, error) {
+	if len(tokens) == 0 {
+		return o.Value, nil
+	}
+	token := tokens[0]
+	if strings.HasSuffix(token, "?") {
+		token = strings.TrimSuffix(token, "?")
+		create = create || o.Type == overlayOperationReplace
+	}
+	last := len(tokens) == 1
+
+	if node == nil && create {
+		if token == "-" || strings.Contains(token, "=") {
+			node = []interface{}{}
+		} else {
+			node = map[interface{}]interface{}{}
+		}
+	}
+
+	switch node := node.(type) {
+	case map[interface{}]interface{}:
+		child, ok := node[token]
+		if !ok && !create {
+			return nil, fmt.Errorf("Key %s not found", token)
+		}
+		if last && o.Type == overlayOperationRemove {
+			delete(node, token)
+			return node, nil
+		}
+		child, err := o.applyNode(child, tokens[1:], create)
+		if err != nil {
+			return nil, err
+		}
+		node[token] = child
+		return node, nil
+
+	case []interface{}:
+		if token == "-" {
+			if !last || o.Type != overlayOperationReplace {
+				return nil, fmt.Errorf("The end of a list can only be replaced")
+			}
+			return append(node, o.Value), nil
+		}
+		index, err := o.listIndex(node, token, create)
+		if err != nil {
+			return nil, err
+		}
+		if index == len(node) {
+			// A new entry with the given name
+			parts := strings.SplitN(token, "=", 2)
+			node = append(node, map[interface{}]interface{}{parts[0]: parts[1]})
+		}
+		if last && o.Type == overlayOperationRemove {
+			return append(node[:index], node[index+1:]...), nil
+		}
+		child, err := o.applyNode(node[index], tokens[1:], create)
+		if err != nil {
+			return nil, err
+		}
+		node[index] = child
+		return node, nil
+
+	default:
+		return nil, fmt.Errorf("Cannot find %s in %v", token, node)
+	}
+}
+
+// listIndex returns the index of the list entry selected by a token, either
+// an index or name=value.  Missing entries selected by name have the index of
+// the end of the list when created.
+func (o *roleManifestOperation) listIndex(list []interface{}, token string, create bool) (int, error) {
+	parts := strings.SplitN(token, "=", 2)
+	if len(parts) == 1 {
+		index, err := strconv.Atoi(token)
+		if err != nil {
+			return 0, fmt.Errorf("Invalid list index %s", token)
+		}
+		if index < 0 || index >= len(list) {
+			return 0, fmt.Errorf("List index %d out of range", index)
+		}
+		return index, nil
+	}
+
+	for index, entry := range list {
+		if entry, ok := entry.(map[interface{}]interface{}); ok {
+			if value, ok := entry[parts[0]]; ok && fmt.Sprintf("%v", value) == parts[1] {
+				return index, nil
+			}
+		}
+	}
+	if !create {
+		return 0, fmt.Errorf("List entry %s not found", token)
+	}
+	return len(list), nil
+}
@@ -0,0 +1,219 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SUSE/fissile/validation"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadRoleManifestOverlays(t *testing.T) {
+	workDir, err := os.Getwd()
+	assert.NoError(t, err)
+
+	torReleasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	torReleasePathBoshCache := filepath.Join(torReleasePath, "bosh-cache")
+	release, err := NewDevRelease(torReleasePath, "", "", torReleasePathBoshCache)
+	assert.NoError(t, err)
+
+	modelDir := filepath.Join(workDir, "../test-assets/role-manifests/model")
+	roleManifest, err := LoadRoleManifest(filepath.Join(modelDir, "includes-good.yml"), []*Release{release}, nil,
+		filepath.Join(modelDir, "overlays/merge.yml"),
+		filepath.Join(modelDir, "overlays/operations.yml"))
+	require.NoError(t, err)
+	require.NotNil(t, roleManifest)
+
+	var roles []string
+	for _, role := range roleManifest.Roles {
+		roles = append(roles, role.Name)
+	}
+	assert.Equal(t, []string{"myrole", "foorole", "newrole", "patchedrole"}, roles)
+
+	myrole := roleManifest.LookupRole("myrole")
+	require.NotNil(t, myrole)
+	assert.Equal(t, 1, myrole.Run.Scaling.Min)
+	assert.Equal(t, 3, myrole.Run.Scaling.Max)
+	require.NotNil(t, myrole.Run.MemRequest)
+	assert.Equal(t, int64(256), *myrole.Run.MemRequest)
+	assert.Equal(t, []string{"NET_ADMIN"}, myrole.Run.Capabilities)
+	assert.Len(t, myrole.RoleJobs, 1, "Jobs missing from the overlay should be kept")
+
+	foorole := roleManifest.LookupRole("foorole")
+	require.NotNil(t, foorole)
+	require.NotNil(t, foorole.Run.MemRequest)
+	assert.Equal(t, int64(128), *foorole.Run.MemRequest)
+	assert.Equal(t, "tor-account", foorole.Run.ServiceAccount)
+
+	var variableNames []string
+	variables := make(map[string]string)
+	for _, cv := range roleManifest.Configuration.Variables {
+		variableNames = append(variableNames, cv.Name)
+		variables[cv.Name] = cv.Description
+	}
+	assert.Equal(t, []string{"ALPHA", "BAR", "FOO", "QUX"}, variableNames, "Variables should be sorted")
+	assert.Equal(t, map[string]string{
+		"ALPHA": "Appended out of order",
+		"BAR":   "",
+		"FOO":   "The tor hostname",
+		"QUX":   "",
+	}, variables)
+
+	assert.Equal(t, map[string]string{
+		"properties.tor.client_keys": "((QUX))",
+		"properties.tor.hostname":    "((FOO)).((ALPHA)).onion",
+		"properties.tor.private_key": "((BAR))",
+	}, roleManifest.Configuration.Templates)
+
+	// Scripts are relative to the file declaring the role
+	assert.Equal(t, map[string]string{
+		"myrole.sh": filepath.Join(modelDir, "myrole.sh"),
+	}, myrole.GetScriptPaths())
+	assert.Equal(t, map[string]string{
+		"foorole.sh": filepath.Join(modelDir, "includes", "foorole.sh"),
+	}, foorole.GetScriptPaths())
+	assert.Equal(t, map[string]string{
+		"newrole.sh": filepath.Join(modelDir, "overlays", "newrole.sh"),
+	}, roleManifest.LookupRole("newrole").GetScriptPaths())
+
+	// Nodes replaced by operations are located at their values
+	operationsPath := filepath.Join(modelDir, "overlays/operations.yml")
+	assert.Equal(t, validation.Position{File: operationsPath, Line: 5, Column: 3},
+		roleManifest.Position("roles[foorole].run.memory"))
+	assert.Equal(t, validation.Position{File: operationsPath, Line: 22, Column: 5},
+		roleManifest.Position("roles[patchedrole].jobs[new_hostname]"))
+}
+
+func TestLoadRoleManifestOverlaysBadValues(t *testing.T) {
+	workDir, err := os.Getwd()
+	assert.NoError(t, err)
+
+	torReleasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	torReleasePathBoshCache := filepath.Join(torReleasePath, "bosh-cache")
+	release, err := NewDevRelease(torReleasePath, "", "", torReleasePathBoshCache)
+	assert.NoError(t, err)
+
+	modelDir := filepath.Join(workDir, "../test-assets/role-manifests/model")
+	overlayPath := filepath.Join(modelDir, "overlays/bad-values.yml")
+	roleManifest, err := LoadRoleManifest(filepath.Join(modelDir, "includes-good.yml"), []*Release{release}, nil, overlayPath)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), overlayPath+":5:3: roles[myrole].run.memory: Invalid value: -10")
+	assert.Nil(t, roleManifest)
+}
+
+func TestLoadRoleManifestOverlaysBadOperations(t *testing.T) {
+	workDir, err := os.Getwd()
+	assert.NoError(t, err)
+
+	modelDir := filepath.Join(workDir, "../test-assets/role-manifests/model")
+	overlayPath := filepath.Join(modelDir, "overlays/bad-operations.yml")
+	roleManifest, err := LoadMergedRoleManifest(filepath.Join(modelDir, "includes-good.yml"), overlayPath)
+	assert.EqualError(t, err, "Error applying role manifest overlay "+overlayPath+
+		": Error applying replace operation to /roles/name=missing/run/memory: List entry name=missing not found")
+	assert.Nil(t, roleManifest)
+}
+
+func TestLoadRoleManifestOverlaysBadKeys(t *testing.T) {
+	workDir, err := os.Getwd()
+	assert.NoError(t, err)
+
+	modelDir := filepath.Join(workDir, "../test-assets/role-manifests/model")
+	overlayPath := filepath.Join(modelDir, "overlays/bad-keys.yml")
+	roleManifest, err := LoadMergedRoleManifest(filepath.Join(modelDir, "includes-good.yml"), overlayPath)
+	assert.EqualError(t, err, "Error applying role manifest overlay "+overlayPath+": "+
+		overlayPath+":5:3: roles[myrole].run.virtual_cpus: Forbidden: Unknown key, did you mean virtual-cpus?")
+	assert.Nil(t, roleManifest)
+}
+
+func TestRoleManifestOperationPaths(t *testing.T) {
+	t.Parallel()
+
+	document := func() interface{} {
+		return map[interface{}]interface{}{
+			"list": []interface{}{
+				map[interface{}]interface{}{"name": "a", "value": 1},
+				map[interface{}]interface{}{"name": "b", "value": 2},
+			},
+			"a/b": "slash",
+		}
+	}
+
+	for _, sample := range []struct {
+		desc      string
+		operation roleManifestOperation
+		expected  interface{}
+		err       string
+	}{
+		{
+			desc:      "replace by index",
+			operation: roleManifestOperation{Type: "replace", Path: "/list/1/value", Value: 3},
+			expected: map[interface{}]interface{}{
+				"list": []interface{}{
+					map[interface{}]interface{}{"name": "a", "value": 1},
+					map[interface{}]interface{}{"name": "b", "value": 3},
+				},
+				"a/b": "slash",
+			},
+		},
+		{
+			desc:      "replace escaped key",
+			operation: roleManifestOperation{Type: "replace", Path: "/a~1b", Value: "escaped"},
+			expected: map[interface{}]interface{}{
+				"list": document().(map[interface{}]interface{})["list"],
+				"a/b":  "escaped",
+			},
+		},
+		{
+			desc:      "create optional entries",
+			operation: roleManifestOperation{Type: "replace", Path: "/list/name=c?/nested/value", Value: 4},
+			expected: map[interface{}]interface{}{
+				"list": []interface{}{
+					map[interface{}]interface{}{"name": "a", "value": 1},
+					map[interface{}]interface{}{"name": "b", "value": 2},
+					map[interface{}]interface{}{"name": "c", "nested": map[interface{}]interface{}{"value": 4}},
+				},
+				"a/b": "slash",
+			},
+		},
+		{
+			desc:      "remove list entry",
+			operation: roleManifestOperation{Type: "remove", Path: "/list/name=a"},
+			expected: map[interface{}]interface{}{
+				"list": []interface{}{
+					map[interface{}]interface{}{"name": "b", "value": 2},
+				},
+				"a/b": "slash",
+			},
+		},
+		{
+			desc:      "missing key",
+			operation: roleManifestOperation{Type: "replace", Path: "/missing/value", Value: 1},
+			err:       "Error applying replace operation to /missing/value: Key missing not found",
+		},
+		{
+			desc:      "index out of range",
+			operation: roleManifestOperation{Type: "remove", Path: "/list/2"},
+			err:       "Error applying remove operation to /list/2: List index 2 out of range",
+		},
+		{
+			desc:      "invalid type",
+			operation: roleManifestOperation{Type: "move", Path: "/list"},
+			err:       "Invalid operation type move, expected one of replace or remove",
+		},
+		{
+			desc:      "relative path",
+			operation: roleManifestOperation{Type: "remove", Path: "list"},
+			err:       "Invalid path list of remove operation, expected a leading /",
+		},
+	} {
+		actual, err := sample.operation.apply(document())
+		if sample.err != "" {
+			assert.EqualError(t, err, sample.err, sample.desc)
+		} else if assert.NoError(t, err, sample.desc) {
+			assert.Equal(t, sample.expected, actual, sample.desc)
+		}
+	}
+}
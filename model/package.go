@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/pivotal-golang/archiver/extractor"
 )
@@ -19,6 +20,9 @@ type Package struct {
 	Release      *Release
 	Path         string
 	Dependencies Packages
+	// Stemcell is the "<os>/<version>" of the stemcell the package was
+	// compiled against; it is only set for packages of compiled releases.
+	Stemcell string
 
 	packageReleaseInfo map[interface{}]interface{}
 }
@@ -118,6 +122,9 @@ func (p *Package) loadPackageInfo() (err error) {
 	p.Version = p.packageReleaseInfo["version"].(string)
 	p.Fingerprint = p.packageReleaseInfo["fingerprint"].(string)
 	p.SHA1 = p.packageReleaseInfo["sha1"].(string)
+	if stemcell, ok := p.packageReleaseInfo["stemcell"]; ok {
+		p.Stemcell = stemcell.(string)
+	}
 	p.Path = p.packageArchivePath()
 
 	return nil
@@ -144,6 +151,10 @@ func (p *Package) loadPackageDependencies() (err error) {
 }
 
 func (p *Package) packageArchivePath() string {
+	if p.IsCompiled() {
+		return filepath.Join(p.Release.Path, compiledPackagesDir, p.Name+".tgz")
+	}
+
 	if p.Release.FinalRelease {
 		return filepath.Join(p.Release.Path, "packages", p.Name+".tgz")
 	}
@@ -151,6 +162,23 @@ func (p *Package) packageArchivePath() string {
 	return filepath.Join(p.Release.DevBOSHCacheDir, p.SHA1)
 }
 
+// IsCompiled returns true if the package comes from a compiled release, i.e.
+// its archive holds the compiled package rather than its sources
+func (p *Package) IsCompiled() bool {
+	return p.Stemcell != ""
+}
+
+// StemcellOSAndVersion splits the stemcell the package was compiled against
+// into its OS and version. It returns an error for source packages.
+func (p *Package) StemcellOSAndVersion() (string, string, error) {
+	parts := strings.SplitN(p.Stemcell, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("Invalid stemcell '%s' for compiled package %s, expected <os>/<version>", p.Stemcell, p.Name)
+	}
+
+	return parts[0], parts[1], nil
+}
+
 // GetTargetPackageSourcesDir returns the path to the sources of the
 // package, underneath the main cache directory
 func (p *Package) GetTargetPackageSourcesDir(workDir string) string {
@@ -180,7 +208,7 @@ func (p *Package) Marshal() (interface{}, error) {
 		dependencies = append(dependencies, dependency.Fingerprint)
 	}
 
-	return map[string]interface{}{
+	result := map[string]interface{}{
 		"name":         p.Name,
 		"version":      p.Version,
 		"fingerprint":  p.Fingerprint,
@@ -188,5 +216,10 @@ func (p *Package) Marshal() (interface{}, error) {
 		"release":      releaseName,
 		"path":         p.Path,
 		"dependencies": dependencies,
-	}, nil
+	}
+	if p.IsCompiled() {
+		result["stemcell"] = p.Stemcell
+	}
+
+	return result, nil
 }
@@ -28,9 +28,10 @@ type Release struct {
 }
 
 const (
-	jobsDir      = "jobs"
-	packagesDir  = "packages"
-	manifestFile = "release.MF"
+	jobsDir             = "jobs"
+	packagesDir         = "packages"
+	compiledPackagesDir = "compiled_packages"
+	manifestFile        = "release.MF"
 )
 
 // yamlBinaryRegexp is the regexp used to look for the "!binary" YAML tag; see
@@ -153,9 +154,41 @@ func (r *Release) loadPackages() (err error) {
 		}
 	}
 
+	// Compiled releases list their packages under a different key; every
+	// one of those carries the stemcell it was compiled against.
+	if packages, ok := r.manifest["compiled_packages"].([]interface{}); ok {
+		for _, pkg := range packages {
+			p, err := newPackage(r, pkg.(map[interface{}]interface{}))
+			if err != nil {
+				return err
+			}
+			if !p.IsCompiled() {
+				return fmt.Errorf("Compiled package %s has no stemcell", p.Name)
+			}
+
+			r.Packages = append(r.Packages, p)
+		}
+	}
+
 	return nil
 }
 
+// IsCompiled returns true if the release is a compiled release, i.e. its
+// packages do not need to be compiled
+func (r *Release) IsCompiled() bool {
+	if len(r.Packages) == 0 {
+		return false
+	}
+
+	for _, pkg := range r.Packages {
+		if !pkg.IsCompiled() {
+			return false
+		}
+	}
+
+	return true
+}
+
 func (r *Release) loadDependenciesForPackages() error {
 	for _, pkg := range r.Packages {
 		if err := pkg.loadPackageDependencies(); err != nil {
@@ -230,8 +263,13 @@ func (r *Release) manifestFilePath() string {
 	return filepath.Join(r.getDevReleaseManifestsDir(), r.getDevReleaseManifestFilename())
 }
 
-// ReleaseType returns a string identifying the type of the release: Dev or Final.
+// ReleaseType returns a string identifying the type of the release: Dev,
+// Final or Compiled.
 func (r *Release) ReleaseType() string {
+	if r.IsCompiled() {
+		return "Compiled"
+	}
+
 	if r.FinalRelease {
 		return "Final"
 	}
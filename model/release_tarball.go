@@ -0,0 +1,203 @@
+package model
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/SUSE/fissile/util"
+)
+
+const (
+	// releaseTarballsDir is the directory underneath the cache directory
+	// where release tarballs are extracted to
+	releaseTarballsDir = "release-tarballs"
+	licenseArchiveFile = "license.tgz"
+)
+
+// NewReleaseFromTarball will create an instance of a BOSH final release from
+// a release tarball (as downloaded from bosh.io, or created by `bosh
+// create-release --tarball`). The release manifest and the job and package
+// archives are streamed out of the tarball into the cache directory, keyed by
+// the SHA1 of the tarball, so loading the same tarball again is cheap.
+func NewReleaseFromTarball(tarballPath, cacheDir string) (*Release, error) {
+	tarballSHA1, err := fileSHA1(tarballPath)
+	if err != nil {
+		return nil, fmt.Errorf("Error calculating SHA1 of release tarball %s: %s", tarballPath, err.Error())
+	}
+
+	releasePath := filepath.Join(cacheDir, releaseTarballsDir, tarballSHA1)
+	if err := util.ValidatePath(releasePath, true, "extracted release tarball"); err != nil {
+		if err := extractReleaseTarball(tarballPath, releasePath); err != nil {
+			return nil, err
+		}
+	}
+
+	release, err := NewFinalRelease(releasePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := release.validateArchiveSHA1s(); err != nil {
+		// Don't leave a corrupt extraction behind to be picked up next time
+		if cleanupErr := os.RemoveAll(releasePath); cleanupErr != nil {
+			return nil, fmt.Errorf("%s; error removing extracted release tarball: %s", err.Error(), cleanupErr.Error())
+		}
+		return nil, err
+	}
+
+	return release, nil
+}
+
+// IsReleaseTarball returns true if the given path points to a file that looks
+// like a BOSH release tarball
+func IsReleaseTarball(releasePath string) bool {
+	if err := util.ValidatePath(releasePath, false, "release tarball"); err != nil {
+		return false
+	}
+
+	return strings.HasSuffix(releasePath, ".tgz") || strings.HasSuffix(releasePath, ".tar.gz")
+}
+
+// validateArchiveSHA1s checks the SHA1 of every job and package archive
+// against the release manifest
+func (r *Release) validateArchiveSHA1s() error {
+	for _, job := range r.Jobs {
+		if err := job.ValidateSHA1(); err != nil {
+			return fmt.Errorf("Error validating release %s: %s", r.Name, err.Error())
+		}
+	}
+
+	for _, pkg := range r.Packages {
+		if err := pkg.ValidateSHA1(); err != nil {
+			return fmt.Errorf("Error validating release %s: %s", r.Name, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// extractReleaseTarball streams the contents of a release tarball into the
+// target directory. The files are first written into a temporary directory
+// next to the target, which is then renamed into place; this way an
+// interrupted extraction never looks like a complete one.
+func extractReleaseTarball(tarballPath, targetDir string) (err error) {
+	if err := os.MkdirAll(filepath.Dir(targetDir), 0755); err != nil {
+		return err
+	}
+
+	tempDir, err := ioutil.TempDir(filepath.Dir(targetDir), "extract-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cleanupErr := os.RemoveAll(tempDir); cleanupErr != nil && err == nil {
+			err = fmt.Errorf("Error cleaning up after extracting release tarball %s: %s", tarballPath, cleanupErr.Error())
+		}
+	}()
+
+	tarball, err := os.Open(tarballPath)
+	if err != nil {
+		return err
+	}
+	defer tarball.Close()
+
+	err = util.TargzIterate(tarballPath, tarball, func(reader *tar.Reader, header *tar.Header) error {
+		name := path.Clean(header.Name)
+		if name == "." {
+			return nil
+		}
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("Release tarball %s contains invalid path %s", tarballPath, header.Name)
+		}
+
+		target := filepath.Join(tempDir, filepath.FromSlash(name))
+		switch {
+		case header.FileInfo().IsDir():
+			return os.MkdirAll(target, 0755)
+		case header.FileInfo().Mode().IsRegular():
+			return writeTarEntry(reader, target)
+		}
+
+		// Release tarballs have no use for links and devices; ignore them
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := extractLicenseArchive(tempDir); err != nil {
+		return err
+	}
+
+	return os.Rename(tempDir, targetDir)
+}
+
+// extractLicenseArchive unpacks the LICENSE file from the license archive of
+// a release tarball, so that it is found by Release.loadLicense
+func extractLicenseArchive(releaseDir string) error {
+	if _, err := os.Stat(filepath.Join(releaseDir, "LICENSE")); err == nil {
+		return nil
+	}
+
+	licenseArchive, err := ioutil.ReadFile(filepath.Join(releaseDir, licenseArchiveFile))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	files, err := util.LoadLicenseFiles(licenseArchiveFile, bytes.NewReader(licenseArchive), "LICENSE")
+	if err != nil {
+		return err
+	}
+
+	for name, contents := range files {
+		if path.Dir(path.Clean(name)) != "." {
+			continue
+		}
+		return ioutil.WriteFile(filepath.Join(releaseDir, "LICENSE"), contents, 0644)
+	}
+
+	return nil
+}
+
+func writeTarEntry(reader io.Reader, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(file, reader); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+func fileSHA1(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha1.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
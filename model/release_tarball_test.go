@@ -0,0 +1,137 @@
+package model
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SUSE/fissile/util"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createReleaseTarball packs the release directory at releasePath into a
+// release tarball in tempDir, replacing the contents of the files listed in
+// overrides. It returns the path to the tarball.
+func createReleaseTarball(t *testing.T, releasePath, tempDir string, overrides map[string][]byte) string {
+	tarballPath := filepath.Join(tempDir, "release.tgz")
+	tarball, err := os.Create(tarballPath)
+	require.NoError(t, err)
+	defer tarball.Close()
+
+	gzipWriter := gzip.NewWriter(tarball)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	err = filepath.Walk(releasePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(releasePath, path)
+		if err != nil {
+			return err
+		}
+		name := "./" + filepath.ToSlash(relPath)
+		if info.IsDir() {
+			return tarWriter.WriteHeader(&tar.Header{Name: name + "/", Mode: 0755, Typeflag: tar.TypeDir})
+		}
+		if contents, ok := overrides[relPath]; ok {
+			return util.WriteToTarStream(tarWriter, contents, tar.Header{Name: name})
+		}
+		return util.CopyFileToTarStream(tarWriter, path, &tar.Header{Name: name})
+	})
+	require.NoError(t, err)
+	require.NoError(t, tarWriter.Close())
+	require.NoError(t, gzipWriter.Close())
+
+	return tarballPath
+}
+
+func TestReleaseTarballOk(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	tempDir, err := ioutil.TempDir("", "fissile-tests")
+	assert.NoError(err)
+	defer os.RemoveAll(tempDir)
+
+	releasePath := filepath.Join(workDir, "../test-assets/test-final-release")
+	tarballPath := createReleaseTarball(t, releasePath, tempDir, nil)
+	cacheDir := filepath.Join(tempDir, "cache")
+
+	assert.True(IsReleaseTarball(tarballPath))
+	assert.False(IsReleaseTarball(releasePath))
+
+	release, err := NewReleaseFromTarball(tarballPath, cacheDir)
+	if !assert.NoError(err) {
+		return
+	}
+
+	assert.Equal("test-final", release.Name)
+	assert.Equal("1", release.Version)
+	assert.True(release.FinalRelease)
+	assert.Len(release.Packages, 2)
+	assert.Len(release.Jobs, 2)
+	assert.Contains(release.License.Files, "LICENSE")
+
+	tarballSHA1, err := fileSHA1(tarballPath)
+	assert.NoError(err)
+	assert.Equal(filepath.Join(cacheDir, releaseTarballsDir, tarballSHA1), release.Path)
+
+	barPkg, err := release.LookupPackage("bar")
+	if assert.NoError(err) {
+		assert.NoError(barPkg.ValidateSHA1())
+		assert.Equal("foo", barPkg.Dependencies[0].Name)
+	}
+
+	// Loading the tarball again reuses the extracted release
+	release, err = NewReleaseFromTarball(tarballPath, cacheDir)
+	if assert.NoError(err) {
+		assert.Equal("test-final", release.Name)
+	}
+}
+
+func TestReleaseTarballBadSHA1(t *testing.T) {
+	assert := assert.New(t)
+
+	workDir, err := os.Getwd()
+	assert.NoError(err)
+
+	tempDir, err := ioutil.TempDir("", "fissile-tests")
+	assert.NoError(err)
+	defer os.RemoveAll(tempDir)
+
+	releasePath := filepath.Join(workDir, "../test-assets/test-final-release")
+	// Replace the package archive with another (valid) archive
+	corruptPackage, err := ioutil.ReadFile(filepath.Join(releasePath, "packages", "foo.tgz"))
+	assert.NoError(err)
+	tarballPath := createReleaseTarball(t, releasePath, tempDir, map[string][]byte{
+		filepath.Join("packages", "bar.tgz"): corruptPackage,
+	})
+	cacheDir := filepath.Join(tempDir, "cache")
+
+	_, err = NewReleaseFromTarball(tarballPath, cacheDir)
+	if assert.Error(err) {
+		assert.Contains(err.Error(), "is different than manifest SHA1")
+	}
+
+	extracted, err := filepath.Glob(filepath.Join(cacheDir, releaseTarballsDir, "*"))
+	assert.NoError(err)
+	assert.Empty(extracted, "Corrupt release tarball should not be kept in the cache")
+}
+
+func TestReleaseTarballMissing(t *testing.T) {
+	assert := assert.New(t)
+
+	tempDir, err := ioutil.TempDir("", "fissile-tests")
+	assert.NoError(err)
+	defer os.RemoveAll(tempDir)
+
+	_, err = NewReleaseFromTarball(filepath.Join(tempDir, "missing.tgz"), tempDir)
+	assert.Error(err)
+}
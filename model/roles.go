@@ -42,6 +42,25 @@ const (
 	FlightStageManual     = FlightStage("manual")      // A role that only runs via user intervention
 )
 
+// RoleController is the kubernetes controller managing the pods of a role
+type RoleController string
+
+// These are the controllers available
+const (
+	RoleControllerDeployment  = RoleController("deployment")  // A stateless role, with interchangeable pods
+	RoleControllerStatefulSet = RoleController("statefulset") // A role with storage, or pods that must be reachable individually
+)
+
+// ColocationMode describes how a colocated container runs in the pod of its
+// main role
+type ColocationMode string
+
+// These are the colocation modes available
+const (
+	ColocationModeSidecar = ColocationMode("sidecar") // A container running alongside the main role
+	ColocationModeInit    = ColocationMode("init")    // A container running to completion before the main role starts
+)
+
 // VolumeType is the type of volume to create
 type VolumeType string
 
@@ -56,10 +75,12 @@ const (
 
 // RoleManifest represents a collection of roles
 type RoleManifest struct {
+	Includes      []string       `yaml:"includes,omitempty"`
 	Roles         Roles          `yaml:"roles"`
 	Configuration *Configuration `yaml:"configuration"`
 
 	manifestFilePath string
+	files            yamlFiles // The files the role manifest is merged from, to locate validation errors
 }
 
 // RoleJob represents a job in the context of a role
@@ -96,28 +117,52 @@ type Role struct {
 	Tags                []RoleTag      `yaml:"tags"`
 	ColocatedContainers []string       `yaml:"colocated_containers,omitempty"`
 
-	roleManifest *RoleManifest
+	roleManifest     *RoleManifest
+	manifestFilePath string // The file declaring the role, if included by the role manifest
 }
 
 // RoleRun describes how a role should behave at runtime
 type RoleRun struct {
-	Scaling            *RoleRunScaling       `yaml:"scaling"`
-	Capabilities       []string              `yaml:"capabilities"`
-	PersistentVolumes  []*RoleRunVolume      `yaml:"persistent-volumes"` // Backwards compat only
-	SharedVolumes      []*RoleRunVolume      `yaml:"shared-volumes"`     // Backwards compat only
-	Volumes            []*RoleRunVolume      `yaml:"volumes"`
-	MemRequest         *int64                `yaml:"memory"`
-	Memory             *RoleRunMemory        `yaml:"mem"`
-	VirtualCPUs        *float64              `yaml:"virtual-cpus"`
-	CPU                *RoleRunCPU           `yaml:"cpu"`
-	ExposedPorts       []*RoleRunExposedPort `yaml:"exposed-ports"`
-	FlightStage        FlightStage           `yaml:"flight-stage"`
-	HealthCheck        *HealthCheck          `yaml:"healthcheck,omitempty"`
-	ActivePassiveProbe string                `yaml:"active-passive-probe,omitempty"`
-	ServiceAccount     string                `yaml:"service-account,omitempty"`
-	Affinity           *RoleRunAffinity      `yaml:"affinity,omitempty"`
-	Environment        []string              `yaml:"env"`
-	ObjectAnnotations  *map[string]string    `yaml:"object-annotations,omitempty"`
+	Scaling            *RoleRunScaling          `yaml:"scaling"`
+	Autoscaling        *RoleRunAutoscaling      `yaml:"autoscaling,omitempty"`
+	Capabilities       []string                 `yaml:"capabilities"`
+	SecurityContext    *RoleRunSecurityContext  `yaml:"security-context,omitempty"`
+	PersistentVolumes  []*RoleRunVolume         `yaml:"persistent-volumes"` // Backwards compat only
+	SharedVolumes      []*RoleRunVolume         `yaml:"shared-volumes"`     // Backwards compat only
+	Volumes            []*RoleRunVolume         `yaml:"volumes"`
+	MemRequest         *int64                   `yaml:"memory"`
+	Memory             *RoleRunMemory           `yaml:"mem"`
+	VirtualCPUs        *float64                 `yaml:"virtual-cpus"`
+	CPU                *RoleRunCPU              `yaml:"cpu"`
+	ExposedPorts       []*RoleRunExposedPort    `yaml:"exposed-ports"`
+	FlightStage        FlightStage              `yaml:"flight-stage"`
+	Controller         RoleController           `yaml:"controller,omitempty"`
+	Colocation         ColocationMode           `yaml:"colocation,omitempty"`
+	HealthCheck        *HealthCheck             `yaml:"healthcheck,omitempty"`
+	ActivePassiveProbe string                   `yaml:"active-passive-probe,omitempty"`
+	ServiceAccount     string                   `yaml:"service-account,omitempty"`
+	Affinity           *RoleRunAffinity         `yaml:"affinity,omitempty"`
+	Tolerations        []*RoleRunToleration     `yaml:"tolerations,omitempty"`
+	NodeSelector       map[string]string        `yaml:"node-selector,omitempty"`
+	PriorityClassName  string                   `yaml:"priority-class-name,omitempty"`
+	TopologySpread     []*RoleRunTopologySpread `yaml:"topology-spread-constraints,omitempty"`
+	Environment        []string                 `yaml:"env"`
+	ObjectAnnotations  *map[string]string       `yaml:"object-annotations,omitempty"`
+}
+
+// RoleRunSecurityContext describes the privileges and access control settings
+// of the containers of a role.  Profiles are the seccomp profile
+// (RuntimeDefault, Unconfined or localhost/<path>) and the AppArmor profile
+// (runtime/default, unconfined or localhost/<name>).
+type RoleRunSecurityContext struct {
+	RunAsUser                *int64 `yaml:"run-as-user,omitempty"`
+	RunAsGroup               *int64 `yaml:"run-as-group,omitempty"`
+	RunAsNonRoot             *bool  `yaml:"run-as-non-root,omitempty"`
+	FSGroup                  *int64 `yaml:"fs-group,omitempty"`
+	ReadOnlyRootFilesystem   *bool  `yaml:"read-only-root-filesystem,omitempty"`
+	AllowPrivilegeEscalation *bool  `yaml:"allow-privilege-escalation,omitempty"`
+	SeccompProfile           string `yaml:"seccomp-profile,omitempty"`
+	AppArmorProfile          string `yaml:"apparmor-profile,omitempty"`
 }
 
 // RoleRunAffinity describes how a role should behave with regard to node / pod selection
@@ -127,6 +172,23 @@ type RoleRunAffinity struct {
 	NodeAffinity    interface{} `yaml:"nodeAffinity,omitempty"`
 }
 
+// RoleRunToleration describes a node taint the pods of a role tolerate
+type RoleRunToleration struct {
+	Key               string `yaml:"key,omitempty"`
+	Operator          string `yaml:"operator,omitempty"`
+	Value             string `yaml:"value,omitempty"`
+	Effect            string `yaml:"effect,omitempty"`
+	TolerationSeconds *int64 `yaml:"tolerationSeconds,omitempty"`
+}
+
+// RoleRunTopologySpread describes how the pods of a role spread across a
+// topology domain, such as zones or nodes
+type RoleRunTopologySpread struct {
+	MaxSkew           int    `yaml:"maxSkew"`
+	TopologyKey       string `yaml:"topologyKey"`
+	WhenUnsatisfiable string `yaml:"whenUnsatisfiable,omitempty"`
+}
+
 // RoleRunMemory describes how a role should behave with regard to memory usage.
 type RoleRunMemory struct {
 	Request *int64 `yaml:"request"`
@@ -147,6 +209,14 @@ type RoleRunScaling struct {
 	MustBeOdd bool `yaml:"must_be_odd,omitempty"`
 }
 
+// RoleRunAutoscaling describes how a role should scale between its minimum
+// and maximum instance counts automatically, by resource utilization in
+// percent of the requested resources
+type RoleRunAutoscaling struct {
+	TargetCPUUtilization    *int `yaml:"target-cpu-utilization,omitempty"`
+	TargetMemoryUtilization *int `yaml:"target-memory-utilization,omitempty"`
+}
+
 // RoleRunVolume describes a volume to be attached at runtime
 type RoleRunVolume struct {
 	Type        VolumeType        `yaml:"type"`
@@ -167,8 +237,20 @@ type RoleRunExposedPort struct {
 	Max                 int    `yaml:"max"`
 	PortIsConfigurable  bool   `yaml:"port-configurable"`
 	CountIsConfigurable bool   `yaml:"count-configurable"`
-	InternalPort        int
-	ExternalPort        int
+	// The first port numbers are computed from the internal and external ports
+	InternalPort int `yaml:"-"`
+	ExternalPort int `yaml:"-"`
+	// Ingress routes HTTP(S) traffic for a host and path to the port
+	Ingress *RoleRunExposedPortIngress `yaml:"ingress,omitempty"`
+}
+
+// RoleRunExposedPortIngress describes the ingress to an exposed port.  The
+// host is completed by the ingress domain of helm charts.
+type RoleRunExposedPortIngress struct {
+	Host        string            `yaml:"host"`
+	Path        string            `yaml:"path"`
+	TLS         bool              `yaml:"tls"`
+	Annotations map[string]string `yaml:"annotations"`
 }
 
 // HealthCheck describes a non-standard health check endpoint
@@ -345,30 +427,66 @@ func (roles Roles) Swap(i, j int) {
 	roles[i], roles[j] = roles[j], roles[i]
 }
 
-// LoadRoleManifest loads a yaml manifest that details how jobs get grouped into roles
-func LoadRoleManifest(manifestFilePath string, releases []*Release, grapher util.ModelGrapher) (*RoleManifest, error) {
-	manifestContents, err := ioutil.ReadFile(manifestFilePath)
+// LoadRoleManifest loads a yaml manifest that details how jobs get grouped into roles.
+// The overlays, if any, are applied in order before resolving it.
+func LoadRoleManifest(manifestFilePath string, releases []*Release, grapher util.ModelGrapher, overlayPaths ...string) (*RoleManifest, error) {
+	roleManifest, err := LoadMergedRoleManifest(manifestFilePath, overlayPaths...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = roleManifest.resolveRoleManifest(releases, grapher)
 	if err != nil {
 		return nil, err
 	}
+	return roleManifest, nil
+}
+
+// LoadMergedRoleManifest loads a role manifest, merging the files it includes
+// and applying the overlays to it, without resolving it
+func LoadMergedRoleManifest(manifestFilePath string, overlayPaths ...string) (*RoleManifest, error) {
+	roleManifest, allErrs, err := loadRoleManifestFile(manifestFilePath, "")
+	if err != nil {
+		return nil, err
+	}
+	if len(allErrs) != 0 {
+		return nil, fmt.Errorf("%s", allErrs.Errors())
+	}
+	if allErrs := roleManifest.loadIncludes(); len(allErrs) != 0 {
+		roleManifest.files.locateErrors(allErrs)
+		return nil, fmt.Errorf("%s", allErrs.Errors())
+	}
+	for _, overlayPath := range overlayPaths {
+		if err := roleManifest.applyOverlay(overlayPath); err != nil {
+			return nil, err
+		}
+	}
+	return roleManifest, nil
+}
+
+// loadRoleManifestFile loads a role manifest file, or a file it includes,
+// without resolving it.  The keys role manifests do not have are reported,
+// named after the field.
+func loadRoleManifestFile(manifestFilePath, field string) (*RoleManifest, validation.ErrorList, error) {
+	manifestContents, err := ioutil.ReadFile(manifestFilePath)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	roleManifest := RoleManifest{}
 	roleManifest.manifestFilePath = manifestFilePath
 	if err := yaml.Unmarshal(manifestContents, &roleManifest); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	file := newYAMLFile(manifestFilePath, manifestContents)
+	roleManifest.files = yamlFiles{file}
 	if roleManifest.Configuration == nil {
 		roleManifest.Configuration = &Configuration{}
 	}
 	if roleManifest.Configuration.Templates == nil {
 		roleManifest.Configuration.Templates = map[string]string{}
 	}
-
-	err = roleManifest.resolveRoleManifest(releases, grapher)
-	if err != nil {
-		return nil, err
-	}
-	return &roleManifest, nil
+	return &roleManifest, validateRoleManifestKeys(file, field), nil
 }
 
 // resolveRoleManifest takes a role manifest as loaded from disk, and validates
@@ -502,12 +620,26 @@ func (m *RoleManifest) resolveRoleManifest(releases []*Release, grapher util.Mod
 	}
 
 	if len(allErrs) != 0 {
+		m.files.locateErrors(allErrs)
 		return fmt.Errorf(allErrs.Errors())
 	}
 
 	return nil
 }
 
+// Position returns the position of the node named by a validation field, such
+// as "roles[api].run.memory", in the files the role manifest is merged from.
+// When the node is not found, the position of its deepest parent is returned.
+func (m *RoleManifest) Position(field string) validation.Position {
+	return m.files.position(field)
+}
+
+// LocateErrors sets the positions of the validation errors that have none to
+// the positions of their fields in the role manifest
+func (m *RoleManifest) LocateErrors(allErrs validation.ErrorList) {
+	m.files.locateErrors(allErrs)
+}
+
 // LookupRole will find the given role in the role manifest
 func (m *RoleManifest) LookupRole(roleName string) *Role {
 	for _, role := range m.Roles {
@@ -642,6 +774,24 @@ func (m *RoleManifest) resolveLinks() validation.ErrorList {
 	return errors
 }
 
+// LinkConsumers returns the roles with jobs consuming BOSH links provided by
+// the given role, in manifest order
+func (m *RoleManifest) LinkConsumers(provider *Role) Roles {
+	var consumers Roles
+roles:
+	for _, role := range m.Roles {
+		for _, roleJob := range role.RoleJobs {
+			for _, consumer := range roleJob.ResolvedConsumers {
+				if consumer.RoleName == provider.Name {
+					consumers = append(consumers, role)
+					continue roles
+				}
+			}
+		}
+	}
+	return consumers
+}
+
 // SelectRoles will find only the given roles in the role manifest
 func (m *RoleManifest) SelectRoles(roleNames []string) (Roles, error) {
 	if len(roleNames) == 0 {
@@ -666,6 +816,134 @@ func (m *RoleManifest) SelectRoles(roleNames []string) (Roles, error) {
 	return results, nil
 }
 
+// Marshal implements the util.Marshaler interface
+func (m *RoleManifest) Marshal() (interface{}, error) {
+	roles := make([]interface{}, 0, len(m.Roles))
+	for _, role := range m.Roles {
+		roles = append(roles, util.NewMarshalAdapter(role))
+	}
+
+	configuration, err := marshalNode(m.Configuration)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"roles":         roles,
+		"configuration": configuration,
+	}, nil
+}
+
+// Marshal implements the util.Marshaler interface
+func (r *Role) Marshal() (interface{}, error) {
+	jobs := make([]interface{}, 0, len(r.RoleJobs))
+	for _, roleJob := range r.RoleJobs {
+		jobs = append(jobs, util.NewMarshalAdapter(roleJob))
+	}
+
+	tags := make([]string, 0, len(r.Tags))
+	for _, tag := range r.Tags {
+		tags = append(tags, string(tag))
+	}
+
+	run, err := marshalNode(r.Run)
+	if err != nil {
+		return nil, err
+	}
+	configuration, err := marshalNode(r.Configuration)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"name":                 r.Name,
+		"description":          r.Description,
+		"type":                 r.Type,
+		"environment_scripts":  append([]string{}, r.EnvironScripts...),
+		"scripts":              append([]string{}, r.Scripts...),
+		"post_config_scripts":  append([]string{}, r.PostConfigScripts...),
+		"jobs":                 jobs,
+		"configuration":        configuration,
+		"run":                  run,
+		"tags":                 tags,
+		"colocated_containers": append([]string{}, r.ColocatedContainers...),
+	}, nil
+}
+
+// Marshal implements the util.Marshaler interface.  Resolved jobs include
+// their spec, and consumers the role and job providing them.
+func (j *RoleJob) Marshal() (interface{}, error) {
+	provides := make(map[string]interface{})
+	for name, info := range j.ExportedProviders {
+		provides[name] = map[string]interface{}{
+			"as":     info.Alias,
+			"shared": info.Shared,
+		}
+	}
+
+	consumes := make(map[string]interface{})
+	for name, info := range j.ResolvedConsumers {
+		consumes[name] = map[string]interface{}{
+			"name": info.Name,
+			"type": info.Type,
+			"role": info.RoleName,
+			"job":  info.JobName,
+		}
+	}
+
+	result := map[string]interface{}{
+		"name":         j.Name,
+		"release_name": j.ReleaseName,
+		"provides":     provides,
+		"consumes":     consumes,
+	}
+	if j.Job != nil {
+		pkgs := make([]string, 0, len(j.Packages))
+		for _, pkg := range j.Packages {
+			pkgs = append(pkgs, pkg.Name)
+		}
+		result["description"] = j.Description
+		result["fingerprint"] = j.Fingerprint
+		result["version"] = j.Version
+		result["packages"] = pkgs
+		result["properties"] = append([]*JobProperty{}, j.Properties...)
+	}
+
+	return result, nil
+}
+
+// marshalNode converts a structure of the role manifest to the mappings and
+// lists of its YAML representation, with string keys to be marshalled as
+// JSON as well
+func marshalNode(value interface{}) (interface{}, error) {
+	contents, err := yaml.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var node interface{}
+	if err := yaml.Unmarshal(contents, &node); err != nil {
+		return nil, err
+	}
+	return stringKeys(node), nil
+}
+
+// stringKeys converts the keys of the mappings of a YAML node to strings
+func stringKeys(node interface{}) interface{} {
+	switch node := node.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(node))
+		for key, value := range node {
+			result[fmt.Sprintf("%v", key)] = stringKeys(value)
+		}
+		return result
+	case []interface{}:
+		for i, value := range node {
+			node[i] = stringKeys(value)
+		}
+	}
+	return node
+}
+
 // GetLongDescription returns the description of the role plus a list of all included jobs
 func (r *Role) GetLongDescription() string {
 	desc := r.Description
@@ -699,7 +977,11 @@ func (r *Role) GetScriptPaths() map[string]string {
 				// Absolute paths _inside_ the container; there is nothing to copy
 				continue
 			}
-			result[script] = filepath.Join(filepath.Dir(r.roleManifest.manifestFilePath), script)
+			manifestFilePath := r.manifestFilePath
+			if manifestFilePath == "" {
+				manifestFilePath = r.roleManifest.manifestFilePath
+			}
+			result[script] = filepath.Join(filepath.Dir(manifestFilePath), script)
 		}
 	}
 
@@ -787,6 +1069,12 @@ func (r *Role) GetRoleDevVersion(opinions *Opinions, tagExtra, fissileVersion st
 		[]string{"extra/", tagExtra},
 	}
 
+	// Init containers run their jobs without monit, so their images differ
+	if r.IsInitContainerRole() {
+		signatures = append(signatures, "colocation", string(ColocationModeInit))
+		extraGraphEdges = append(extraGraphEdges, []string{"colocation/", string(ColocationModeInit)})
+	}
+
 	// Job order comes from the role manifest, and is sort of
 	// fix. Avoid sorting for now.  Also note, if a property is
 	// used multiple times, in different jobs, it will be added
@@ -915,6 +1203,20 @@ func (r *Role) HasTag(tag RoleTag) bool {
 	return false
 }
 
+// HasStorage returns true if the role uses shared or persistent volumes
+func (r *Role) HasStorage() bool {
+	if r.Run == nil {
+		return false
+	}
+	for _, volume := range r.Run.Volumes {
+		switch volume.Type {
+		case VolumeTypePersistent, VolumeTypeShared:
+			return true
+		}
+	}
+	return false
+}
+
 func (r *Role) calculateRoleConfigurationTemplates() {
 	if r.Configuration == nil {
 		r.Configuration = &Configuration{}
@@ -1269,6 +1571,11 @@ func validateRoleRun(role *Role, roleManifest *RoleManifest, declared CVMap) val
 		}
 	}
 
+	allErrs = append(allErrs, normalizeController(role)...)
+	allErrs = append(allErrs, normalizeColocation(role)...)
+	allErrs = append(allErrs, validateRoleAutoscaling(role)...)
+	allErrs = append(allErrs, validateRoleIngress(role)...)
+
 	// Normalize capabilities to upper case, if any.
 	var capabilities []string
 	for _, cap := range role.Run.Capabilities {
@@ -1276,6 +1583,9 @@ func validateRoleRun(role *Role, roleManifest *RoleManifest, declared CVMap) val
 	}
 	role.Run.Capabilities = capabilities
 
+	allErrs = append(allErrs, validateRoleSecurityContext(role)...)
+	allErrs = append(allErrs, validateRoleScheduling(role)...)
+
 	if len(role.Run.Environment) == 0 {
 		return allErrs
 	}
@@ -1515,6 +1825,14 @@ func validateHealthProbe(role *Role, probeName string, probe *HealthProbe) valid
 				"bosh-task roles cannot have health checks"))
 		}
 
+	case RoleTypeColocatedContainer:
+		// The colocation mode is only normalized later on
+		if role.Run.Colocation == ColocationModeInit && len(checks) > 0 {
+			allErrs = append(allErrs, validation.Forbidden(
+				fmt.Sprintf("roles[%s].run.healthcheck.%s", role.Name, probeName),
+				"init containers cannot have health checks"))
+		}
+
 	case RoleTypeDocker:
 		if len(probe.Command) > 1 {
 			allErrs = append(allErrs, validation.Forbidden(
@@ -1554,6 +1872,352 @@ func normalizeFlightStage(role *Role) validation.ErrorList {
 	return allErrs
 }
 
+// normalizeController reports roles with a bad controller, and picks
+// the controller of bosh roles without one: a deployment for single
+// instance roles without storage, not tagged headless or
+// sequential-startup, and a statefulset otherwise.  Instances of
+// statefulsets have stable names and indices, which BOSH jobs rely on
+// to bootstrap clusters.  Roles that may have several instances keep
+// statefulsets even without storage or those tags: the tags are not
+// required of clustered jobs, which would break silently if their
+// controller changed under them, so they opt into deployments
+// explicitly.  Needs the volumes of the role to be normalized.
+func normalizeController(role *Role) validation.ErrorList {
+	allErrs := validation.ErrorList{}
+	path := fmt.Sprintf("roles[%s].run.controller", role.Name)
+
+	if role.Type != RoleTypeBosh {
+		if role.Run.Controller != "" {
+			allErrs = append(allErrs, validation.Forbidden(path,
+				fmt.Sprintf("Only bosh roles can choose a controller, not %s roles", role.Type)))
+		}
+		return allErrs
+	}
+
+	needsStatefulSet := role.HasStorage() ||
+		role.HasTag(RoleTagHeadless) ||
+		role.HasTag(RoleTagSequentialStartup)
+
+	switch role.Run.Controller {
+	case "":
+		role.Run.Controller = RoleControllerStatefulSet
+		if !needsStatefulSet && role.Run.Scaling != nil && role.Run.Scaling.Max == 1 {
+			role.Run.Controller = RoleControllerDeployment
+		}
+	case RoleControllerStatefulSet:
+	case RoleControllerDeployment:
+		if needsStatefulSet {
+			allErrs = append(allErrs, validation.Invalid(path,
+				role.Run.Controller,
+				"Roles with persistent or shared volumes, or tagged headless or sequential-startup, need a statefulset"))
+		}
+	default:
+		allErrs = append(allErrs, validation.Invalid(path,
+			role.Run.Controller,
+			"Expected one of deployment or statefulset"))
+	}
+
+	return allErrs
+}
+
+// normalizeColocation reports roles with a bad colocation mode, and defaults
+// colocated containers to sidecars.  Init containers run to completion before
+// the other containers of the pod start, so they cannot expose ports.
+func normalizeColocation(role *Role) validation.ErrorList {
+	allErrs := validation.ErrorList{}
+	path := fmt.Sprintf("roles[%s].run.colocation", role.Name)
+
+	if !role.IsColocatedContainerRole() {
+		if role.Run.Colocation != "" {
+			allErrs = append(allErrs, validation.Forbidden(path,
+				fmt.Sprintf("Only colocated-container roles can choose a colocation, not %s roles", role.Type)))
+		}
+		return allErrs
+	}
+
+	switch role.Run.Colocation {
+	case "":
+		role.Run.Colocation = ColocationModeSidecar
+	case ColocationModeSidecar:
+	case ColocationModeInit:
+		if len(role.Run.ExposedPorts) > 0 {
+			allErrs = append(allErrs, validation.Forbidden(
+				fmt.Sprintf("roles[%s].run.exposed-ports", role.Name),
+				"Init containers cannot expose ports"))
+		}
+	default:
+		allErrs = append(allErrs, validation.Invalid(path,
+			role.Run.Colocation,
+			"Expected one of init or sidecar"))
+	}
+
+	return allErrs
+}
+
+// validateRoleAutoscaling reports autoscaling of roles which cannot
+// scale automatically: roles not managed by a controller, roles that
+// must have an odd instance count, and active-passive roles
+func validateRoleAutoscaling(role *Role) validation.ErrorList {
+	allErrs := validation.ErrorList{}
+
+	autoscaling := role.Run.Autoscaling
+	if autoscaling == nil {
+		return allErrs
+	}
+	path := fmt.Sprintf("roles[%s].run.autoscaling", role.Name)
+
+	if role.Type != RoleTypeBosh {
+		allErrs = append(allErrs, validation.Forbidden(path,
+			fmt.Sprintf("Only bosh roles can be autoscaled, not %s roles", role.Type)))
+	}
+	if role.HasTag(RoleTagActivePassive) {
+		allErrs = append(allErrs, validation.Forbidden(path,
+			"active-passive roles cannot be autoscaled"))
+	}
+	if role.Run.Scaling != nil {
+		if role.Run.Scaling.MustBeOdd {
+			allErrs = append(allErrs, validation.Forbidden(path,
+				"Roles that must have an odd instance count cannot be autoscaled"))
+		}
+		if role.Run.Scaling.Max <= role.Run.Scaling.Min || role.Run.Scaling.Max < 2 {
+			allErrs = append(allErrs, validation.Invalid(
+				fmt.Sprintf("roles[%s].run.scaling.max", role.Name),
+				role.Run.Scaling.Max,
+				"Autoscaled roles must be able to scale beyond their minimum instance count"))
+		}
+	}
+
+	if autoscaling.TargetCPUUtilization == nil && autoscaling.TargetMemoryUtilization == nil {
+		allErrs = append(allErrs, validation.Required(path,
+			"Expected a target-cpu-utilization or target-memory-utilization"))
+	}
+	for _, target := range []struct {
+		name  string
+		value *int
+	}{
+		{"target-cpu-utilization", autoscaling.TargetCPUUtilization},
+		{"target-memory-utilization", autoscaling.TargetMemoryUtilization},
+	} {
+		if target.value != nil && *target.value <= 0 {
+			allErrs = append(allErrs, validation.Invalid(
+				fmt.Sprintf("%s.%s", path, target.name),
+				*target.value,
+				"must be greater than 0"))
+		}
+	}
+
+	return allErrs
+}
+
+// validateRoleIngress reports ingresses to ports without a service to route
+// to: ports of roles other than bosh roles, of headless roles, and ports which
+// are not public single TCP ports.  It defaults the path of ingresses to /.
+func validateRoleIngress(role *Role) validation.ErrorList {
+	allErrs := validation.ErrorList{}
+
+	for _, port := range role.Run.ExposedPorts {
+		ingress := port.Ingress
+		if ingress == nil {
+			continue
+		}
+		path := fmt.Sprintf("roles[%s].run.exposed-ports[%s].ingress", role.Name, port.Name)
+
+		if role.Type != RoleTypeBosh {
+			allErrs = append(allErrs, validation.Forbidden(path,
+				fmt.Sprintf("Only ports of bosh roles can have an ingress, not of %s roles", role.Type)))
+		} else if role.HasTag(RoleTagHeadless) {
+			allErrs = append(allErrs, validation.Forbidden(path,
+				"Headless roles have no service for an ingress"))
+		}
+		if !port.Public {
+			allErrs = append(allErrs, validation.Forbidden(path,
+				"Only public ports can have an ingress"))
+		}
+		if port.Protocol != "TCP" {
+			allErrs = append(allErrs, validation.Forbidden(path,
+				fmt.Sprintf("Only TCP ports can have an ingress, not %s ports", port.Protocol)))
+		}
+		if port.Max > 1 || port.CountIsConfigurable {
+			allErrs = append(allErrs, validation.Forbidden(path,
+				"Port ranges cannot have an ingress"))
+		}
+
+		if ingress.Path == "" {
+			ingress.Path = "/"
+		} else if !strings.HasPrefix(ingress.Path, "/") {
+			allErrs = append(allErrs, validation.Invalid(path+".path", ingress.Path,
+				"The path must be absolute"))
+		}
+	}
+
+	return allErrs
+}
+
+// validateRoleSecurityContext validates the security context of a role: user
+// and group ids, the profiles, and settings Kubernetes refuses to combine.
+// The file system group is a setting of the pod, which colocated containers
+// cannot have.
+func validateRoleSecurityContext(role *Role) validation.ErrorList {
+	allErrs := validation.ErrorList{}
+
+	sc := role.Run.SecurityContext
+	if sc == nil {
+		return allErrs
+	}
+	path := fmt.Sprintf("roles[%s].run.security-context", role.Name)
+
+	for _, id := range []struct {
+		name  string
+		value *int64
+	}{
+		{"run-as-user", sc.RunAsUser},
+		{"run-as-group", sc.RunAsGroup},
+		{"fs-group", sc.FSGroup},
+	} {
+		if id.value != nil {
+			allErrs = append(allErrs, validation.ValidateNonnegativeField(*id.value,
+				fmt.Sprintf("%s.%s", path, id.name))...)
+		}
+	}
+
+	if sc.RunAsNonRoot != nil && *sc.RunAsNonRoot && sc.RunAsUser != nil && *sc.RunAsUser == 0 {
+		allErrs = append(allErrs, validation.Invalid(path+".run-as-user", *sc.RunAsUser,
+			"Roles which must run as non-root cannot run as root"))
+	}
+
+	if sc.FSGroup != nil && role.IsColocatedContainerRole() {
+		allErrs = append(allErrs, validation.Forbidden(path+".fs-group",
+			"Colocated containers share the file system group of the pod they are colocated with"))
+	}
+
+	if sc.AllowPrivilegeEscalation != nil && !*sc.AllowPrivilegeEscalation {
+		for _, cap := range role.Run.Capabilities {
+			if cap == "ALL" || cap == "SYS_ADMIN" {
+				allErrs = append(allErrs, validation.Invalid(path+".allow-privilege-escalation", false,
+					fmt.Sprintf("Roles with the %s capability always allow privilege escalation", cap)))
+				break
+			}
+		}
+	}
+
+	if sc.SeccompProfile != "" {
+		switch {
+		case sc.SeccompProfile == "RuntimeDefault":
+		case sc.SeccompProfile == "Unconfined":
+		case strings.HasPrefix(sc.SeccompProfile, "localhost/") && len(sc.SeccompProfile) > len("localhost/"):
+		default:
+			allErrs = append(allErrs, validation.Invalid(path+".seccomp-profile", sc.SeccompProfile,
+				"Expected RuntimeDefault, Unconfined, or localhost/<path>"))
+		}
+	}
+
+	if sc.AppArmorProfile != "" {
+		switch {
+		case sc.AppArmorProfile == "runtime/default":
+		case sc.AppArmorProfile == "unconfined":
+		case strings.HasPrefix(sc.AppArmorProfile, "localhost/") && len(sc.AppArmorProfile) > len("localhost/"):
+		default:
+			allErrs = append(allErrs, validation.Invalid(path+".apparmor-profile", sc.AppArmorProfile,
+				"Expected runtime/default, unconfined, or localhost/<name>"))
+		}
+	}
+
+	return allErrs
+}
+
+// dnsSubdomainRegexp matches names of kubernetes objects such as priority
+// classes
+var dnsSubdomainRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+
+// validateRoleScheduling validates the tolerations, node selector, priority
+// class and topology spread constraints of a role.  These are settings of
+// the pod, which colocated containers cannot have.  It defaults the topology
+// spread constraints to DoNotSchedule when unsatisfiable.
+func validateRoleScheduling(role *Role) validation.ErrorList {
+	allErrs := validation.ErrorList{}
+	path := fmt.Sprintf("roles[%s].run", role.Name)
+
+	if role.IsColocatedContainerRole() {
+		for _, setting := range []struct {
+			name  string
+			isSet bool
+		}{
+			{"tolerations", len(role.Run.Tolerations) > 0},
+			{"node-selector", len(role.Run.NodeSelector) > 0},
+			{"priority-class-name", role.Run.PriorityClassName != ""},
+			{"topology-spread-constraints", len(role.Run.TopologySpread) > 0},
+		} {
+			if setting.isSet {
+				allErrs = append(allErrs, validation.Forbidden(fmt.Sprintf("%s.%s", path, setting.name),
+					"Colocated containers are scheduled with the pod they are colocated with"))
+			}
+		}
+		return allErrs
+	}
+
+	for i, toleration := range role.Run.Tolerations {
+		field := fmt.Sprintf("%s.tolerations[%d]", path, i)
+		switch toleration.Operator {
+		case "", "Equal":
+			if toleration.Key == "" {
+				allErrs = append(allErrs, validation.Required(field+".key",
+					"Tolerations of any key must use the Exists operator"))
+			}
+		case "Exists":
+			if toleration.Value != "" {
+				allErrs = append(allErrs, validation.Invalid(field+".value", toleration.Value,
+					"Tolerations using the Exists operator cannot have a value"))
+			}
+		default:
+			allErrs = append(allErrs, validation.Invalid(field+".operator", toleration.Operator,
+				"Expected Equal or Exists"))
+		}
+		switch toleration.Effect {
+		case "", "NoSchedule", "PreferNoSchedule", "NoExecute":
+		default:
+			allErrs = append(allErrs, validation.Invalid(field+".effect", toleration.Effect,
+				"Expected NoSchedule, PreferNoSchedule or NoExecute"))
+		}
+		if toleration.TolerationSeconds != nil && toleration.Effect != "NoExecute" {
+			allErrs = append(allErrs, validation.Forbidden(field+".tolerationSeconds",
+				"Only tolerations with the NoExecute effect can have a toleration period"))
+		}
+	}
+
+	for key := range role.Run.NodeSelector {
+		if key == "" {
+			allErrs = append(allErrs, validation.Required(path+".node-selector",
+				"Node selector labels must have a name"))
+		}
+	}
+
+	if role.Run.PriorityClassName != "" && !dnsSubdomainRegexp.MatchString(role.Run.PriorityClassName) {
+		allErrs = append(allErrs, validation.Invalid(path+".priority-class-name", role.Run.PriorityClassName,
+			"Expected a lowercase name of words separated by dots or hyphens"))
+	}
+
+	for i, constraint := range role.Run.TopologySpread {
+		field := fmt.Sprintf("%s.topology-spread-constraints[%d]", path, i)
+		if constraint.MaxSkew < 1 {
+			allErrs = append(allErrs, validation.Invalid(field+".maxSkew", constraint.MaxSkew,
+				"must be greater than 0"))
+		}
+		if constraint.TopologyKey == "" {
+			allErrs = append(allErrs, validation.Required(field+".topologyKey", ""))
+		}
+		switch constraint.WhenUnsatisfiable {
+		case "":
+			constraint.WhenUnsatisfiable = "DoNotSchedule"
+		case "DoNotSchedule", "ScheduleAnyway":
+		default:
+			allErrs = append(allErrs, validation.Invalid(field+".whenUnsatisfiable", constraint.WhenUnsatisfiable,
+				"Expected DoNotSchedule or ScheduleAnyway"))
+		}
+	}
+
+	return allErrs
+}
+
 // validateNonTemplates tests whether the global templates are
 // constant or not. It reports the contant templates as errors (They
 // should be opinions).
@@ -1775,6 +2439,12 @@ func validateColocatedContainerVolumeShares(RoleManifest *RoleManifest) validati
 									volume.Path,
 									fmt.Sprintf("colocated role specifies a shared volume with tag %s, which path does not match the path of the main role shared volume with the same tag", volume.Tag)))
 							}
+						} else {
+							// The pod only has the volumes of the main role
+							allErrs = append(allErrs, validation.Invalid(
+								fmt.Sprintf("role[%s]", colocatedRole.Name),
+								volume.Path,
+								fmt.Sprintf("colocated role specifies a shared volume with tag %s, which the main role does not have", volume.Tag)))
 						}
 					}
 				}
@@ -1823,6 +2493,18 @@ func (r *Role) IsColocatedContainerRole() bool {
 	return r.Type == RoleTypeColocatedContainer
 }
 
+// IsInitContainerRole tests if the role is a colocated container running to
+// completion before the main role starts
+func (r *Role) IsInitContainerRole() bool {
+	return r.IsColocatedContainerRole() && r.Run != nil && r.Run.Colocation == ColocationModeInit
+}
+
+// RunsToCompletion tests if the jobs of the role run once instead of being
+// supervised by monit: bosh tasks and init containers
+func (r *Role) RunsToCompletion() bool {
+	return r.Type == RoleTypeBoshTask || r.IsInitContainerRole()
+}
+
 // GetColocatedRoles lists all colocation roles references by this role
 func (r *Role) GetColocatedRoles() []*Role {
 	result := make([]*Role, len(r.ColocatedContainers))
@@ -395,7 +395,7 @@ func TestLoadRoleManifestVariablesNotUsed(t *testing.T) {
 	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/model/variables-without-usage.yml")
 	roleManifest, err := LoadRoleManifest(roleManifestPath, []*Release{release}, nil)
 	assert.EqualError(t, err,
-		`configuration.variables: Not found: "No templates using 'SOME_VAR'"`)
+		roleManifestPath+`:10:3: configuration.variables: Not found: "No templates using 'SOME_VAR'"`)
 	assert.Nil(t, roleManifest)
 }
 
@@ -411,7 +411,7 @@ func TestLoadRoleManifestVariablesNotDeclared(t *testing.T) {
 	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/model/variables-without-decl.yml")
 	roleManifest, err := LoadRoleManifest(roleManifestPath, []*Release{release}, nil)
 	assert.EqualError(t, err,
-		`configuration.variables: Not found: "No declaration of 'HOME'"`)
+		roleManifestPath+`:10:3: configuration.variables: Not found: "No declaration of 'HOME'"`)
 	assert.Nil(t, roleManifest)
 }
 
@@ -427,7 +427,7 @@ func TestLoadRoleManifestNonTemplates(t *testing.T) {
 	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/model/templates-non.yml")
 	roleManifest, err := LoadRoleManifest(roleManifestPath, []*Release{release}, nil)
 	assert.EqualError(t, err,
-		`configuration.templates: Invalid value: "": Using 'properties.tor.hostname' as a constant`)
+		roleManifestPath+`:30:3: configuration.templates: Invalid value: "": Using 'properties.tor.hostname' as a constant`)
 	assert.Nil(t, roleManifest)
 }
 
@@ -443,7 +443,7 @@ func TestLoadRoleManifestBadCVType(t *testing.T) {
 	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/model/bad-cv-type.yml")
 	roleManifest, err := LoadRoleManifest(roleManifestPath, []*Release{release}, nil)
 	assert.EqualError(t, err,
-		`configuration.variables[BAR].type: Invalid value: "bogus": Expected one of user, or environment`)
+		roleManifestPath+`:29:5: configuration.variables[BAR].type: Invalid value: "bogus": Expected one of user, or environment`)
 	assert.Nil(t, roleManifest)
 }
 
@@ -459,7 +459,7 @@ func TestLoadRoleManifestBadCVTypeConflictInternal(t *testing.T) {
 	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/model/bad-cv-type-internal.yml")
 	roleManifest, err := LoadRoleManifest(roleManifestPath, []*Release{release}, nil)
 	assert.EqualError(t, err,
-		`configuration.variables[BAR].type: Invalid value: "environment": type conflicts with flag "internal"`)
+		roleManifestPath+`:12:5: configuration.variables[BAR].type: Invalid value: "environment": type conflicts with flag "internal"`)
 	assert.Nil(t, roleManifest)
 }
 
@@ -474,7 +474,7 @@ func TestLoadRoleManifestRunEnvDocker(t *testing.T) {
 
 	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/model/docker-run-env.yml")
 	roleManifest, err := LoadRoleManifest(roleManifestPath, []*Release{release}, nil)
-	assert.EqualError(t, err, `roles[dockerrole].run.env: Not found: "No variable declaration of 'UNKNOWN'"`)
+	assert.EqualError(t, err, roleManifestPath+`:6:5: roles[dockerrole].run.env: Not found: "No variable declaration of 'UNKNOWN'"`)
 	assert.Nil(t, roleManifest)
 }
 
@@ -489,7 +489,7 @@ func TestLoadRoleManifestMissingRBACAccount(t *testing.T) {
 
 	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/model/rbac-missing-account.yml")
 	roleManifest, err := LoadRoleManifest(roleManifestPath, []*Release{release}, nil)
-	assert.EqualError(t, err, `roles[myrole].run.service-account: Not found: "missing-account"`)
+	assert.EqualError(t, err, roleManifestPath+`:5:5: roles[myrole].run.service-account: Not found: "missing-account"`)
 	assert.Nil(t, roleManifest)
 }
 
@@ -504,7 +504,7 @@ func TestLoadRoleManifestMissingRBACRole(t *testing.T) {
 
 	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/model/rbac-missing-role.yml")
 	roleManifest, err := LoadRoleManifest(roleManifestPath, []*Release{release}, nil)
-	assert.EqualError(t, err, `configuration.auth.accounts[test-account].roles: Not found: "missing-role"`)
+	assert.EqualError(t, err, roleManifestPath+`:6:9: configuration.auth.accounts[test-account].roles: Not found: "missing-role"`)
 	assert.Nil(t, roleManifest)
 }
 
@@ -527,40 +527,40 @@ func TestLoadRoleManifestRunGeneral(t *testing.T) {
 	tests := []testCase{
 		{
 			"bosh-run-missing.yml", []string{
-				`roles[myrole].run: Required value`,
+				`bosh-run-missing.yml:3:1: roles[myrole].run: Required value`,
 			},
 		},
 		{
 			"bosh-run-bad-proto.yml", []string{
-				`roles[myrole].run.exposed-ports[https].protocol: Unsupported value: "AA": supported values: TCP, UDP`,
+				`bosh-run-bad-proto.yml:8:9: roles[myrole].run.exposed-ports[https].protocol: Unsupported value: "AA": supported values: TCP, UDP`,
 			},
 		},
 		{
 			"bosh-run-bad-port-names.yml", []string{
-				`roles[myrole].run.exposed-ports[a--b].name: Invalid value: "a--b": port names must be lowercase words separated by hyphens`,
-				`roles[myrole].run.exposed-ports[abcd-efgh-ijkl-x].name: Invalid value: "abcd-efgh-ijkl-x": port name must be no more than 15 characters`,
-				`roles[myrole].run.exposed-ports[abcdefghij].name: Invalid value: "abcdefghij": user configurable port name must be no more than 9 characters`,
+				`bosh-run-bad-port-names.yml:7:7: roles[myrole].run.exposed-ports[a--b].name: Invalid value: "a--b": port names must be lowercase words separated by hyphens`,
+				`bosh-run-bad-port-names.yml:10:7: roles[myrole].run.exposed-ports[abcd-efgh-ijkl-x].name: Invalid value: "abcd-efgh-ijkl-x": port name must be no more than 15 characters`,
+				`bosh-run-bad-port-names.yml:13:7: roles[myrole].run.exposed-ports[abcdefghij].name: Invalid value: "abcdefghij": user configurable port name must be no more than 9 characters`,
 			},
 		},
 		{
 			"bosh-run-bad-port-count.yml", []string{
-				`roles[myrole].run.exposed-ports[http].count: Invalid value: 2: count doesn't match port range 80-82`,
+				`bosh-run-bad-port-count.yml:10:7: roles[myrole].run.exposed-ports[http].count: Invalid value: 2: count doesn't match port range 80-82`,
 			},
 		},
 		{
 			"bosh-run-bad-ports.yml", []string{
-				`roles[myrole].run.exposed-ports[https].internal: Invalid value: "-1": invalid syntax`,
-				`roles[myrole].run.exposed-ports[https].external: Invalid value: 0: must be between 1 and 65535, inclusive`,
+				`bosh-run-bad-ports.yml:10:7: roles[myrole].run.exposed-ports[https].internal: Invalid value: "-1": invalid syntax`,
+				`bosh-run-bad-ports.yml:9:7: roles[myrole].run.exposed-ports[https].external: Invalid value: 0: must be between 1 and 65535, inclusive`,
 			},
 		},
 		{
 			"bosh-run-missing-portrange.yml", []string{
-				`roles[myrole].run.exposed-ports[https].internal: Invalid value: "": invalid syntax`,
+				`bosh-run-missing-portrange.yml:9:9: roles[myrole].run.exposed-ports[https].internal: Invalid value: "": invalid syntax`,
 			},
 		},
 		{
 			"bosh-run-reverse-portrange.yml", []string{
-				`roles[myrole].run.exposed-ports[https].internal: Invalid value: "5678-123": last port can't be lower than first port`,
+				`bosh-run-reverse-portrange.yml:9:9: roles[myrole].run.exposed-ports[https].internal: Invalid value: "5678-123": last port can't be lower than first port`,
 			},
 		},
 		{
@@ -569,23 +569,79 @@ func TestLoadRoleManifestRunGeneral(t *testing.T) {
 		},
 		{
 			"bosh-run-bad-parse.yml", []string{
-				`roles[myrole].run.exposed-ports[https].internal: Invalid value: "qq": invalid syntax`,
-				`roles[myrole].run.exposed-ports[https].external: Invalid value: "aa": invalid syntax`,
+				`bosh-run-bad-parse.yml:10:9: roles[myrole].run.exposed-ports[https].internal: Invalid value: "qq": invalid syntax`,
+				`bosh-run-bad-parse.yml:9:9: roles[myrole].run.exposed-ports[https].external: Invalid value: "aa": invalid syntax`,
 			},
 		},
 		{
 			"bosh-run-bad-memory.yml", []string{
-				`roles[myrole].run.memory: Invalid value: -10: must be greater than or equal to 0`,
+				`bosh-run-bad-memory.yml:6:5: roles[myrole].run.memory: Invalid value: -10: must be greater than or equal to 0`,
 			},
 		},
 		{
 			"bosh-run-bad-cpu.yml", []string{
-				`roles[myrole].run.virtual-cpus: Invalid value: -2: must be greater than or equal to 0`,
+				`bosh-run-bad-cpu.yml:7:5: roles[myrole].run.virtual-cpus: Invalid value: -2: must be greater than or equal to 0`,
 			},
 		},
 		{
 			"bosh-run-env.yml", []string{
-				`roles[xrole].run.env: Forbidden: Non-docker role declares bogus parameters`,
+				`bosh-run-env.yml:8:5: roles[xrole].run.env: Forbidden: Non-docker role declares bogus parameters`,
+			},
+		},
+		{
+			"bosh-run-bad-controller.yml", []string{
+				`bosh-run-bad-controller.yml:18:5: roles[unknown].run.controller: Invalid value: "daemonset": Expected one of deployment or statefulset`,
+				`bosh-run-bad-controller.yml:6:5: roles[persistent].run.controller: Invalid value: "deployment": Roles with persistent or shared volumes, or tagged headless or sequential-startup, need a statefulset`,
+			},
+		},
+		{
+			"bosh-run-bad-autoscaling.yml", []string{
+				`bosh-run-bad-autoscaling.yml:35:5: roles[active].run.autoscaling: Forbidden: active-passive roles cannot be autoscaled`,
+				`bosh-run-bad-autoscaling.yml:26:5: roles[untargeted].run.autoscaling: Required value: Expected a target-cpu-utilization or target-memory-utilization`,
+				`bosh-run-bad-autoscaling.yml:17:7: roles[fixed].run.scaling.max: Invalid value: 2: Autoscaled roles must be able to scale beyond their minimum instance count`,
+				`bosh-run-bad-autoscaling.yml:19:7: roles[fixed].run.autoscaling.target-memory-utilization: Invalid value: 0: must be greater than 0`,
+				`bosh-run-bad-autoscaling.yml:10:5: roles[quorum].run.autoscaling: Forbidden: Roles that must have an odd instance count cannot be autoscaled`,
+			},
+		},
+		{
+			"bosh-run-bad-ingress.yml", []string{
+				`bosh-run-bad-ingress.yml:30:7: roles[ranges].run.exposed-ports[routes].ingress: Forbidden: Port ranges cannot have an ingress`,
+				`bosh-run-bad-ingress.yml:21:7: roles[headless].run.exposed-ports[dns].ingress: Forbidden: Headless roles have no service for an ingress`,
+				`bosh-run-bad-ingress.yml:21:7: roles[headless].run.exposed-ports[dns].ingress: Forbidden: Only TCP ports can have an ingress, not UDP ports`,
+				`bosh-run-bad-ingress.yml:10:7: roles[private].run.exposed-ports[http].ingress: Forbidden: Only public ports can have an ingress`,
+				`bosh-run-bad-ingress.yml:11:9: roles[private].run.exposed-ports[http].ingress.path: Invalid value: "api": The path must be absolute`,
+			},
+		},
+		{
+			"bosh-run-bad-security-context.yml", []string{
+				`bosh-run-bad-security-context.yml:23:7: roles[sidecar].run.security-context.fs-group: Forbidden: Colocated containers share the file system group of the pod they are colocated with`,
+				`bosh-run-bad-security-context.yml:15:7: roles[admin].run.security-context.allow-privilege-escalation: Invalid value: false: Roles with the SYS_ADMIN capability always allow privilege escalation`,
+				`bosh-run-bad-security-context.yml:16:7: roles[admin].run.security-context.seccomp-profile: Invalid value: "docker/default": Expected RuntimeDefault, Unconfined, or localhost/<path>`,
+				`bosh-run-bad-security-context.yml:17:7: roles[admin].run.security-context.apparmor-profile: Invalid value: "localhost/": Expected runtime/default, unconfined, or localhost/<name>`,
+				`bosh-run-bad-security-context.yml:9:7: roles[root].run.security-context.fs-group: Invalid value: -1: must be greater than or equal to 0`,
+				`bosh-run-bad-security-context.yml:7:7: roles[root].run.security-context.run-as-user: Invalid value: 0: Roles which must run as non-root cannot run as root`,
+			},
+		},
+		{
+			"bosh-run-bad-colocation.yml", []string{
+				`bosh-run-bad-colocation.yml:26:5: roles[later].run.colocation: Invalid value: "afterwards": Expected one of init or sidecar`,
+				`bosh-run-bad-colocation.yml:20:7: roles[migrate].run.healthcheck.readiness: Forbidden: init containers cannot have health checks`,
+				`bosh-run-bad-colocation.yml:15:5: roles[migrate].run.exposed-ports: Forbidden: Init containers cannot expose ports`,
+				`bosh-run-bad-colocation.yml:6:5: roles[main].run.colocation: Forbidden: Only colocated-container roles can choose a colocation, not bosh roles`,
+			},
+		},
+		{
+			"bosh-run-bad-scheduling.yml", []string{
+				`bosh-run-bad-scheduling.yml:25:5: roles[sidecar].run.node-selector: Forbidden: Colocated containers are scheduled with the pod they are colocated with`,
+				`bosh-run-bad-scheduling.yml:27:5: roles[sidecar].run.priority-class-name: Forbidden: Colocated containers are scheduled with the pod they are colocated with`,
+				`bosh-run-bad-scheduling.yml:17:5: roles[spread].run.priority-class-name: Invalid value: "High_Priority": Expected a lowercase name of words separated by dots or hyphens`,
+				`bosh-run-bad-scheduling.yml:19:7: roles[spread].run.topology-spread-constraints[0].maxSkew: Invalid value: 0: must be greater than 0`,
+				`bosh-run-bad-scheduling.yml:19:5: roles[spread].run.topology-spread-constraints[0].topologyKey: Required value`,
+				`bosh-run-bad-scheduling.yml:20:7: roles[spread].run.topology-spread-constraints[0].whenUnsatisfiable: Invalid value: "Never": Expected DoNotSchedule or ScheduleAnyway`,
+				`bosh-run-bad-scheduling.yml:7:5: roles[tolerant].run.tolerations[0].key: Required value: Tolerations of any key must use the Exists operator`,
+				`bosh-run-bad-scheduling.yml:11:7: roles[tolerant].run.tolerations[1].value: Invalid value: "storage": Tolerations using the Exists operator cannot have a value`,
+				`bosh-run-bad-scheduling.yml:12:7: roles[tolerant].run.tolerations[1].effect: Invalid value: "Sometimes": Expected NoSchedule, PreferNoSchedule or NoExecute`,
+				`bosh-run-bad-scheduling.yml:13:7: roles[tolerant].run.tolerations[1].tolerationSeconds: Forbidden: Only tolerations with the NoExecute effect can have a toleration period`,
 			},
 		},
 		{
@@ -600,7 +656,12 @@ func TestLoadRoleManifestRunGeneral(t *testing.T) {
 				roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/model", tc.manifest)
 				roleManifest, err := LoadRoleManifest(roleManifestPath, []*Release{release}, nil)
 				if len(tc.message) > 0 {
-					assert.EqualError(t, err, strings.Join(tc.message, "\n"))
+					// Errors are located relative to the role manifests
+					var messages []string
+					for _, message := range tc.message {
+						messages = append(messages, filepath.Dir(roleManifestPath)+"/"+message)
+					}
+					assert.EqualError(t, err, strings.Join(messages, "\n"))
 					assert.Nil(t, roleManifest)
 				} else {
 					assert.NoError(t, err)
@@ -610,6 +671,36 @@ func TestLoadRoleManifestRunGeneral(t *testing.T) {
 	}
 }
 
+func TestLoadRoleManifestControllers(t *testing.T) {
+	t.Parallel()
+	workDir, err := os.Getwd()
+	require.NoError(t, err)
+
+	torReleasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	torReleasePathBoshCache := filepath.Join(torReleasePath, "bosh-cache")
+	release, err := NewDevRelease(torReleasePath, "", "", torReleasePathBoshCache)
+	require.NoError(t, err, "Error reading BOSH release")
+
+	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/model/bosh-run-controller.yml")
+	roleManifest, err := LoadRoleManifest(roleManifestPath, []*Release{release}, nil)
+	require.NoError(t, err)
+
+	expected := map[string]RoleController{
+		"stateless":           RoleControllerDeployment,
+		"clustered":           RoleControllerStatefulSet,
+		"explicit-deployment": RoleControllerDeployment,
+		"persistent":          RoleControllerStatefulSet,
+		"headless":            RoleControllerStatefulSet,
+		"explicit":            RoleControllerStatefulSet,
+	}
+	for roleName, controller := range expected {
+		role := roleManifest.LookupRole(roleName)
+		if assert.NotNil(t, role, "Failed to find role %s", roleName) {
+			assert.Equal(t, controller, role.Run.Controller, "Wrong controller for role %s", roleName)
+		}
+	}
+}
+
 func TestLoadRoleManifestHealthChecks(t *testing.T) {
 	t.Parallel()
 	workDir, err := os.Getwd()
@@ -1171,6 +1262,12 @@ func TestLoadRoleManifestColocatedContainers(t *testing.T) {
 	for _, roleName := range []string{"main-role", "to-be-colocated"} {
 		assert.EqualValues([]*RoleRunVolume{&RoleRunVolume{Path: "/var/vcap/store", Type: "emptyDir", Tag: "shared-data"}}, roleManifest.LookupRole(roleName).Run.Volumes)
 	}
+
+	// Colocated containers default to sidecars
+	assert.Empty(roleManifest.LookupRole("main-role").Run.Colocation)
+	assert.Equal(ColocationModeSidecar, roleManifest.LookupRole("to-be-colocated").Run.Colocation)
+	assert.False(roleManifest.LookupRole("to-be-colocated").IsInitContainerRole())
+	assert.False(roleManifest.LookupRole("to-be-colocated").RunsToCompletion())
 }
 
 func TestLoadRoleManifestColocatedContainersValidationMissingRole(t *testing.T) {
@@ -1190,7 +1287,7 @@ func TestLoadRoleManifestColocatedContainersValidationMissingRole(t *testing.T)
 	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/model/colocated-containers-with-missing-role.yml")
 	roleManifest, err := LoadRoleManifest(roleManifestPath, []*Release{torRelease, ntpRelease}, nil)
 	assert.Nil(roleManifest)
-	assert.EqualError(err, `roles[main-role].colocated_containers[0]: Invalid value: "to-be-colocated-typo": There is no such role defined`)
+	assert.EqualError(err, roleManifestPath+`:13:3: roles[main-role].colocated_containers[0]: Invalid value: "to-be-colocated-typo": There is no such role defined`)
 }
 
 func TestLoadRoleManifestColocatedContainersValidationUsusedRole(t *testing.T) {
@@ -1273,7 +1370,7 @@ func TestLoadRoleManifestColocatedContainersValidationInvalidTags(t *testing.T)
 	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/model/colocated-containers-with-clustered-tag.yml")
 	roleManifest, err := LoadRoleManifest(roleManifestPath, []*Release{torRelease, ntpRelease}, nil)
 	assert.Nil(roleManifest)
-	assert.EqualError(err, `roles[to-be-colocated].tags[0]: Invalid value: "headless": headless tag is only supported in [bosh, docker] roles, not colocated-container`)
+	assert.EqualError(err, roleManifestPath+`:21:3: roles[to-be-colocated].tags[0]: Invalid value: "headless": headless tag is only supported in [bosh, docker] roles, not colocated-container`)
 }
 
 func TestLoadRoleManifestColocatedContainersValidationOfSharedVolumes(t *testing.T) {
@@ -1294,6 +1391,7 @@ func TestLoadRoleManifestColocatedContainersValidationOfSharedVolumes(t *testing
 	roleManifest, err := LoadRoleManifest(roleManifestPath, []*Release{torRelease, ntpRelease}, nil)
 	assert.Nil(roleManifest)
 	assert.EqualError(err, "role[to-be-colocated]: Invalid value: \"/mnt/foobAr\": colocated role specifies a shared volume with tag mount-share, which path does not match the path of the main role shared volume with the same tag\n"+
+		"role[to-be-colocated]: Invalid value: \"/tmp/scratch\": colocated role specifies a shared volume with tag scratch, which the main role does not have\n"+
 		"role[main-role]: Required value: container must use shared volumes of the main role: vcap-logs\n"+
 		"role[main-role]: Required value: container must use shared volumes of the main role: vcap-store")
 }
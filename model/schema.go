@@ -0,0 +1,213 @@
+package model
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/SUSE/fissile/validation"
+)
+
+// RoleManifestSchema returns the JSON Schema of role manifests, generated
+// from the structures they are loaded into.  Role manifests only have the
+// keys of these structures.
+func RoleManifestSchema() map[string]interface{} {
+	schema := typeSchema(reflect.TypeOf(RoleManifest{}))
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["title"] = "Fissile role manifest"
+	return schema
+}
+
+// typeSchema returns the JSON Schema of the YAML representation of a type
+func typeSchema(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem())
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		addStructSchemaProperties(properties, t)
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": typeSchema(t.Elem()),
+		}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{
+			"type":  "array",
+			"items": typeSchema(t.Elem()),
+		}
+	case reflect.String:
+		// Scalars of any type are read as strings
+		return map[string]interface{}{"type": []string{"string", "number", "boolean"}}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	default:
+		// Any value
+		return map[string]interface{}{}
+	}
+}
+
+// addStructSchemaProperties adds the schemas of the fields of a structure to
+// the properties of its schema, keyed like the YAML decoder does
+func addStructSchemaProperties(properties map[string]interface{}, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			// Unexported fields are not loaded from YAML
+			continue
+		}
+		tag := strings.Split(field.Tag.Get("yaml"), ",")
+		if tag[0] == "-" {
+			continue
+		}
+		name := tag[0]
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		inline := false
+		for _, flag := range tag[1:] {
+			inline = inline || flag == "inline"
+		}
+		if inline {
+			fieldType := field.Type
+			if fieldType.Kind() == reflect.Ptr {
+				fieldType = fieldType.Elem()
+			}
+			addStructSchemaProperties(properties, fieldType)
+			continue
+		}
+		properties[name] = typeSchema(field.Type)
+	}
+}
+
+// validateSchemaKeys reports the keys of a YAML node that its schema does not
+// have, at their position in the file.  The field names the node, and the path
+// is its path in the file.
+func validateSchemaKeys(node interface{}, schema map[string]interface{}, field, path string, file *yamlFile) validation.ErrorList {
+	allErrs := validation.ErrorList{}
+
+	switch node := node.(type) {
+	case map[interface{}]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		additional, _ := schema["additionalProperties"].(map[string]interface{})
+		if properties == nil && additional == nil {
+			break
+		}
+
+		// Keys are reported in the order of the file
+		keys := make([]interface{}, 0, len(node))
+		for key := range node {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			left := file.positions[yamlPositionPath(path, keys[i])]
+			right := file.positions[yamlPositionPath(path, keys[j])]
+			if left.Line != right.Line {
+				return left.Line < right.Line
+			}
+			return keyString(keys[i]) < keyString(keys[j])
+		})
+
+		for _, key := range keys {
+			childField := keyString(key)
+			if field != "" {
+				childField = fmt.Sprintf("%s.%s", field, childField)
+			}
+			childPath := yamlPositionPath(path, key)
+
+			childSchema, ok := properties[keyString(key)].(map[string]interface{})
+			if !ok && additional != nil {
+				childSchema, ok = additional, true
+			}
+			if !ok {
+				detail := "Unknown key"
+				if suggestion := suggestSchemaKey(keyString(key), properties); suggestion != "" {
+					detail = fmt.Sprintf("%s, did you mean %s?", detail, suggestion)
+				}
+				err := validation.Forbidden(childField, detail)
+				err.Position = validation.Position{File: file.path}
+				if position, ok := file.positions[childPath]; ok {
+					err.Position.Line, err.Position.Column = position.Line, position.Column
+				}
+				allErrs = append(allErrs, err)
+				continue
+			}
+			allErrs = append(allErrs, validateSchemaKeys(node[key], childSchema, childField, childPath, file)...)
+		}
+
+	case []interface{}:
+		items, ok := schema["items"].(map[string]interface{})
+		if !ok {
+			break
+		}
+		for index, item := range node {
+			name := fmt.Sprintf("%d", index)
+			if entry, ok := item.(map[interface{}]interface{}); ok {
+				if entryName, ok := entry["name"].(string); ok {
+					name = entryName
+				}
+			}
+			childField := fmt.Sprintf("%s[%s]", field, name)
+			allErrs = append(allErrs, validateSchemaKeys(item, items, childField, yamlPositionPath(path, index), file)...)
+		}
+	}
+
+	return allErrs
+}
+
+// suggestSchemaKey returns the known key matching an unknown key but for its
+// case, dashes and underscores, if any
+func suggestSchemaKey(key string, properties map[string]interface{}) string {
+	normalize := func(key string) string {
+		return strings.ToLower(strings.NewReplacer("-", "", "_", "").Replace(key))
+	}
+	for property := range properties {
+		if normalize(property) == normalize(key) {
+			return property
+		}
+	}
+	return ""
+}
+
+// keyString returns a key of a YAML mapping as a string
+func keyString(key interface{}) string {
+	return fmt.Sprintf("%v", key)
+}
+
+// validateRoleManifestKeys reports the keys of a role manifest file that role
+// manifests do not have.  The field prefixes the names of the keys.  Docker
+// roles are ignored by fissile, and so are their keys.
+func validateRoleManifestKeys(file *yamlFile, field string) validation.ErrorList {
+	document := file.document
+	if manifest, ok := document.(map[interface{}]interface{}); ok {
+		if roles, ok := manifest["roles"].([]interface{}); ok {
+			// Docker roles are left out of copies, keeping the indices of the
+			// other roles, and the file as is to locate other fields
+			checked := make(map[interface{}]interface{}, len(manifest))
+			for key, value := range manifest {
+				checked[key] = value
+			}
+			checkedRoles := make([]interface{}, len(roles))
+			for i, role := range roles {
+				if role, ok := role.(map[interface{}]interface{}); ok && role["type"] == string(RoleTypeDocker) {
+					continue
+				}
+				checkedRoles[i] = role
+			}
+			checked["roles"] = checkedRoles
+			document = checked
+		}
+	}
+	return validateSchemaKeys(document, RoleManifestSchema(), field, "", file)
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoleManifestSchema(t *testing.T) {
+	schema := RoleManifestSchema()
+	assert.Equal(t, "http://json-schema.org/draft-07/schema#", schema["$schema"])
+	assert.Equal(t, false, schema["additionalProperties"])
+
+	properties := schema["properties"].(map[string]interface{})
+	assert.Contains(t, properties, "roles")
+	assert.Contains(t, properties, "configuration")
+	assert.Contains(t, properties, "includes")
+
+	roles := properties["roles"].(map[string]interface{})
+	assert.Equal(t, "array", roles["type"])
+	role := roles["items"].(map[string]interface{})
+	roleProperties := role["properties"].(map[string]interface{})
+	assert.Contains(t, roleProperties, "environment_scripts")
+	assert.NotContains(t, roleProperties, "manifestFilePath", "Unexported fields should not be in the schema")
+
+	run := roleProperties["run"].(map[string]interface{})
+	runProperties := run["properties"].(map[string]interface{})
+	assert.Contains(t, runProperties, "virtual-cpus")
+	assert.Equal(t, map[string]interface{}{"type": "number"}, runProperties["virtual-cpus"])
+}
+
+func TestLoadRoleManifestUnknownKeys(t *testing.T) {
+	workDir, err := os.Getwd()
+	assert.NoError(t, err)
+
+	torReleasePath := filepath.Join(workDir, "../test-assets/tor-boshrelease")
+	torReleasePathBoshCache := filepath.Join(torReleasePath, "bosh-cache")
+	release, err := NewDevRelease(torReleasePath, "", "", torReleasePathBoshCache)
+	assert.NoError(t, err)
+
+	roleManifestPath := filepath.Join(workDir, "../test-assets/role-manifests/model/unknown-keys.yml")
+	roleManifest, err := LoadRoleManifest(roleManifestPath, []*Release{release}, nil)
+	assert.Nil(t, roleManifest)
+	require.Error(t, err)
+	assert.Equal(t, roleManifestPath+`:7:5: roles[myrole].run.virtual_cpus: Forbidden: Unknown key, did you mean virtual-cpus?
+`+roleManifestPath+`:12:7: roles[myrole].run.exposed-ports[http].internalport: Forbidden: Unknown key
+`+roleManifestPath+`:16:5: roles[myrole].jobs[tor].propertys: Forbidden: Unknown key
+`+roleManifestPath+`:25:5: configuration.variables[FOO].previous-names: Forbidden: Unknown key, did you mean previous_names?`, err.Error())
+}
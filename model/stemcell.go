@@ -0,0 +1,277 @@
+package model
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// StemcellInfoFile is the name of the file describing the stemcell in each
+// stemcell directory of the compilation cache
+const StemcellInfoFile = "stemcell.yml"
+
+// UnknownStemcellCacheKeyPrefix prefixes the keys of the directories holding
+// packages of older fissile versions that cannot be tied to a stemcell image.
+// They are kept, but never reused.
+const UnknownStemcellCacheKeyPrefix = "unknown-"
+
+// Stemcell identifies the stemcell image packages are compiled against
+type Stemcell struct {
+	ImageName string `yaml:"image_name" json:"image_name"`
+	ImageID   string `yaml:"image_id,omitempty" json:"image_id,omitempty"`
+	OS        string `yaml:"os,omitempty" json:"os,omitempty"`
+	Version   string `yaml:"version,omitempty" json:"version,omitempty"`
+	// Created is when the image was built, if known
+	Created time.Time `yaml:"-" json:"-"`
+}
+
+// CacheKey returns the name of the directory holding the packages compiled
+// against the stemcell.  It is derived from the image ID, so that pointing
+// the image name at a different image never reuses packages compiled against
+// the old one.  If the image ID is not known, the name based key of older
+// fissile versions is used.
+func (s *Stemcell) CacheKey() string {
+	if s.ImageID == "" {
+		return LegacyStemcellCacheKey(s.ImageName)
+	}
+	return strings.TrimPrefix(s.ImageID, "sha256:")
+}
+
+// LegacyStemcellCacheKey returns the name of the directory older versions of
+// fissile stored packages compiled against the named stemcell image in
+func LegacyStemcellCacheKey(imageName string) string {
+	hasher := sha1.New()
+	hasher.Write([]byte(imageName))
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// CompilationDir returns the directory holding the packages compiled against
+// the stemcell, inside the compilation cache
+func (s *Stemcell) CompilationDir(compilationDir string) string {
+	return filepath.Join(compilationDir, s.CacheKey())
+}
+
+// FindCompilationDir returns the directory holding the packages compiled
+// against the stemcell.  Until the compilation cache has been migrated by
+// PrepareCompilationDir, this is the directory of an older fissile version,
+// if there is one and it belongs to the image.
+func (s *Stemcell) FindCompilationDir(compilationDir string) string {
+	stemcellDir := s.CompilationDir(compilationDir)
+	if _, err := os.Stat(stemcellDir); os.IsNotExist(err) {
+		legacyDir := filepath.Join(compilationDir, LegacyStemcellCacheKey(s.ImageName))
+		if isLegacyStemcellDir(legacyDir) && s.compiledLegacyDir(legacyDir) {
+			return legacyDir
+		}
+	}
+
+	return stemcellDir
+}
+
+// PrepareCompilationDir creates the directory for packages compiled against
+// the stemcell, and records which stemcell it belongs to.  A directory of an
+// older fissile version for the same image name, unless claimed by another
+// image already, is adopted (moved into place) if its packages were compiled
+// after the image was built.  Otherwise the image name may have been moved
+// to the image since, so the directory is moved aside, keyed as unknown.
+// The second return value is where the directory of the older version was
+// moved to, if anywhere.
+func (s *Stemcell) PrepareCompilationDir(compilationDir string) (string, string, error) {
+	stemcellDir := s.CompilationDir(compilationDir)
+	movedTo := ""
+
+	if _, err := os.Stat(stemcellDir); os.IsNotExist(err) {
+		legacyKey := LegacyStemcellCacheKey(s.ImageName)
+		legacyDir := filepath.Join(compilationDir, legacyKey)
+		if legacyDir != stemcellDir && isLegacyStemcellDir(legacyDir) {
+			movedTo = stemcellDir
+			if !s.compiledLegacyDir(legacyDir) {
+				movedTo = filepath.Join(compilationDir, UnknownStemcellCacheKeyPrefix+legacyKey)
+			}
+			if err := moveLegacyStemcellDir(legacyDir, movedTo); err != nil {
+				return "", "", fmt.Errorf("Error migrating compilation cache %s: %s", legacyDir, err.Error())
+			}
+		}
+	} else if err != nil {
+		return "", "", err
+	}
+
+	if err := os.MkdirAll(stemcellDir, 0755); err != nil {
+		return "", "", err
+	}
+
+	// Without an image ID the directory is name based, like the old layout
+	if s.ImageID != "" {
+		contents, err := yaml.Marshal(s)
+		if err != nil {
+			return "", "", err
+		}
+		if err := ioutil.WriteFile(filepath.Join(stemcellDir, StemcellInfoFile), contents, 0644); err != nil {
+			return "", "", err
+		}
+	}
+
+	return stemcellDir, movedTo, nil
+}
+
+// compiledLegacyDir returns true if the packages in a directory of an older
+// fissile version were all compiled after the image was built, so they can
+// only have been compiled against it
+func (s *Stemcell) compiledLegacyDir(legacyDir string) bool {
+	if s.ImageID == "" {
+		// The directory is the one of the image name, as it was
+		return true
+	}
+	if s.Created.IsZero() {
+		return false
+	}
+
+	packages, err := ioutil.ReadDir(legacyDir)
+	if err != nil {
+		return false
+	}
+	for _, pkg := range packages {
+		info, err := os.Stat(filepath.Join(legacyDir, pkg.Name(), "compiled"))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil || info.ModTime().Before(s.Created) {
+			return false
+		}
+	}
+	return true
+}
+
+// moveLegacyStemcellDir moves a directory of an older fissile version to the
+// target directory.  Packages the target has already are left behind.
+func moveLegacyStemcellDir(legacyDir, targetDir string) error {
+	if _, err := os.Stat(targetDir); os.IsNotExist(err) {
+		return os.Rename(legacyDir, targetDir)
+	}
+
+	packages, err := ioutil.ReadDir(legacyDir)
+	if err != nil {
+		return err
+	}
+	for _, pkg := range packages {
+		target := filepath.Join(targetDir, pkg.Name())
+		if _, err := os.Stat(target); os.IsNotExist(err) {
+			if err := os.Rename(filepath.Join(legacyDir, pkg.Name()), target); err != nil {
+				return err
+			}
+		}
+	}
+	// The directory is only removed if nothing was left behind
+	os.Remove(legacyDir)
+	return nil
+}
+
+func (s *Stemcell) String() string {
+	var description string
+	if s.OS != "" {
+		description = fmt.Sprintf("%s/%s ", s.OS, s.Version)
+	}
+
+	if s.ImageID == "" {
+		return fmt.Sprintf("%s(%s)", description, s.ImageName)
+	}
+
+	imageID := strings.TrimPrefix(s.ImageID, "sha256:")
+	if len(imageID) > 12 {
+		imageID = imageID[:12]
+	}
+	return fmt.Sprintf("%s(%s, %s)", description, s.ImageName, imageID)
+}
+
+// CachedStemcell describes a stemcell directory of the compilation cache
+type CachedStemcell struct {
+	Key  string
+	Path string
+	// Stemcell is nil for directories of older fissile versions, which
+	// don't record the stemcell
+	Stemcell *Stemcell
+	// Fingerprints of the packages in the directory, compiled or not
+	Fingerprints []string
+}
+
+// LoadCachedStemcells scans the compilation cache, and returns its stemcell
+// directories sorted by key
+func LoadCachedStemcells(compilationDir string) ([]*CachedStemcell, error) {
+	entries, err := ioutil.ReadDir(compilationDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var result []*CachedStemcell
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		cached := &CachedStemcell{
+			Key:  entry.Name(),
+			Path: filepath.Join(compilationDir, entry.Name()),
+		}
+
+		contents, err := ioutil.ReadFile(filepath.Join(cached.Path, StemcellInfoFile))
+		if err == nil {
+			cached.Stemcell = &Stemcell{}
+			if err := yaml.Unmarshal(contents, cached.Stemcell); err != nil {
+				return nil, fmt.Errorf("Error loading %s: %s", filepath.Join(cached.Path, StemcellInfoFile), err.Error())
+			}
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		packages, err := ioutil.ReadDir(cached.Path)
+		if err != nil {
+			return nil, err
+		}
+		for _, pkg := range packages {
+			if pkg.IsDir() {
+				cached.Fingerprints = append(cached.Fingerprints, pkg.Name())
+			}
+		}
+		sort.Strings(cached.Fingerprints)
+
+		result = append(result, cached)
+	}
+
+	return result, nil
+}
+
+// HasCompiled returns true if the package with the given fingerprint has been
+// compiled against the stemcell, not merely prepared for compilation
+func (c *CachedStemcell) HasCompiled(fingerprint string) bool {
+	entries, err := ioutil.ReadDir(filepath.Join(c.Path, fingerprint, "compiled"))
+	return err == nil && len(entries) > 0
+}
+
+// Description returns a human readable description of the stemcell
+func (c *CachedStemcell) Description() string {
+	if strings.HasPrefix(c.Key, UnknownStemcellCacheKeyPrefix) {
+		return "unknown stemcell image (compiled by an older fissile version, not reused)"
+	}
+	if c.Stemcell == nil {
+		return "unknown stemcell (compiled by an older fissile version)"
+	}
+	return c.Stemcell.String()
+}
+
+// isLegacyStemcellDir returns true if the directory exists and was not
+// claimed by a stemcell image yet
+func isLegacyStemcellDir(dir string) bool {
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(dir, StemcellInfoFile))
+	return os.IsNotExist(err)
+}
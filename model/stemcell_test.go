@@ -0,0 +1,148 @@
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SUSE/fissile/util"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStemcellCacheKey(t *testing.T) {
+	assert := assert.New(t)
+
+	stemcell := &Stemcell{ImageName: "ubuntu:14.04", ImageID: "sha256:0123456789abcdef"}
+	assert.Equal("0123456789abcdef", stemcell.CacheKey())
+	assert.Equal("/work/compilation/0123456789abcdef", stemcell.CompilationDir("/work/compilation"))
+
+	// Without an image ID, the key is the one used by older fissile versions
+	stemcell = &Stemcell{ImageName: "ubuntu:14.04"}
+	assert.Equal("4d51b43d077ed5a7b7ae4fb200aeb216b7736a96", stemcell.CacheKey())
+	assert.Equal(LegacyStemcellCacheKey("ubuntu:14.04"), stemcell.CacheKey())
+}
+
+func TestStemcellPrepareCompilationDir(t *testing.T) {
+	assert := assert.New(t)
+
+	compilationDir, err := ioutil.TempDir("", "fissile-tests")
+	require.NoError(t, err)
+	defer os.RemoveAll(compilationDir)
+
+	// A cache directory as created by older fissile versions
+	legacyDir := filepath.Join(compilationDir, LegacyStemcellCacheKey("stemcell:latest"))
+	require.NoError(t, os.MkdirAll(filepath.Join(legacyDir, "fingerprint", "compiled", "bin"), 0755))
+
+	stemcell := &Stemcell{
+		ImageName: "stemcell:latest",
+		ImageID:   "sha256:0123456789abcdef",
+		OS:        "ubuntu-trusty",
+		Version:   "3586.40",
+		Created:   time.Now().Add(-time.Hour),
+	}
+	assert.Equal(legacyDir, stemcell.FindCompilationDir(compilationDir))
+
+	stemcellDir, movedTo, err := stemcell.PrepareCompilationDir(compilationDir)
+	require.NoError(t, err)
+	assert.Equal(stemcellDir, movedTo, "Packages compiled after the image was built should be migrated")
+	assert.Equal(filepath.Join(compilationDir, "0123456789abcdef"), stemcellDir)
+	assert.Equal(stemcellDir, stemcell.FindCompilationDir(compilationDir))
+	assert.NoError(util.ValidatePath(filepath.Join(stemcellDir, "fingerprint", "compiled"), true, "migrated package"))
+	assert.Error(util.ValidatePath(legacyDir, true, "legacy directory"))
+
+	// Preparing again keeps what is there
+	_, movedTo, err = stemcell.PrepareCompilationDir(compilationDir)
+	require.NoError(t, err)
+	assert.Empty(movedTo)
+
+	// A new image with the same name starts from scratch
+	newStemcell := &Stemcell{ImageName: "stemcell:latest", ImageID: "sha256:fedcba9876543210", Created: time.Now().Add(-time.Hour)}
+	require.NoError(t, os.MkdirAll(filepath.Join(legacyDir, "other"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(legacyDir, StemcellInfoFile), []byte("image_name: stemcell:latest\n"), 0644))
+	newDir, movedTo, err := newStemcell.PrepareCompilationDir(compilationDir)
+	require.NoError(t, err)
+	assert.Empty(movedTo, "A claimed directory must not be migrated")
+	assert.Equal(filepath.Join(compilationDir, "fedcba9876543210"), newDir)
+
+	cached, err := LoadCachedStemcells(compilationDir)
+	require.NoError(t, err)
+	require.Len(t, cached, 3)
+
+	keys := map[string]*CachedStemcell{}
+	for _, c := range cached {
+		keys[c.Key] = c
+	}
+
+	if assert.Contains(keys, "0123456789abcdef") {
+		c := keys["0123456789abcdef"]
+		recorded := *stemcell
+		recorded.Created = time.Time{}
+		assert.Equal(&recorded, c.Stemcell, "The creation time of the image is not recorded")
+		assert.Equal([]string{"fingerprint"}, c.Fingerprints)
+		assert.True(c.HasCompiled("fingerprint"))
+		assert.False(c.HasCompiled("other"))
+		assert.Equal("ubuntu-trusty/3586.40 (stemcell:latest, 0123456789ab)", c.Description())
+	}
+	if assert.Contains(keys, "fedcba9876543210") {
+		assert.Empty(keys["fedcba9876543210"].Fingerprints)
+	}
+}
+
+func TestStemcellPrepareCompilationDirUnknownImage(t *testing.T) {
+	assert := assert.New(t)
+
+	compilationDir, err := ioutil.TempDir("", "fissile-tests")
+	require.NoError(t, err)
+	defer os.RemoveAll(compilationDir)
+
+	// Packages compiled by an older fissile version, before the image name
+	// was moved to a new image
+	legacyKey := LegacyStemcellCacheKey("stemcell:latest")
+	legacyDir := filepath.Join(compilationDir, legacyKey)
+	require.NoError(t, os.MkdirAll(filepath.Join(legacyDir, "fingerprint", "compiled", "bin"), 0755))
+
+	stemcell := &Stemcell{
+		ImageName: "stemcell:latest",
+		ImageID:   "sha256:0123456789abcdef",
+		Created:   time.Now().Add(time.Hour),
+	}
+	stemcellDir := stemcell.CompilationDir(compilationDir)
+	assert.Equal(stemcellDir, stemcell.FindCompilationDir(compilationDir), "Packages of other images must not be used")
+
+	preparedDir, movedTo, err := stemcell.PrepareCompilationDir(compilationDir)
+	require.NoError(t, err)
+	assert.Equal(stemcellDir, preparedDir)
+	assert.Equal(filepath.Join(compilationDir, UnknownStemcellCacheKeyPrefix+legacyKey), movedTo)
+	assert.Error(util.ValidatePath(filepath.Join(stemcellDir, "fingerprint"), true, "package of another image"))
+	assert.Error(util.ValidatePath(legacyDir, true, "legacy directory"))
+
+	cached, err := LoadCachedStemcells(compilationDir)
+	require.NoError(t, err)
+	require.Len(t, cached, 2)
+	for _, c := range cached {
+		if c.Key == UnknownStemcellCacheKeyPrefix+legacyKey {
+			assert.True(c.HasCompiled("fingerprint"))
+			assert.Equal("unknown stemcell image (compiled by an older fissile version, not reused)", c.Description())
+		} else {
+			assert.Empty(c.Fingerprints)
+		}
+	}
+
+	// Without a creation time, packages cannot be tied to the image either
+	stemcell = &Stemcell{ImageName: "other:latest", ImageID: "sha256:fedcba9876543210"}
+	otherLegacyDir := filepath.Join(compilationDir, LegacyStemcellCacheKey("other:latest"))
+	require.NoError(t, os.MkdirAll(filepath.Join(otherLegacyDir, "fingerprint", "compiled"), 0755))
+	_, movedTo, err = stemcell.PrepareCompilationDir(compilationDir)
+	require.NoError(t, err)
+	assert.Equal(filepath.Join(compilationDir, UnknownStemcellCacheKeyPrefix+LegacyStemcellCacheKey("other:latest")), movedTo)
+}
+
+func TestLoadCachedStemcellsMissing(t *testing.T) {
+	cached, err := LoadCachedStemcells(filepath.Join(os.TempDir(), "fissile-does-not-exist"))
+	assert.NoError(t, err)
+	assert.Empty(t, cached)
+}
@@ -0,0 +1,298 @@
+package model
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/SUSE/fissile/validation"
+
+	"gopkg.in/yaml.v2"
+)
+
+// yamlPosition is the position of a node in a YAML file
+type yamlPosition struct {
+	Line   int
+	Column int
+}
+
+// yamlPositions maps the paths of the nodes of a YAML document to their
+// positions.  Paths are made of the slash separated keys and list indices
+// leading to a node, with "~" and "/" in keys escaped as "~0" and "~1".
+type yamlPositions map[string]yamlPosition
+
+// yamlPositionsFrame is a mapping or list of a YAML document being scanned
+type yamlPositionsFrame struct {
+	column int
+	list   bool
+	path   string
+	count  int
+}
+
+// yamlPositionPath returns the path of the child of a node
+func yamlPositionPath(path string, key interface{}) string {
+	if index, ok := key.(int); ok {
+		return path + "/" + strconv.Itoa(index)
+	}
+	return path + "/" + strings.Replace(strings.Replace(keyString(key), "~", "~0", -1), "/", "~1", -1)
+}
+
+// scanYAMLPositions returns the positions of the nodes of a YAML document.
+// Only block mappings and lists are scanned; the entries of flow mappings and
+// lists, and of the following documents of a stream, have no positions.
+func scanYAMLPositions(contents []byte) yamlPositions {
+	positions := yamlPositions{}
+
+	var stack []*yamlPositionsFrame
+	var pending string      // The path of the last entry, for the nodes nested in it
+	blockScalarColumn := -1 // The column of the entry of a block scalar being skipped
+	documents := 0
+
+	for lineIndex, line := range strings.Split(string(contents), "\n") {
+		line = strings.TrimRight(line, "\r")
+		trimmed := strings.TrimLeft(line, " ")
+		column := len(line) - len(trimmed)
+
+		if blockScalarColumn >= 0 {
+			if trimmed == "" || column > blockScalarColumn {
+				continue
+			}
+			blockScalarColumn = -1
+		}
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "%") {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "---") || strings.HasPrefix(trimmed, "...") {
+			if strings.HasPrefix(trimmed, "---") {
+				documents++
+			}
+			stack = nil
+			continue
+		}
+		if documents > 1 {
+			break
+		}
+
+		for trimmed != "" {
+			list := trimmed == "-" || strings.HasPrefix(trimmed, "- ")
+			var key, value string
+			if list {
+				value = strings.TrimPrefix(trimmed, "-")
+			} else {
+				var ok bool
+				key, value, ok = splitYAMLKey(trimmed)
+				if !ok {
+					// A continued scalar
+					break
+				}
+			}
+
+			for len(stack) > 0 {
+				top := stack[len(stack)-1]
+				if top.column > column || (top.column == column && top.list && !list) {
+					stack = stack[:len(stack)-1]
+					continue
+				}
+				break
+			}
+			if len(stack) == 0 || stack[len(stack)-1].column < column ||
+				(list && !stack[len(stack)-1].list) {
+				path := ""
+				if len(stack) > 0 {
+					path = pending
+				}
+				stack = append(stack, &yamlPositionsFrame{column: column, list: list, path: path})
+			}
+
+			top := stack[len(stack)-1]
+			if list {
+				pending = yamlPositionPath(top.path, top.count)
+				top.count++
+			} else {
+				pending = yamlPositionPath(top.path, key)
+			}
+			positions[pending] = yamlPosition{Line: lineIndex + 1, Column: column + 1}
+
+			rest := strings.TrimLeft(value, " ")
+			if strings.HasPrefix(rest, "|") || strings.HasPrefix(rest, ">") {
+				blockScalarColumn = column
+				break
+			}
+			if !list || rest == "" {
+				break
+			}
+			// The node of the list entry is on the same line
+			column += len(trimmed) - len(rest)
+			trimmed = rest
+		}
+	}
+
+	return positions
+}
+
+// splitYAMLKey splits a line of a YAML mapping into its key and value
+func splitYAMLKey(line string) (string, string, bool) {
+	if strings.HasPrefix(line, `"`) || strings.HasPrefix(line, "'") {
+		end := strings.Index(line[1:], line[:1])
+		if end < 0 {
+			return "", "", false
+		}
+		rest := line[end+2:]
+		if !strings.HasPrefix(rest, ":") {
+			return "", "", false
+		}
+		return line[1 : end+1], rest[1:], true
+	}
+	if strings.HasPrefix(line, "[") || strings.HasPrefix(line, "{") {
+		return "", "", false
+	}
+
+	for i := 0; i < len(line); i++ {
+		if line[i] == '#' && (i == 0 || line[i-1] == ' ') {
+			break
+		}
+		if line[i] == ':' && (i+1 == len(line) || line[i+1] == ' ') {
+			return strings.TrimRight(line[:i], " "), line[i+1:], true
+		}
+	}
+	return "", "", false
+}
+
+// yamlFile is a YAML file as loaded, with the positions of its nodes, to find
+// the fields reported by validation errors
+type yamlFile struct {
+	path      string
+	document  interface{}
+	positions yamlPositions
+}
+
+// newYAMLFile parses the contents of a YAML file for the positions of its
+// nodes.  Parse errors are left to the loading of the file.
+func newYAMLFile(path string, contents []byte) *yamlFile {
+	file := &yamlFile{path: path}
+	if err := yaml.Unmarshal(contents, &file.document); err == nil {
+		file.positions = scanYAMLPositions(contents)
+	}
+	return file
+}
+
+// locate returns the position of the node named by a validation field, such
+// as "roles[api].run.memory", and the number of parts of the field it
+// matched.  When the node is not found, its deepest parent is returned.
+func (f *yamlFile) locate(field string) (validation.Position, int) {
+	position := validation.Position{}
+	depth := 0
+
+	node := f.document
+	path := ""
+	parts := splitValidationField(field)
+	for len(parts) > 0 {
+		var key interface{}
+		matched := 0
+
+		switch value := node.(type) {
+		case map[interface{}]interface{}:
+			// Keys may contain dots, as in the names of properties
+			for count := 1; count <= len(parts) && key == nil; count++ {
+				name := strings.Join(parts[:count], ".")
+				for candidate := range value {
+					if keyString(candidate) == name {
+						key, matched = candidate, count
+						break
+					}
+				}
+			}
+			if key != nil {
+				node = value[key]
+			}
+		case []interface{}:
+			// List entries are named by their names, or their index
+			for index, item := range value {
+				name := keyString(item)
+				if entry, ok := item.(map[interface{}]interface{}); ok {
+					name = keyString(entry["name"])
+				}
+				if name == parts[0] {
+					key, matched = index, 1
+					break
+				}
+			}
+			if key == nil {
+				if index, err := strconv.Atoi(parts[0]); err == nil && index >= 0 && index < len(value) {
+					key, matched = index, 1
+				}
+			}
+			if key != nil {
+				node = value[key.(int)]
+			}
+		}
+		if key == nil {
+			break
+		}
+
+		path = yamlPositionPath(path, key)
+		parts = parts[matched:]
+		depth += matched
+		// Nodes in flow mappings and lists have no positions of their own
+		if found, ok := f.positions[path]; ok {
+			position = validation.Position{File: f.path, Line: found.Line, Column: found.Column}
+		}
+	}
+
+	if depth > 0 && !position.IsKnown() {
+		position = validation.Position{File: f.path}
+	}
+	return position, depth
+}
+
+// yamlFiles are the files a document is merged from, in order
+type yamlFiles []*yamlFile
+
+// position returns the position of the node named by a validation field, in
+// the file matching the most of it; later files override earlier ones
+func (files yamlFiles) position(field string) validation.Position {
+	position := validation.Position{}
+	depth := 0
+	for _, file := range files {
+		if filePosition, fileDepth := file.locate(field); fileDepth > 0 && fileDepth >= depth {
+			position, depth = filePosition, fileDepth
+		}
+	}
+	return position
+}
+
+// locateErrors sets the positions of the validation errors that have none to
+// the positions of their fields
+func (files yamlFiles) locateErrors(allErrs validation.ErrorList) {
+	for _, err := range allErrs {
+		if !err.Position.IsKnown() {
+			err.Position = files.position(err.Field)
+		}
+	}
+}
+
+// splitValidationField splits a validation field into the keys and list
+// entries it names: "roles[api].run.memory" names roles, api, run and memory
+func splitValidationField(field string) []string {
+	var parts []string
+	for field != "" {
+		switch {
+		case strings.HasPrefix(field, "."):
+			field = field[1:]
+		case strings.HasPrefix(field, "["):
+			end := strings.Index(field, "]")
+			if end < 0 {
+				return append(parts, field[1:])
+			}
+			parts = append(parts, field[1:end])
+			field = field[end+1:]
+		default:
+			end := strings.IndexAny(field, ".[")
+			if end < 0 {
+				end = len(field)
+			}
+			parts = append(parts, field[:end])
+			field = field[end:]
+		}
+	}
+	return parts
+}
@@ -0,0 +1,87 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/SUSE/fissile/validation"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanYAMLPositions(t *testing.T) {
+	positions := scanYAMLPositions([]byte(`---
+roles:
+- name: myrole
+  run:
+    memory: 1
+  jobs:
+  - name: tor
+    properties:
+      a/b: |
+        c: d
+      e: f
+configuration: {}
+`))
+	assert.Equal(t, yamlPositions{
+		"/roles":                          {Line: 2, Column: 1},
+		"/roles/0":                        {Line: 3, Column: 1},
+		"/roles/0/name":                   {Line: 3, Column: 3},
+		"/roles/0/run":                    {Line: 4, Column: 3},
+		"/roles/0/run/memory":             {Line: 5, Column: 5},
+		"/roles/0/jobs":                   {Line: 6, Column: 3},
+		"/roles/0/jobs/0":                 {Line: 7, Column: 3},
+		"/roles/0/jobs/0/name":            {Line: 7, Column: 5},
+		"/roles/0/jobs/0/properties":      {Line: 8, Column: 5},
+		"/roles/0/jobs/0/properties/a~1b": {Line: 9, Column: 7},
+		"/roles/0/jobs/0/properties/e":    {Line: 11, Column: 7},
+		"/configuration":                  {Line: 12, Column: 1},
+	}, positions)
+}
+
+func TestYAMLFileLocate(t *testing.T) {
+	file := newYAMLFile("role-manifest.yml", []byte(`---
+roles:
+- name: myrole
+  run:
+    memory: 1
+    exposed-ports:
+    - name: http
+      internal: 80
+  tags: [headless]
+configuration:
+  templates:
+    properties.tor.hostname: ((FOO))
+`))
+
+	testCases := []struct {
+		field  string
+		line   int
+		column int
+		depth  int
+	}{
+		{"roles[myrole].run.memory", 5, 5, 4},
+		{"roles[myrole].run.exposed-ports[http].internal", 8, 7, 6},
+		{"roles[0].run", 4, 3, 3},
+		{"roles[myrole].tags[0]", 9, 3, 4},
+		{"configuration.templates[properties.tor.hostname]", 12, 5, 3},
+		{"roles[myrole].run.scaling.min", 4, 3, 3},
+		{"roles[other]", 2, 1, 1},
+		{"role-manifest 'fox'", 0, 0, 0},
+	}
+	for _, testCase := range testCases {
+		position, depth := file.locate(testCase.field)
+		assert.Equal(t, testCase.depth, depth, testCase.field)
+		if testCase.depth == 0 {
+			assert.Equal(t, validation.Position{}, position, testCase.field)
+			continue
+		}
+		assert.Equal(t, validation.Position{File: "role-manifest.yml", Line: testCase.line, Column: testCase.column}, position, testCase.field)
+	}
+}
+
+func TestSplitValidationField(t *testing.T) {
+	assert.Equal(t, []string{"roles", "myrole", "run", "memory"}, splitValidationField("roles[myrole].run.memory"))
+	assert.Equal(t, []string{"configuration", "templates", "properties.tor.hostname"},
+		splitValidationField("configuration.templates[properties.tor.hostname]"))
+	assert.Equal(t, []string{"properties", "tor", "hostname"}, splitValidationField("properties.tor.hostname"))
+}
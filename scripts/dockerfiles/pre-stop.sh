@@ -44,7 +44,7 @@ fi
 set -o errexit
 echo "Running pre-stop script..."
 
-{{ if ne .role.Type "bosh-task" }}
+{{ if not .role.RunsToCompletion }}
     processes=($(/var/vcap/bosh/bin/monit summary | awk '$1 == "Process" { print $2 }' | tr -d "'"))
 
     # Lifecycle: Stop: 1. `monit unmonitor` is called for each process
@@ -132,7 +132,7 @@ then
   chmod 1730 /var/spool/cron/tabs/
 fi
 
-{{ if eq .role.Type "bosh-task" }}
+{{ if .role.RunsToCompletion }}
     # Start rsyslog and cron
     /usr/sbin/rsyslogd
     cron
@@ -170,7 +170,7 @@ for fname in $(sorted-pre-start-paths) ; do
 done
 
 # Run
-{{ if eq .role.Type "bosh-task" }}
+{{ if .role.RunsToCompletion }}
         idx=0
     {{ range $job := .role.RoleJobs}}
         if [ -x /var/vcap/jobs/{{ $job.Name }}/bin/run ] ; then
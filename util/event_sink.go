@@ -0,0 +1,204 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// EventType identifies the kind of an Event
+type EventType string
+
+// These are the events emitted by long-running commands
+const (
+	EventPackageCompileStart   EventType = "package-compile-start"
+	EventPackageCompileDone    EventType = "package-compile-done"
+	EventPackageCompileFailed  EventType = "package-compile-failed"
+	EventPackageCompileSkipped EventType = "package-compile-skipped"
+	EventPackageWaiting        EventType = "package-waiting"
+	EventPackageDependencyDone EventType = "package-dependency-done"
+	EventPackageCached         EventType = "package-cached"
+	EventPackageFound          EventType = "package-found"
+	EventPackageQueued         EventType = "package-queued"
+	EventRoleImageBuildStart   EventType = "role-image-build-start"
+	EventRoleImageBuildDone    EventType = "role-image-build-done"
+	EventRoleImageBuildFailed  EventType = "role-image-build-failed"
+	EventRoleImageBuildSkipped EventType = "role-image-build-skipped"
+	EventKubeFileWritten       EventType = "kube-file-written"
+	EventWarning               EventType = "warning"
+)
+
+// Event describes one step of a long-running command.  Only the fields
+// relevant to the event type are set.
+type Event struct {
+	Type        EventType
+	Time        time.Time
+	Release     string
+	Package     string
+	Fingerprint string
+	Dependency  string
+	Role        string
+	Image       string
+	Path        string
+	Duration    time.Duration
+	Message     string
+	Err         error
+}
+
+// MarshalJSON implements json.Marshaler; durations are written in seconds
+// and errors as their message
+func (e Event) MarshalJSON() ([]byte, error) {
+	var errorMessage string
+	if e.Err != nil {
+		errorMessage = e.Err.Error()
+	}
+
+	return json.Marshal(struct {
+		Time        time.Time `json:"time"`
+		Type        EventType `json:"event"`
+		Release     string    `json:"release,omitempty"`
+		Package     string    `json:"package,omitempty"`
+		Fingerprint string    `json:"fingerprint,omitempty"`
+		Dependency  string    `json:"dependency,omitempty"`
+		Role        string    `json:"role,omitempty"`
+		Image       string    `json:"image,omitempty"`
+		Path        string    `json:"path,omitempty"`
+		Duration    float64   `json:"duration,omitempty"`
+		Message     string    `json:"message,omitempty"`
+		Error       string    `json:"error,omitempty"`
+	}{
+		Time:        e.Time,
+		Type:        e.Type,
+		Release:     e.Release,
+		Package:     e.Package,
+		Fingerprint: e.Fingerprint,
+		Dependency:  e.Dependency,
+		Role:        e.Role,
+		Image:       e.Image,
+		Path:        e.Path,
+		Duration:    e.Duration.Seconds(),
+		Message:     e.Message,
+		Error:       errorMessage,
+	})
+}
+
+// EventSink receives the events of long-running commands.  It must be safe
+// for concurrent use, as events are emitted by parallel workers.
+type EventSink interface {
+	Emit(event Event)
+}
+
+// jsonEventSink writes one JSON object per event
+type jsonEventSink struct {
+	mutex   sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewJSONEventSink creates an EventSink writing each event as a line of JSON
+func NewJSONEventSink(writer io.Writer) EventSink {
+	return &jsonEventSink{encoder: json.NewEncoder(writer)}
+}
+
+func (s *jsonEventSink) Emit(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	_ = s.encoder.Encode(event)
+}
+
+// humanEventSink prints events as colored text
+type humanEventSink struct {
+	mutex  sync.Mutex
+	writer io.Writer
+}
+
+// NewHumanEventSink creates an EventSink printing events for humans
+func NewHumanEventSink(writer io.Writer) EventSink {
+	return &humanEventSink{writer: writer}
+}
+
+func (s *humanEventSink) Emit(event Event) {
+	message := formatHumanEvent(event)
+	if message == "" {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	fmt.Fprintln(s.writer, message)
+}
+
+func formatHumanEvent(event Event) string {
+	switch event.Type {
+	case EventPackageCompileStart:
+		return fmt.Sprintf("compile: %s/%s",
+			color.MagentaString(event.Release),
+			color.MagentaString(event.Package))
+	case EventPackageCompileDone:
+		return fmt.Sprintf("%s   > success: %s/%s",
+			color.YellowString("result"),
+			color.GreenString(event.Release),
+			color.GreenString(event.Package))
+	case EventPackageCompileFailed:
+		return fmt.Sprintf("%s   > failure: %s/%s - %s",
+			color.YellowString("result"),
+			color.RedString(event.Release),
+			color.RedString(event.Package),
+			color.RedString(fmt.Sprintf("%v", event.Err)))
+	case EventPackageCompileSkipped:
+		return fmt.Sprintf("%s   > skipped: %s/%s - %s failed",
+			color.YellowString("result"),
+			color.RedString(event.Release),
+			color.RedString(event.Package),
+			color.RedString(event.Dependency))
+	case EventPackageWaiting:
+		return fmt.Sprintf("waiting: %s/%s - %s",
+			color.MagentaString(event.Release),
+			color.MagentaString(event.Package),
+			color.MagentaString(event.Dependency))
+	case EventPackageDependencyDone:
+		return fmt.Sprintf("depdone: %s/%s - %s",
+			color.MagentaString(event.Release),
+			color.MagentaString(event.Package),
+			color.MagentaString(event.Dependency))
+	case EventPackageCached:
+		return fmt.Sprintf("cached:  %s/%s",
+			color.MagentaString(event.Release),
+			color.MagentaString(event.Package))
+	case EventPackageFound:
+		return fmt.Sprintf("found %s in %s", color.YellowString(event.Package), event.Path)
+	case EventPackageQueued:
+		return fmt.Sprintf("building %s in %s", color.YellowString(event.Package), event.Path)
+	case EventRoleImageBuildStart:
+		if event.Path != "" {
+			return fmt.Sprintf("Building tarball of %s...", color.YellowString(event.Role))
+		}
+		return fmt.Sprintf("Building docker image of %s...", color.YellowString(event.Role))
+	case EventRoleImageBuildSkipped:
+		if event.Path != "" {
+			return fmt.Sprintf("Skipping build of role tarball %s because %s", color.YellowString(event.Path), event.Message)
+		}
+		return fmt.Sprintf("Skipping build of role image %s because %s", color.YellowString(event.Role), event.Message)
+	case EventRoleImageBuildDone, EventRoleImageBuildFailed:
+		// The result is reported by the command itself
+		return ""
+	case EventKubeFileWritten:
+		if event.Role != "" {
+			return fmt.Sprintf("Writing config %s for role %s",
+				color.CyanString(event.Path),
+				color.CyanString(event.Role))
+		}
+		return fmt.Sprintf("Writing config %s", color.CyanString(event.Path))
+	case EventWarning:
+		return fmt.Sprintf("%s: %s", color.YellowString("Warning"), event.Message)
+	}
+
+	return event.Message
+}
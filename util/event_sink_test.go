@@ -0,0 +1,70 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONEventSink(t *testing.T) {
+	assert := assert.New(t)
+
+	buf := &bytes.Buffer{}
+	sink := NewJSONEventSink(buf)
+	sink.Emit(Event{
+		Type:        EventPackageCompileFailed,
+		Release:     "tor",
+		Package:     "libevent",
+		Fingerprint: "deadbeef",
+		Duration:    1500 * time.Millisecond,
+		Err:         errors.New("exit status 2"),
+	})
+	sink.Emit(Event{Type: EventKubeFileWritten, Path: "/out/tor.yaml", Role: "tor"})
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+
+	var event map[string]interface{}
+	require.NoError(t, json.Unmarshal(lines[0], &event))
+	assert.Equal("package-compile-failed", event["event"])
+	assert.Equal("tor", event["release"])
+	assert.Equal("libevent", event["package"])
+	assert.Equal("deadbeef", event["fingerprint"])
+	assert.Equal(1.5, event["duration"])
+	assert.Equal("exit status 2", event["error"])
+	assert.NotEmpty(event["time"])
+
+	event = nil
+	require.NoError(t, json.Unmarshal(lines[1], &event))
+	assert.Equal("kube-file-written", event["event"])
+	assert.Equal("/out/tor.yaml", event["path"])
+	assert.NotContains(event, "duration")
+	assert.NotContains(event, "error")
+}
+
+func TestHumanEventSink(t *testing.T) {
+	assert := assert.New(t)
+
+	saveNoColor := color.NoColor
+	color.NoColor = true
+	defer func() {
+		color.NoColor = saveNoColor
+	}()
+
+	buf := &bytes.Buffer{}
+	sink := NewHumanEventSink(buf)
+	sink.Emit(Event{Type: EventPackageCompileStart, Release: "tor", Package: "libevent"})
+	sink.Emit(Event{Type: EventPackageCompileFailed, Release: "tor", Package: "libevent", Err: errors.New("exit status 2")})
+	sink.Emit(Event{Type: EventRoleImageBuildDone, Role: "myrole"})
+	sink.Emit(Event{Type: EventKubeFileWritten, Path: "/out/tor.yaml", Role: "tor"})
+
+	assert.Equal("compile: tor/libevent\n"+
+		"result   > failure: tor/libevent - exit status 2\n"+
+		"Writing config /out/tor.yaml for role tor\n", buf.String())
+}
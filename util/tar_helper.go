@@ -8,6 +8,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
 )
 
 var (
@@ -120,3 +121,56 @@ func CopyFileToTarStream(stream *tar.Writer, path string, header *tar.Header) er
 	}
 	return nil
 }
+
+// WriteDirToTargz writes the contents of a directory as a tar.gz stream,
+// preserving file modes and symlinks. Names in the archive are relative to the
+// directory and prefixed with "./", as in BOSH package archives.
+func WriteDirToTargz(targz io.Writer, sourceDir string) error {
+	gzipWriter := gzip.NewWriter(targz)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	err := filepath.Walk(sourceDir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(sourceDir, filePath)
+		if err != nil {
+			return err
+		}
+
+		var linkname string
+		if info.Mode()&os.ModeSymlink != 0 {
+			if linkname, err = os.Readlink(filePath); err != nil {
+				return err
+			}
+		}
+
+		header, err := tar.FileInfoHeader(info, linkname)
+		if err != nil {
+			return err
+		}
+		header.Name = "./" + filepath.ToSlash(relPath)
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if relPath == "." {
+			header.Name = "./"
+		}
+
+		if !info.Mode().IsRegular() {
+			return tarWriter.WriteHeader(header)
+		}
+
+		return CopyFileToTarStream(tarWriter, filePath, header)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return err
+	}
+
+	return gzipWriter.Close()
+}
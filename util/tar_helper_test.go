@@ -85,3 +85,39 @@ func TestCopyFileToTarStream(t *testing.T) {
 	assert.NoError(err)
 	assert.Equal(expected, actual, "Incorrect data read")
 }
+
+func TestWriteDirToTargz(t *testing.T) {
+	assert := assert.New(t)
+
+	sourceDir, err := ioutil.TempDir("", "fissile-tar-test")
+	assert.NoError(err)
+	defer os.RemoveAll(sourceDir)
+
+	assert.NoError(os.MkdirAll(filepath.Join(sourceDir, "bin"), 0755))
+	assert.NoError(ioutil.WriteFile(filepath.Join(sourceDir, "bin", "hello"), []byte("hello"), 0755))
+	assert.NoError(os.Symlink("hello", filepath.Join(sourceDir, "bin", "link")))
+
+	buf := bytes.Buffer{}
+	assert.NoError(WriteDirToTargz(&buf, sourceDir))
+
+	found := make(map[string]*tar.Header)
+	contents := make(map[string][]byte)
+	err = TargzIterate("test", &buf, func(reader *tar.Reader, header *tar.Header) error {
+		found[header.Name] = header
+		data, err := ioutil.ReadAll(reader)
+		contents[header.Name] = data
+		return err
+	})
+	assert.NoError(err)
+
+	assert.Contains(found, "./")
+	assert.Contains(found, "./bin/")
+	if assert.Contains(found, "./bin/hello") {
+		assert.EqualValues(0755, found["./bin/hello"].Mode&0777)
+		assert.Equal([]byte("hello"), contents["./bin/hello"])
+	}
+	if assert.Contains(found, "./bin/link") {
+		assert.EqualValues(tar.TypeSymlink, found["./bin/link"].Typeflag)
+		assert.Equal("hello", found["./bin/link"].Linkname)
+	}
+}
@@ -0,0 +1,255 @@
+package util
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/SUSE/termui"
+)
+
+// TimingKind identifies what a Timing was measured for
+type TimingKind string
+
+// These are the kinds of timings collected by long-running commands
+const (
+	TimingPackage   TimingKind = "package"
+	TimingRoleImage TimingKind = "role-image"
+)
+
+// Timing is the measurement of compiling one package or building one role
+// image
+type Timing struct {
+	Kind        TimingKind
+	Release     string
+	Name        string
+	Fingerprint string
+	// Wait is the time spent waiting for dependencies before starting
+	Wait     time.Duration
+	Duration time.Duration
+	Failed   bool
+}
+
+// Status returns "success" or "failed"
+func (t Timing) Status() string {
+	if t.Failed {
+		return "failed"
+	}
+	return "success"
+}
+
+// TimingCollector gathers the timings of a single run of a command.  It is
+// safe for concurrent use.
+type TimingCollector struct {
+	command string
+	start   time.Time
+	mutex   sync.Mutex
+	timings []Timing
+}
+
+// NewTimingCollector creates a TimingCollector for a run of the named
+// command, starting now
+func NewTimingCollector(command string) *TimingCollector {
+	return &TimingCollector{
+		command: command,
+		start:   time.Now(),
+	}
+}
+
+// Record adds a timing to the collector.  Recording into a nil collector
+// does nothing, so callers don't have to check whether timings are wanted.
+func (c *TimingCollector) Record(timing Timing) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.timings = append(c.timings, timing)
+}
+
+// Timings returns the recorded timings, slowest first
+func (c *TimingCollector) Timings() []Timing {
+	c.mutex.Lock()
+	timings := make([]Timing, len(c.timings))
+	copy(timings, c.timings)
+	c.mutex.Unlock()
+
+	sort.SliceStable(timings, func(i, j int) bool {
+		return timings[i].Duration > timings[j].Duration
+	})
+	return timings
+}
+
+// Elapsed returns the wall clock time since the collector was created
+func (c *TimingCollector) Elapsed() time.Duration {
+	return time.Since(c.start)
+}
+
+// WriteSummary prints a table of the slowest packages and role images, at
+// most limit rows (all of them if limit is not positive), followed by totals
+func (c *TimingCollector) WriteSummary(writer io.Writer, limit int) {
+	timings := c.Timings()
+
+	var total time.Duration
+	for _, timing := range timings {
+		total += timing.Duration
+	}
+
+	if limit > 0 && len(timings) > limit {
+		timings = timings[:limit]
+	}
+
+	table := termui.NewTable("Kind", "Name", "Status", "Wait", "Duration", "Share")
+	for _, timing := range timings {
+		name := timing.Name
+		if timing.Release != "" {
+			name = fmt.Sprintf("%s/%s", timing.Release, timing.Name)
+		}
+		share := 0.0
+		if total > 0 {
+			share = 100 * timing.Duration.Seconds() / total.Seconds()
+		}
+		table.Add(
+			string(timing.Kind),
+			name,
+			timing.Status(),
+			formatDuration(timing.Wait),
+			formatDuration(timing.Duration),
+			fmt.Sprintf("%.1f%%", share),
+		)
+	}
+	table.PrintTo(writer)
+
+	fmt.Fprintf(writer, "%s: %d steps took %s in total, %s wall clock time\n",
+		c.command,
+		len(c.timings),
+		formatDuration(total),
+		formatDuration(c.Elapsed()))
+}
+
+// WriteCSV appends the timings to a CSV file, writing a header first if the
+// file is new, so that the file can accumulate timings across runs
+func (c *TimingCollector) WriteCSV(path string) error {
+	csvFile, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+	defer csvFile.Close()
+
+	info, err := csvFile.Stat()
+	if err != nil {
+		return err
+	}
+
+	w := csv.NewWriter(csvFile)
+	if info.Size() == 0 {
+		w.Write([]string{"time", "command", "kind", "release", "name", "fingerprint", "status", "wait_seconds", "duration_seconds"})
+	}
+
+	now := time.Now().UTC().Format("2006-01-02 15:04:05") // Excel-readable time format.
+	for _, timing := range c.Timings() {
+		w.Write([]string{
+			now,
+			c.command,
+			string(timing.Kind),
+			timing.Release,
+			timing.Name,
+			timing.Fingerprint,
+			timing.Status(),
+			formatSeconds(timing.Wait),
+			formatSeconds(timing.Duration),
+		})
+	}
+	w.Write([]string{now, c.command, "run", "", c.command, "", "", "", formatSeconds(c.Elapsed())})
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return csvFile.Close()
+}
+
+// WritePrometheus writes the timings as gauges in the format of the
+// Prometheus node exporter textfile collector.  The file is replaced
+// atomically, so the exporter never reads a partial file.
+func (c *TimingCollector) WritePrometheus(path string) error {
+	timings := c.Timings()
+
+	var b bytes.Buffer
+
+	fmt.Fprintln(&b, "# HELP fissile_run_seconds Wall clock time of the last fissile run.")
+	fmt.Fprintln(&b, "# TYPE fissile_run_seconds gauge")
+	fmt.Fprintf(&b, "fissile_run_seconds{command=%q} %s\n", c.command, formatSeconds(c.Elapsed()))
+
+	var packages, roles []Timing
+	for _, timing := range timings {
+		switch timing.Kind {
+		case TimingPackage:
+			packages = append(packages, timing)
+		case TimingRoleImage:
+			roles = append(roles, timing)
+		}
+	}
+
+	if len(packages) > 0 {
+		fmt.Fprintln(&b, "# HELP fissile_package_compile_seconds Time spent compiling a BOSH package.")
+		fmt.Fprintln(&b, "# TYPE fissile_package_compile_seconds gauge")
+		for _, timing := range packages {
+			fmt.Fprintf(&b, "fissile_package_compile_seconds{%s} %s\n", packageLabels(timing), formatSeconds(timing.Duration))
+		}
+		fmt.Fprintln(&b, "# HELP fissile_package_wait_seconds Time a BOSH package waited for its dependencies to compile.")
+		fmt.Fprintln(&b, "# TYPE fissile_package_wait_seconds gauge")
+		for _, timing := range packages {
+			fmt.Fprintf(&b, "fissile_package_wait_seconds{%s} %s\n", packageLabels(timing), formatSeconds(timing.Wait))
+		}
+	}
+
+	if len(roles) > 0 {
+		fmt.Fprintln(&b, "# HELP fissile_role_image_build_seconds Time spent building a role image.")
+		fmt.Fprintln(&b, "# TYPE fissile_role_image_build_seconds gauge")
+		for _, timing := range roles {
+			fmt.Fprintf(&b, "fissile_role_image_build_seconds{role=%q,status=%q} %s\n",
+				timing.Name, timing.Status(), formatSeconds(timing.Duration))
+		}
+	}
+
+	tempFile, err := ioutil.TempFile(filepath.Dir(path), ".fissile-metrics-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+
+	if _, err := tempFile.WriteString(b.String()); err != nil {
+		tempFile.Close()
+		return err
+	}
+	if err := tempFile.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tempFile.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tempFile.Name(), path)
+}
+
+func packageLabels(timing Timing) string {
+	return fmt.Sprintf("release=%q,package=%q,fingerprint=%q,status=%q",
+		timing.Release, timing.Name, timing.Fingerprint, timing.Status())
+}
+
+func formatSeconds(duration time.Duration) string {
+	return fmt.Sprintf("%.3f", duration.Seconds())
+}
+
+func formatDuration(duration time.Duration) string {
+	return duration.Round(100 * time.Millisecond).String()
+}
@@ -0,0 +1,124 @@
+package util
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestTimingCollector() *TimingCollector {
+	timings := NewTimingCollector("build-packages")
+	timings.Record(Timing{
+		Kind:        TimingPackage,
+		Release:     "tor",
+		Name:        "libevent",
+		Fingerprint: "aaaa",
+		Duration:    2 * time.Second,
+	})
+	timings.Record(Timing{
+		Kind:        TimingPackage,
+		Release:     "tor",
+		Name:        "tor",
+		Fingerprint: "bbbb",
+		Wait:        2 * time.Second,
+		Duration:    6 * time.Second,
+		Failed:      true,
+	})
+	return timings
+}
+
+func TestTimingCollector(t *testing.T) {
+	assert := assert.New(t)
+
+	var timings *TimingCollector
+	timings.Record(Timing{Name: "ignored"})
+
+	timings = newTestTimingCollector()
+	recorded := timings.Timings()
+	if assert.Len(recorded, 2) {
+		assert.Equal("tor", recorded[0].Name, "Timings should be sorted slowest first")
+		assert.Equal("failed", recorded[0].Status())
+		assert.Equal("libevent", recorded[1].Name)
+		assert.Equal("success", recorded[1].Status())
+	}
+}
+
+func TestTimingCollectorSummary(t *testing.T) {
+	assert := assert.New(t)
+
+	saveNoColor := color.NoColor
+	color.NoColor = true
+	defer func() {
+		color.NoColor = saveNoColor
+	}()
+
+	buf := &bytes.Buffer{}
+	newTestTimingCollector().WriteSummary(buf, 1)
+
+	assert.Contains(buf.String(), "tor/tor")
+	assert.Contains(buf.String(), "75.0%")
+	assert.NotContains(buf.String(), "tor/libevent", "Summary should be limited to the slowest steps")
+	assert.Contains(buf.String(), "build-packages: 2 steps took 8s in total")
+}
+
+func TestTimingCollectorCSV(t *testing.T) {
+	assert := assert.New(t)
+
+	tempDir, err := ioutil.TempDir("", "fissile-tests")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	csvPath := filepath.Join(tempDir, "metrics.csv")
+	require.NoError(t, newTestTimingCollector().WriteCSV(csvPath))
+	require.NoError(t, newTestTimingCollector().WriteCSV(csvPath))
+
+	csvFile, err := os.Open(csvPath)
+	require.NoError(t, err)
+	defer csvFile.Close()
+
+	records, err := csv.NewReader(csvFile).ReadAll()
+	require.NoError(t, err)
+
+	// One header, then two timings and a run total per run
+	require.Len(t, records, 7)
+	assert.Equal("time", records[0][0])
+	assert.Equal([]string{"build-packages", "package", "tor", "tor", "bbbb", "failed", "2.000", "6.000"}, records[1][1:])
+	assert.Equal([]string{"build-packages", "package", "tor", "libevent", "aaaa", "success", "0.000", "2.000"}, records[2][1:])
+	assert.Equal("run", records[3][2])
+	assert.NotEqual("time", records[4][0], "The header should only be written once")
+}
+
+func TestTimingCollectorPrometheus(t *testing.T) {
+	assert := assert.New(t)
+
+	tempDir, err := ioutil.TempDir("", "fissile-tests")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	timings := newTestTimingCollector()
+	timings.Record(Timing{Kind: TimingRoleImage, Name: "myrole", Duration: 1500 * time.Millisecond})
+
+	promPath := filepath.Join(tempDir, "fissile.prom")
+	require.NoError(t, timings.WritePrometheus(promPath))
+
+	contents, err := ioutil.ReadFile(promPath)
+	require.NoError(t, err)
+
+	assert.Contains(string(contents), "# TYPE fissile_package_compile_seconds gauge\n")
+	assert.Contains(string(contents), `fissile_package_compile_seconds{release="tor",package="tor",fingerprint="bbbb",status="failed"} 6.000`)
+	assert.Contains(string(contents), `fissile_package_wait_seconds{release="tor",package="tor",fingerprint="bbbb",status="failed"} 2.000`)
+	assert.Contains(string(contents), `fissile_role_image_build_seconds{role="myrole",status="success"} 1.500`)
+	assert.Contains(string(contents), `fissile_run_seconds{command="build-packages"}`)
+
+	files, err := ioutil.ReadDir(tempDir)
+	require.NoError(t, err)
+	assert.Len(files, 1, "No temporary files should be left behind")
+}
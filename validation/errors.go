@@ -13,10 +13,41 @@ type Error struct {
 	Field    string
 	BadValue interface{}
 	Detail   string
+	Position Position // Where the field is defined, if known
 }
 
-// Error implements the error interface.
+// Position is the place of a field in a file.  Lines and columns start at 1,
+// and are 0 when unknown.
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+// IsKnown returns whether the file of the position is known
+func (p Position) IsKnown() bool {
+	return p.File != ""
+}
+
+// String returns the position as file:line:column, as compilers report
+// them, omitting the parts that are not known
+func (p Position) String() string {
+	switch {
+	case p.Line == 0:
+		return p.File
+	case p.Column == 0:
+		return fmt.Sprintf("%s:%d", p.File, p.Line)
+	default:
+		return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+	}
+}
+
+// Error implements the error interface.  Errors with a known position are
+// prefixed with it, as compilers do, so that editors can jump to it.
 func (v *Error) Error() string {
+	if v.Position.IsKnown() {
+		return fmt.Sprintf("%s: %s: %s", v.Position, v.Field, v.ErrorBody())
+	}
 	return fmt.Sprintf("%s: %s", v.Field, v.ErrorBody())
 }
 
@@ -105,26 +136,26 @@ func (t ErrorType) String() string {
 // NotFound returns a *Error indicating "value not found".  This is
 // used to report failure to find a requested value (e.g. looking up an ID).
 func NotFound(field string, value interface{}) *Error {
-	return &Error{ErrorTypeNotFound, field, value, ""}
+	return &Error{ErrorTypeNotFound, field, value, "", Position{}}
 }
 
 // Required returns a *Error indicating "value required".  This is used
 // to report required values that are not provided (e.g. empty strings, null
 // values, or empty arrays).
 func Required(field string, detail string) *Error {
-	return &Error{ErrorTypeRequired, field, "", detail}
+	return &Error{ErrorTypeRequired, field, "", detail, Position{}}
 }
 
 // Duplicate returns a *Error indicating "duplicate value".  This is
 // used to report collisions of values that must be unique (e.g. names or IDs).
 func Duplicate(field string, value interface{}) *Error {
-	return &Error{ErrorTypeDuplicate, field, value, ""}
+	return &Error{ErrorTypeDuplicate, field, value, "", Position{}}
 }
 
 // Invalid returns a *Error indicating "invalid value".  This is used
 // to report malformed values (e.g. failed regex match, too long, out of bounds).
 func Invalid(field string, value interface{}, detail string) *Error {
-	return &Error{ErrorTypeInvalid, field, value, detail}
+	return &Error{ErrorTypeInvalid, field, value, detail, Position{}}
 }
 
 // NotSupported returns a *Error indicating "unsupported value".
@@ -135,7 +166,7 @@ func NotSupported(field string, value interface{}, validValues []string) *Error
 	if validValues != nil && len(validValues) > 0 {
 		detail = "supported values: " + strings.Join(validValues, ", ")
 	}
-	return &Error{ErrorTypeNotSupported, field, value, detail}
+	return &Error{ErrorTypeNotSupported, field, value, detail, Position{}}
 }
 
 // Forbidden returns a *Error indicating "forbidden".  This is used to
@@ -143,7 +174,7 @@ func NotSupported(field string, value interface{}, validValues []string) *Error
 // some conditions, but which are not permitted by current conditions (e.g.
 // security policy).
 func Forbidden(field string, detail string) *Error {
-	return &Error{ErrorTypeForbidden, field, "", detail}
+	return &Error{ErrorTypeForbidden, field, "", detail, Position{}}
 }
 
 // TooLong returns a *Error indicating "too long".  This is used to
@@ -151,14 +182,14 @@ func Forbidden(field string, detail string) *Error {
 // Invalid, but the returned error will not include the too-long
 // value.
 func TooLong(field string, value interface{}, maxLength int) *Error {
-	return &Error{ErrorTypeTooLong, field, value, fmt.Sprintf("must have at most %d characters", maxLength)}
+	return &Error{ErrorTypeTooLong, field, value, fmt.Sprintf("must have at most %d characters", maxLength), Position{}}
 }
 
 // InternalError returns a *Error indicating "internal error".  This is used
 // to signal that an error was found that was not directly related to user
 // input.  The err argument must be non-nil.
 func InternalError(field string, err error) *Error {
-	return &Error{ErrorTypeInternal, field, nil, err.Error()}
+	return &Error{ErrorTypeInternal, field, nil, err.Error(), Position{}}
 }
 
 // ErrorList holds a set of Errors.  It is plausible that we might one day have
@@ -166,7 +197,9 @@ func InternalError(field string, err error) *Error {
 // we can keep it simple and leave ErrorList here.
 type ErrorList []*Error
 
-// Errors implements the error interface.
+// Errors implements the error interface.  The errors are reported one per
+// line, prefixed with their position when known, as in
+// "role-manifest.yml:12:5: roles[api].run.memory: Invalid value: -1".
 func (v *ErrorList) Errors() string {
 	var values []string
 
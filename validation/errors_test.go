@@ -74,3 +74,21 @@ func TestErrorUsefulMessage(t *testing.T) {
 		assert.Contains(t, s, part)
 	}
 }
+
+func TestErrorPosition(t *testing.T) {
+	err := Invalid("roles[api].run.memory", -1, "")
+	assert.Equal(t, `roles[api].run.memory: Invalid value: -1`, err.Error())
+
+	err.Position = Position{File: "role-manifest.yml"}
+	assert.Equal(t, `role-manifest.yml: roles[api].run.memory: Invalid value: -1`, err.Error())
+
+	err.Position.Line = 12
+	assert.Equal(t, `role-manifest.yml:12: roles[api].run.memory: Invalid value: -1`, err.Error())
+
+	err.Position.Column = 5
+	assert.Equal(t, `role-manifest.yml:12:5: roles[api].run.memory: Invalid value: -1`, err.Error())
+
+	allErrs := ErrorList{err, Required("roles[api].name", "")}
+	assert.Equal(t, `role-manifest.yml:12:5: roles[api].run.memory: Invalid value: -1
+roles[api].name: Required value`, allErrs.Errors())
+}